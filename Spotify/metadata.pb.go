@@ -348,6 +348,124 @@ func (AudioFile_Format) EnumDescriptor() ([]byte, []int) {
 	return file_metadata_proto_rawDescGZIP(), []int{15, 0}
 }
 
+type Show_MediaType int32
+
+const (
+	Show_MIXED Show_MediaType = 0
+	Show_AUDIO Show_MediaType = 1
+	Show_VIDEO Show_MediaType = 2
+)
+
+// Enum value maps for Show_MediaType.
+var (
+	Show_MediaType_name = map[int32]string{
+		0: "MIXED",
+		1: "AUDIO",
+		2: "VIDEO",
+	}
+	Show_MediaType_value = map[string]int32{
+		"MIXED": 0,
+		"AUDIO": 1,
+		"VIDEO": 2,
+	}
+)
+
+func (x Show_MediaType) Enum() *Show_MediaType {
+	p := new(Show_MediaType)
+	*p = x
+	return p
+}
+
+func (x Show_MediaType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Show_MediaType) Descriptor() protoreflect.EnumDescriptor {
+	return file_metadata_proto_enumTypes[5].Descriptor()
+}
+
+func (Show_MediaType) Type() protoreflect.EnumType {
+	return &file_metadata_proto_enumTypes[5]
+}
+
+func (x Show_MediaType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *Show_MediaType) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = Show_MediaType(num)
+	return nil
+}
+
+// Deprecated: Use Show_MediaType.Descriptor instead.
+func (Show_MediaType) EnumDescriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{16, 0}
+}
+
+type Show_ConsumptionOrder int32
+
+const (
+	Show_SEQUENTIAL Show_ConsumptionOrder = 1
+	Show_EPISODIC   Show_ConsumptionOrder = 2
+	Show_RECENT     Show_ConsumptionOrder = 3
+)
+
+// Enum value maps for Show_ConsumptionOrder.
+var (
+	Show_ConsumptionOrder_name = map[int32]string{
+		1: "SEQUENTIAL",
+		2: "EPISODIC",
+		3: "RECENT",
+	}
+	Show_ConsumptionOrder_value = map[string]int32{
+		"SEQUENTIAL": 1,
+		"EPISODIC":   2,
+		"RECENT":     3,
+	}
+)
+
+func (x Show_ConsumptionOrder) Enum() *Show_ConsumptionOrder {
+	p := new(Show_ConsumptionOrder)
+	*p = x
+	return p
+}
+
+func (x Show_ConsumptionOrder) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Show_ConsumptionOrder) Descriptor() protoreflect.EnumDescriptor {
+	return file_metadata_proto_enumTypes[6].Descriptor()
+}
+
+func (Show_ConsumptionOrder) Type() protoreflect.EnumType {
+	return &file_metadata_proto_enumTypes[6]
+}
+
+func (x Show_ConsumptionOrder) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *Show_ConsumptionOrder) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = Show_ConsumptionOrder(num)
+	return nil
+}
+
+// Deprecated: Use Show_ConsumptionOrder.Descriptor instead.
+func (Show_ConsumptionOrder) EnumDescriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{16, 1}
+}
+
 type TopTracks struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -1628,6 +1746,395 @@ func (x *AudioFile) GetFormat() AudioFile_Format {
 	return AudioFile_OGG_VORBIS_96
 }
 
+type Show struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Gid                  []byte                 `protobuf:"bytes,1,opt,name=gid" json:"gid,omitempty"`
+	Name                 *string                `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Description          *string                `protobuf:"bytes,64,opt,name=description" json:"description,omitempty"`
+	DeprecatedPopularity *int32                 `protobuf:"zigzag32,65,opt,name=deprecated_popularity,json=deprecatedPopularity" json:"deprecated_popularity,omitempty"`
+	Publisher            *string                `protobuf:"bytes,66,opt,name=publisher" json:"publisher,omitempty"`
+	Language             *string                `protobuf:"bytes,67,opt,name=language" json:"language,omitempty"`
+	Explicit             *bool                  `protobuf:"varint,68,opt,name=explicit" json:"explicit,omitempty"`
+	CoverImage           *ImageGroup            `protobuf:"bytes,69,opt,name=cover_image,json=coverImage" json:"cover_image,omitempty"`
+	Episode              []*Episode             `protobuf:"bytes,70,rep,name=episode" json:"episode,omitempty"`
+	Copyright            []*Copyright           `protobuf:"bytes,71,rep,name=copyright" json:"copyright,omitempty"`
+	Restriction          []*Restriction         `protobuf:"bytes,72,rep,name=restriction" json:"restriction,omitempty"`
+	Keyword              []string               `protobuf:"bytes,73,rep,name=keyword" json:"keyword,omitempty"`
+	MediaType            *Show_MediaType        `protobuf:"varint,74,opt,name=media_type,json=mediaType,enum=Spotify.Show_MediaType" json:"media_type,omitempty"`
+	ConsumptionOrder     *Show_ConsumptionOrder `protobuf:"varint,75,opt,name=consumption_order,json=consumptionOrder,enum=Spotify.Show_ConsumptionOrder" json:"consumption_order,omitempty"`
+	CountryOfOrigin      *string                `protobuf:"bytes,79,opt,name=country_of_origin,json=countryOfOrigin" json:"country_of_origin,omitempty"`
+	Categories           []*Category            `protobuf:"bytes,80,rep,name=categories" json:"categories,omitempty"`
+}
+
+func (x *Show) Reset() {
+	*x = Show{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Show) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Show) ProtoMessage() {}
+
+func (x *Show) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Show.ProtoReflect.Descriptor instead.
+func (*Show) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Show) GetGid() []byte {
+	if x != nil {
+		return x.Gid
+	}
+	return nil
+}
+
+func (x *Show) GetName() string {
+	if x != nil && x.Name != nil {
+		return *x.Name
+	}
+	return ""
+}
+
+func (x *Show) GetDescription() string {
+	if x != nil && x.Description != nil {
+		return *x.Description
+	}
+	return ""
+}
+
+func (x *Show) GetDeprecatedPopularity() int32 {
+	if x != nil && x.DeprecatedPopularity != nil {
+		return *x.DeprecatedPopularity
+	}
+	return 0
+}
+
+func (x *Show) GetPublisher() string {
+	if x != nil && x.Publisher != nil {
+		return *x.Publisher
+	}
+	return ""
+}
+
+func (x *Show) GetLanguage() string {
+	if x != nil && x.Language != nil {
+		return *x.Language
+	}
+	return ""
+}
+
+func (x *Show) GetExplicit() bool {
+	if x != nil && x.Explicit != nil {
+		return *x.Explicit
+	}
+	return false
+}
+
+func (x *Show) GetCoverImage() *ImageGroup {
+	if x != nil {
+		return x.CoverImage
+	}
+	return nil
+}
+
+func (x *Show) GetEpisode() []*Episode {
+	if x != nil {
+		return x.Episode
+	}
+	return nil
+}
+
+func (x *Show) GetCopyright() []*Copyright {
+	if x != nil {
+		return x.Copyright
+	}
+	return nil
+}
+
+func (x *Show) GetRestriction() []*Restriction {
+	if x != nil {
+		return x.Restriction
+	}
+	return nil
+}
+
+func (x *Show) GetKeyword() []string {
+	if x != nil {
+		return x.Keyword
+	}
+	return nil
+}
+
+func (x *Show) GetMediaType() Show_MediaType {
+	if x != nil && x.MediaType != nil {
+		return *x.MediaType
+	}
+	return Show_MIXED
+}
+
+func (x *Show) GetConsumptionOrder() Show_ConsumptionOrder {
+	if x != nil && x.ConsumptionOrder != nil {
+		return *x.ConsumptionOrder
+	}
+	return Show_SEQUENTIAL
+}
+
+func (x *Show) GetCountryOfOrigin() string {
+	if x != nil && x.CountryOfOrigin != nil {
+		return *x.CountryOfOrigin
+	}
+	return ""
+}
+
+func (x *Show) GetCategories() []*Category {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+type Episode struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Gid                  []byte         `protobuf:"bytes,1,opt,name=gid" json:"gid,omitempty"`
+	Name                 *string        `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Duration             *int32         `protobuf:"zigzag32,7,opt,name=duration" json:"duration,omitempty"`
+	Audio                []*AudioFile   `protobuf:"bytes,12,rep,name=audio" json:"audio,omitempty"`
+	Description          *string        `protobuf:"bytes,64,opt,name=description" json:"description,omitempty"`
+	Number               *int32         `protobuf:"zigzag32,65,opt,name=number" json:"number,omitempty"`
+	PublishTime          *Date          `protobuf:"bytes,66,opt,name=publish_time,json=publishTime" json:"publish_time,omitempty"`
+	DeprecatedPopularity *int32         `protobuf:"zigzag32,67,opt,name=deprecated_popularity,json=deprecatedPopularity" json:"deprecated_popularity,omitempty"`
+	Covers               *ImageGroup    `protobuf:"bytes,68,opt,name=covers" json:"covers,omitempty"`
+	Language             *string        `protobuf:"bytes,69,opt,name=language" json:"language,omitempty"`
+	Explicit             *bool          `protobuf:"varint,70,opt,name=explicit" json:"explicit,omitempty"`
+	Show                 *Show          `protobuf:"bytes,71,opt,name=show" json:"show,omitempty"`
+	AudioPreview         []*AudioFile   `protobuf:"bytes,74,rep,name=audio_preview,json=audioPreview" json:"audio_preview,omitempty"`
+	Restriction          []*Restriction `protobuf:"bytes,75,rep,name=restriction" json:"restriction,omitempty"`
+	Keyword              []string       `protobuf:"bytes,77,rep,name=keyword" json:"keyword,omitempty"`
+	ExternalUrl          *string        `protobuf:"bytes,83,opt,name=external_url,json=externalUrl" json:"external_url,omitempty"`
+}
+
+func (x *Episode) Reset() {
+	*x = Episode{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Episode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Episode) ProtoMessage() {}
+
+func (x *Episode) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Episode.ProtoReflect.Descriptor instead.
+func (*Episode) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Episode) GetGid() []byte {
+	if x != nil {
+		return x.Gid
+	}
+	return nil
+}
+
+func (x *Episode) GetName() string {
+	if x != nil && x.Name != nil {
+		return *x.Name
+	}
+	return ""
+}
+
+func (x *Episode) GetDuration() int32 {
+	if x != nil && x.Duration != nil {
+		return *x.Duration
+	}
+	return 0
+}
+
+func (x *Episode) GetAudio() []*AudioFile {
+	if x != nil {
+		return x.Audio
+	}
+	return nil
+}
+
+func (x *Episode) GetDescription() string {
+	if x != nil && x.Description != nil {
+		return *x.Description
+	}
+	return ""
+}
+
+func (x *Episode) GetNumber() int32 {
+	if x != nil && x.Number != nil {
+		return *x.Number
+	}
+	return 0
+}
+
+func (x *Episode) GetPublishTime() *Date {
+	if x != nil {
+		return x.PublishTime
+	}
+	return nil
+}
+
+func (x *Episode) GetDeprecatedPopularity() int32 {
+	if x != nil && x.DeprecatedPopularity != nil {
+		return *x.DeprecatedPopularity
+	}
+	return 0
+}
+
+func (x *Episode) GetCovers() *ImageGroup {
+	if x != nil {
+		return x.Covers
+	}
+	return nil
+}
+
+func (x *Episode) GetLanguage() string {
+	if x != nil && x.Language != nil {
+		return *x.Language
+	}
+	return ""
+}
+
+func (x *Episode) GetExplicit() bool {
+	if x != nil && x.Explicit != nil {
+		return *x.Explicit
+	}
+	return false
+}
+
+func (x *Episode) GetShow() *Show {
+	if x != nil {
+		return x.Show
+	}
+	return nil
+}
+
+func (x *Episode) GetAudioPreview() []*AudioFile {
+	if x != nil {
+		return x.AudioPreview
+	}
+	return nil
+}
+
+func (x *Episode) GetRestriction() []*Restriction {
+	if x != nil {
+		return x.Restriction
+	}
+	return nil
+}
+
+func (x *Episode) GetKeyword() []string {
+	if x != nil {
+		return x.Keyword
+	}
+	return nil
+}
+
+func (x *Episode) GetExternalUrl() string {
+	if x != nil && x.ExternalUrl != nil {
+		return *x.ExternalUrl
+	}
+	return ""
+}
+
+type Category struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name          *string     `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Subcategories []*Category `protobuf:"bytes,2,rep,name=subcategories" json:"subcategories,omitempty"`
+}
+
+func (x *Category) Reset() {
+	*x = Category{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Category) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Category) ProtoMessage() {}
+
+func (x *Category) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Category.ProtoReflect.Descriptor instead.
+func (*Category) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Category) GetName() string {
+	if x != nil && x.Name != nil {
+		return *x.Name
+	}
+	return ""
+}
+
+func (x *Category) GetSubcategories() []*Category {
+	if x != nil {
+		return x.Subcategories
+	}
+	return nil
+}
+
 var File_metadata_proto protoreflect.FileDescriptor
 
 var file_metadata_proto_rawDesc = []byte{
@@ -1841,13 +2348,13 @@ var file_metadata_proto_rawDesc = []byte{
 	0x74, 0x69, 0x66, 0x79, 0x2e, 0x44, 0x61, 0x74, 0x65, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x22, 0x2e,
 	0x0a, 0x0a, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03,
 	0x74, 0x79, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x79, 0x70, 0x12, 0x0e,
-	0x0a, 0x02, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0xab,
+	0x0a, 0x02, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0xc2,
 	0x02, 0x0a, 0x09, 0x41, 0x75, 0x64, 0x69, 0x6f, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x17, 0x0a, 0x07,
 	0x66, 0x69, 0x6c, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x66,
 	0x69, 0x6c, 0x65, 0x49, 0x64, 0x12, 0x31, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18,
 	0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e,
 	0x41, 0x75, 0x64, 0x69, 0x6f, 0x46, 0x69, 0x6c, 0x65, 0x2e, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74,
-	0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x22, 0xd1, 0x01, 0x0a, 0x06, 0x46, 0x6f, 0x72,
+	0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x22, 0xe8, 0x01, 0x0a, 0x06, 0x46, 0x6f, 0x72,
 	0x6d, 0x61, 0x74, 0x12, 0x11, 0x0a, 0x0d, 0x4f, 0x47, 0x47, 0x5f, 0x56, 0x4f, 0x52, 0x42, 0x49,
 	0x53, 0x5f, 0x39, 0x36, 0x10, 0x00, 0x12, 0x12, 0x0a, 0x0e, 0x4f, 0x47, 0x47, 0x5f, 0x56, 0x4f,
 	0x52, 0x42, 0x49, 0x53, 0x5f, 0x31, 0x36, 0x30, 0x10, 0x01, 0x12, 0x12, 0x0a, 0x0e, 0x4f, 0x47,
@@ -1856,15 +2363,107 @@ var file_metadata_proto_rawDesc = []byte{
 	0x50, 0x33, 0x5f, 0x33, 0x32, 0x30, 0x10, 0x04, 0x12, 0x0b, 0x0a, 0x07, 0x4d, 0x50, 0x33, 0x5f,
 	0x31, 0x36, 0x30, 0x10, 0x05, 0x12, 0x0a, 0x0a, 0x06, 0x4d, 0x50, 0x33, 0x5f, 0x39, 0x36, 0x10,
 	0x06, 0x12, 0x0f, 0x0a, 0x0b, 0x4d, 0x50, 0x33, 0x5f, 0x31, 0x36, 0x30, 0x5f, 0x45, 0x4e, 0x43,
-	0x10, 0x07, 0x12, 0x0a, 0x0a, 0x06, 0x4f, 0x54, 0x48, 0x45, 0x52, 0x32, 0x10, 0x08, 0x12, 0x0a,
-	0x0a, 0x06, 0x4f, 0x54, 0x48, 0x45, 0x52, 0x33, 0x10, 0x09, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x41,
-	0x43, 0x5f, 0x31, 0x36, 0x30, 0x10, 0x0a, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x41, 0x43, 0x5f, 0x33,
-	0x32, 0x30, 0x10, 0x0b, 0x12, 0x0a, 0x0a, 0x06, 0x4f, 0x54, 0x48, 0x45, 0x52, 0x34, 0x10, 0x0c,
-	0x12, 0x0a, 0x0a, 0x06, 0x4f, 0x54, 0x48, 0x45, 0x52, 0x35, 0x10, 0x0d, 0x42, 0x33, 0x5a, 0x31,
-	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6c, 0x69, 0x62, 0x72, 0x65,
-	0x73, 0x70, 0x6f, 0x74, 0x2d, 0x6f, 0x72, 0x67, 0x2f, 0x6c, 0x69, 0x62, 0x72, 0x65, 0x73, 0x70,
-	0x6f, 0x74, 0x2d, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2f, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66,
-	0x79,
+	0x10, 0x07, 0x12, 0x0a, 0x0a, 0x06, 0x41, 0x41, 0x43, 0x5f, 0x32, 0x34, 0x10, 0x08, 0x12, 0x0a,
+	0x0a, 0x06, 0x41, 0x41, 0x43, 0x5f, 0x34, 0x38, 0x10, 0x09, 0x12, 0x0c, 0x0a, 0x08, 0x4f, 0x54,
+	0x48, 0x45, 0x52, 0x5f, 0x31, 0x30, 0x10, 0x0a, 0x12, 0x0c, 0x0a, 0x08, 0x4f, 0x54, 0x48, 0x45,
+	0x52, 0x5f, 0x31, 0x31, 0x10, 0x0b, 0x12, 0x0c, 0x0a, 0x08, 0x4f, 0x54, 0x48, 0x45, 0x52, 0x5f,
+	0x31, 0x32, 0x10, 0x0c, 0x12, 0x0c, 0x0a, 0x08, 0x4f, 0x54, 0x48, 0x45, 0x52, 0x5f, 0x31, 0x33,
+	0x10, 0x0d, 0x12, 0x0f, 0x0a, 0x0b, 0x41, 0x41, 0x43, 0x5f, 0x32, 0x34, 0x5f, 0x4e, 0x4f, 0x52,
+	0x4d, 0x10, 0x10, 0x22, 0x8f, 0x06, 0x0a, 0x04, 0x53, 0x68, 0x6f, 0x77, 0x12, 0x10, 0x0a, 0x03,
+	0x67, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x67, 0x69, 0x64, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x40, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x33, 0x0a, 0x15, 0x64, 0x65, 0x70, 0x72, 0x65, 0x63, 0x61, 0x74,
+	0x65, 0x64, 0x5f, 0x70, 0x6f, 0x70, 0x75, 0x6c, 0x61, 0x72, 0x69, 0x74, 0x79, 0x18, 0x41, 0x20,
+	0x01, 0x28, 0x11, 0x52, 0x14, 0x64, 0x65, 0x70, 0x72, 0x65, 0x63, 0x61, 0x74, 0x65, 0x64, 0x50,
+	0x6f, 0x70, 0x75, 0x6c, 0x61, 0x72, 0x69, 0x74, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x75, 0x62,
+	0x6c, 0x69, 0x73, 0x68, 0x65, 0x72, 0x18, 0x42, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x75,
+	0x62, 0x6c, 0x69, 0x73, 0x68, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x61, 0x6e, 0x67, 0x75,
+	0x61, 0x67, 0x65, 0x18, 0x43, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x61, 0x6e, 0x67, 0x75,
+	0x61, 0x67, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x70, 0x6c, 0x69, 0x63, 0x69, 0x74, 0x18,
+	0x44, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x65, 0x78, 0x70, 0x6c, 0x69, 0x63, 0x69, 0x74, 0x12,
+	0x34, 0x0a, 0x0b, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x18, 0x45,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x49,
+	0x6d, 0x61, 0x67, 0x65, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x52, 0x0a, 0x63, 0x6f, 0x76, 0x65, 0x72,
+	0x49, 0x6d, 0x61, 0x67, 0x65, 0x12, 0x2a, 0x0a, 0x07, 0x65, 0x70, 0x69, 0x73, 0x6f, 0x64, 0x65,
+	0x18, 0x46, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79,
+	0x2e, 0x45, 0x70, 0x69, 0x73, 0x6f, 0x64, 0x65, 0x52, 0x07, 0x65, 0x70, 0x69, 0x73, 0x6f, 0x64,
+	0x65, 0x12, 0x30, 0x0a, 0x09, 0x63, 0x6f, 0x70, 0x79, 0x72, 0x69, 0x67, 0x68, 0x74, 0x18, 0x47,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x43,
+	0x6f, 0x70, 0x79, 0x72, 0x69, 0x67, 0x68, 0x74, 0x52, 0x09, 0x63, 0x6f, 0x70, 0x79, 0x72, 0x69,
+	0x67, 0x68, 0x74, 0x12, 0x36, 0x0a, 0x0b, 0x72, 0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x48, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69,
+	0x66, 0x79, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b,
+	0x72, 0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x6b,
+	0x65, 0x79, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x49, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x6b, 0x65,
+	0x79, 0x77, 0x6f, 0x72, 0x64, 0x12, 0x36, 0x0a, 0x0a, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x5f, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x4a, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x53, 0x70, 0x6f, 0x74,
+	0x69, 0x66, 0x79, 0x2e, 0x53, 0x68, 0x6f, 0x77, 0x2e, 0x4d, 0x65, 0x64, 0x69, 0x61, 0x54, 0x79,
+	0x70, 0x65, 0x52, 0x09, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x54, 0x79, 0x70, 0x65, 0x12, 0x4b, 0x0a,
+	0x11, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6f, 0x72, 0x64,
+	0x65, 0x72, 0x18, 0x4b, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1e, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69,
+	0x66, 0x79, 0x2e, 0x53, 0x68, 0x6f, 0x77, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x10, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x2a, 0x0a, 0x11, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x72, 0x79, 0x5f, 0x6f, 0x66, 0x5f, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x18,
+	0x4f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79, 0x4f, 0x66,
+	0x4f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x12, 0x31, 0x0a, 0x0a, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f,
+	0x72, 0x69, 0x65, 0x73, 0x18, 0x50, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x53, 0x70, 0x6f,
+	0x74, 0x69, 0x66, 0x79, 0x2e, 0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x52, 0x0a, 0x63,
+	0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x69, 0x65, 0x73, 0x22, 0x2c, 0x0a, 0x09, 0x4d, 0x65, 0x64,
+	0x69, 0x61, 0x54, 0x79, 0x70, 0x65, 0x12, 0x09, 0x0a, 0x05, 0x4d, 0x49, 0x58, 0x45, 0x44, 0x10,
+	0x00, 0x12, 0x09, 0x0a, 0x05, 0x41, 0x55, 0x44, 0x49, 0x4f, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05,
+	0x56, 0x49, 0x44, 0x45, 0x4f, 0x10, 0x02, 0x22, 0x3c, 0x0a, 0x10, 0x43, 0x6f, 0x6e, 0x73, 0x75,
+	0x6d, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x0e, 0x0a, 0x0a, 0x53,
+	0x45, 0x51, 0x55, 0x45, 0x4e, 0x54, 0x49, 0x41, 0x4c, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x45,
+	0x50, 0x49, 0x53, 0x4f, 0x44, 0x49, 0x43, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x52, 0x45, 0x43,
+	0x45, 0x4e, 0x54, 0x10, 0x03, 0x22, 0xcc, 0x04, 0x0a, 0x07, 0x45, 0x70, 0x69, 0x73, 0x6f, 0x64,
+	0x65, 0x12, 0x10, 0x0a, 0x03, 0x67, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03,
+	0x67, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x75, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x11, 0x52, 0x08, 0x64, 0x75, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x28, 0x0a, 0x05, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x18, 0x0c, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x41, 0x75, 0x64,
+	0x69, 0x6f, 0x46, 0x69, 0x6c, 0x65, 0x52, 0x05, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x12, 0x20, 0x0a,
+	0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x40, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x41, 0x20, 0x01, 0x28, 0x11, 0x52,
+	0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x30, 0x0a, 0x0c, 0x70, 0x75, 0x62, 0x6c, 0x69,
+	0x73, 0x68, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x42, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e,
+	0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x44, 0x61, 0x74, 0x65, 0x52, 0x0b, 0x70, 0x75,
+	0x62, 0x6c, 0x69, 0x73, 0x68, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x33, 0x0a, 0x15, 0x64, 0x65, 0x70,
+	0x72, 0x65, 0x63, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x70, 0x6f, 0x70, 0x75, 0x6c, 0x61, 0x72, 0x69,
+	0x74, 0x79, 0x18, 0x43, 0x20, 0x01, 0x28, 0x11, 0x52, 0x14, 0x64, 0x65, 0x70, 0x72, 0x65, 0x63,
+	0x61, 0x74, 0x65, 0x64, 0x50, 0x6f, 0x70, 0x75, 0x6c, 0x61, 0x72, 0x69, 0x74, 0x79, 0x12, 0x2b,
+	0x0a, 0x06, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x73, 0x18, 0x44, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13,
+	0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x47, 0x72,
+	0x6f, 0x75, 0x70, 0x52, 0x06, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x6c,
+	0x61, 0x6e, 0x67, 0x75, 0x61, 0x67, 0x65, 0x18, 0x45, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c,
+	0x61, 0x6e, 0x67, 0x75, 0x61, 0x67, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x70, 0x6c, 0x69,
+	0x63, 0x69, 0x74, 0x18, 0x46, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x65, 0x78, 0x70, 0x6c, 0x69,
+	0x63, 0x69, 0x74, 0x12, 0x21, 0x0a, 0x04, 0x73, 0x68, 0x6f, 0x77, 0x18, 0x47, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0d, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x53, 0x68, 0x6f, 0x77,
+	0x52, 0x04, 0x73, 0x68, 0x6f, 0x77, 0x12, 0x37, 0x0a, 0x0d, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x5f,
+	0x70, 0x72, 0x65, 0x76, 0x69, 0x65, 0x77, 0x18, 0x4a, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e,
+	0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x41, 0x75, 0x64, 0x69, 0x6f, 0x46, 0x69, 0x6c,
+	0x65, 0x52, 0x0c, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x50, 0x72, 0x65, 0x76, 0x69, 0x65, 0x77, 0x12,
+	0x36, 0x0a, 0x0b, 0x72, 0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x4b,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x52,
+	0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x72, 0x65, 0x73, 0x74,
+	0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x6b, 0x65, 0x79, 0x77, 0x6f,
+	0x72, 0x64, 0x18, 0x4d, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x6b, 0x65, 0x79, 0x77, 0x6f, 0x72,
+	0x64, 0x12, 0x21, 0x0a, 0x0c, 0x65, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x5f, 0x75, 0x72,
+	0x6c, 0x18, 0x53, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x65, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61,
+	0x6c, 0x55, 0x72, 0x6c, 0x22, 0x57, 0x0a, 0x08, 0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x37, 0x0a, 0x0d, 0x73, 0x75, 0x62, 0x63, 0x61, 0x74, 0x65, 0x67,
+	0x6f, 0x72, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x53, 0x70,
+	0x6f, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x52, 0x0d,
+	0x73, 0x75, 0x62, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x69, 0x65, 0x73, 0x42, 0x2a, 0x5a,
+	0x28, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x61, 0x72, 0x63, 0x73,
+	0x70, 0x61, 0x63, 0x65, 0x2f, 0x67, 0x6f, 0x2d, 0x6c, 0x69, 0x62, 0x72, 0x65, 0x73, 0x70, 0x6f,
+	0x74, 0x2f, 0x53, 0x70, 0x6f, 0x74, 0x69, 0x66, 0x79,
 }
 
 var (
@@ -1879,81 +2478,100 @@ func file_metadata_proto_rawDescGZIP() []byte {
 	return file_metadata_proto_rawDescData
 }
 
-var file_metadata_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
-var file_metadata_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_metadata_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_metadata_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_metadata_proto_goTypes = []interface{}{
-	(Album_Type)(0),        // 0: Spotify.Album.Type
-	(Image_Size)(0),        // 1: Spotify.Image.Size
-	(Copyright_Type)(0),    // 2: Spotify.Copyright.Type
-	(Restriction_Type)(0),  // 3: Spotify.Restriction.Type
-	(AudioFile_Format)(0),  // 4: Spotify.AudioFile.Format
-	(*TopTracks)(nil),      // 5: Spotify.TopTracks
-	(*ActivityPeriod)(nil), // 6: Spotify.ActivityPeriod
-	(*Artist)(nil),         // 7: Spotify.Artist
-	(*AlbumGroup)(nil),     // 8: Spotify.AlbumGroup
-	(*Date)(nil),           // 9: Spotify.Date
-	(*Album)(nil),          // 10: Spotify.Album
-	(*Track)(nil),          // 11: Spotify.Track
-	(*Image)(nil),          // 12: Spotify.Image
-	(*ImageGroup)(nil),     // 13: Spotify.ImageGroup
-	(*Biography)(nil),      // 14: Spotify.Biography
-	(*Disc)(nil),           // 15: Spotify.Disc
-	(*Copyright)(nil),      // 16: Spotify.Copyright
-	(*Restriction)(nil),    // 17: Spotify.Restriction
-	(*SalePeriod)(nil),     // 18: Spotify.SalePeriod
-	(*ExternalId)(nil),     // 19: Spotify.ExternalId
-	(*AudioFile)(nil),      // 20: Spotify.AudioFile
+	(Album_Type)(0),            // 0: Spotify.Album.Type
+	(Image_Size)(0),            // 1: Spotify.Image.Size
+	(Copyright_Type)(0),        // 2: Spotify.Copyright.Type
+	(Restriction_Type)(0),      // 3: Spotify.Restriction.Type
+	(AudioFile_Format)(0),      // 4: Spotify.AudioFile.Format
+	(Show_MediaType)(0),        // 5: Spotify.Show.MediaType
+	(Show_ConsumptionOrder)(0), // 6: Spotify.Show.ConsumptionOrder
+	(*TopTracks)(nil),          // 7: Spotify.TopTracks
+	(*ActivityPeriod)(nil),     // 8: Spotify.ActivityPeriod
+	(*Artist)(nil),             // 9: Spotify.Artist
+	(*AlbumGroup)(nil),         // 10: Spotify.AlbumGroup
+	(*Date)(nil),               // 11: Spotify.Date
+	(*Album)(nil),              // 12: Spotify.Album
+	(*Track)(nil),              // 13: Spotify.Track
+	(*Image)(nil),              // 14: Spotify.Image
+	(*ImageGroup)(nil),         // 15: Spotify.ImageGroup
+	(*Biography)(nil),          // 16: Spotify.Biography
+	(*Disc)(nil),               // 17: Spotify.Disc
+	(*Copyright)(nil),          // 18: Spotify.Copyright
+	(*Restriction)(nil),        // 19: Spotify.Restriction
+	(*SalePeriod)(nil),         // 20: Spotify.SalePeriod
+	(*ExternalId)(nil),         // 21: Spotify.ExternalId
+	(*AudioFile)(nil),          // 22: Spotify.AudioFile
+	(*Show)(nil),               // 23: Spotify.Show
+	(*Episode)(nil),            // 24: Spotify.Episode
+	(*Category)(nil),           // 25: Spotify.Category
 }
 var file_metadata_proto_depIdxs = []int32{
-	11, // 0: Spotify.TopTracks.track:type_name -> Spotify.Track
-	5,  // 1: Spotify.Artist.top_track:type_name -> Spotify.TopTracks
-	8,  // 2: Spotify.Artist.album_group:type_name -> Spotify.AlbumGroup
-	8,  // 3: Spotify.Artist.single_group:type_name -> Spotify.AlbumGroup
-	8,  // 4: Spotify.Artist.compilation_group:type_name -> Spotify.AlbumGroup
-	8,  // 5: Spotify.Artist.appears_on_group:type_name -> Spotify.AlbumGroup
-	19, // 6: Spotify.Artist.external_id:type_name -> Spotify.ExternalId
-	12, // 7: Spotify.Artist.portrait:type_name -> Spotify.Image
-	14, // 8: Spotify.Artist.biography:type_name -> Spotify.Biography
-	6,  // 9: Spotify.Artist.activity_period:type_name -> Spotify.ActivityPeriod
-	17, // 10: Spotify.Artist.restriction:type_name -> Spotify.Restriction
-	7,  // 11: Spotify.Artist.related:type_name -> Spotify.Artist
-	13, // 12: Spotify.Artist.portrait_group:type_name -> Spotify.ImageGroup
-	10, // 13: Spotify.AlbumGroup.album:type_name -> Spotify.Album
-	7,  // 14: Spotify.Album.artist:type_name -> Spotify.Artist
+	13, // 0: Spotify.TopTracks.track:type_name -> Spotify.Track
+	7,  // 1: Spotify.Artist.top_track:type_name -> Spotify.TopTracks
+	10, // 2: Spotify.Artist.album_group:type_name -> Spotify.AlbumGroup
+	10, // 3: Spotify.Artist.single_group:type_name -> Spotify.AlbumGroup
+	10, // 4: Spotify.Artist.compilation_group:type_name -> Spotify.AlbumGroup
+	10, // 5: Spotify.Artist.appears_on_group:type_name -> Spotify.AlbumGroup
+	21, // 6: Spotify.Artist.external_id:type_name -> Spotify.ExternalId
+	14, // 7: Spotify.Artist.portrait:type_name -> Spotify.Image
+	16, // 8: Spotify.Artist.biography:type_name -> Spotify.Biography
+	8,  // 9: Spotify.Artist.activity_period:type_name -> Spotify.ActivityPeriod
+	19, // 10: Spotify.Artist.restriction:type_name -> Spotify.Restriction
+	9,  // 11: Spotify.Artist.related:type_name -> Spotify.Artist
+	15, // 12: Spotify.Artist.portrait_group:type_name -> Spotify.ImageGroup
+	12, // 13: Spotify.AlbumGroup.album:type_name -> Spotify.Album
+	9,  // 14: Spotify.Album.artist:type_name -> Spotify.Artist
 	0,  // 15: Spotify.Album.typ:type_name -> Spotify.Album.Type
-	9,  // 16: Spotify.Album.date:type_name -> Spotify.Date
-	12, // 17: Spotify.Album.cover:type_name -> Spotify.Image
-	19, // 18: Spotify.Album.external_id:type_name -> Spotify.ExternalId
-	15, // 19: Spotify.Album.disc:type_name -> Spotify.Disc
-	16, // 20: Spotify.Album.copyright:type_name -> Spotify.Copyright
-	17, // 21: Spotify.Album.restriction:type_name -> Spotify.Restriction
-	10, // 22: Spotify.Album.related:type_name -> Spotify.Album
-	18, // 23: Spotify.Album.sale_period:type_name -> Spotify.SalePeriod
-	13, // 24: Spotify.Album.cover_group:type_name -> Spotify.ImageGroup
-	10, // 25: Spotify.Track.album:type_name -> Spotify.Album
-	7,  // 26: Spotify.Track.artist:type_name -> Spotify.Artist
-	19, // 27: Spotify.Track.external_id:type_name -> Spotify.ExternalId
-	17, // 28: Spotify.Track.restriction:type_name -> Spotify.Restriction
-	20, // 29: Spotify.Track.file:type_name -> Spotify.AudioFile
-	11, // 30: Spotify.Track.alternative:type_name -> Spotify.Track
-	18, // 31: Spotify.Track.sale_period:type_name -> Spotify.SalePeriod
-	20, // 32: Spotify.Track.preview:type_name -> Spotify.AudioFile
+	11, // 16: Spotify.Album.date:type_name -> Spotify.Date
+	14, // 17: Spotify.Album.cover:type_name -> Spotify.Image
+	21, // 18: Spotify.Album.external_id:type_name -> Spotify.ExternalId
+	17, // 19: Spotify.Album.disc:type_name -> Spotify.Disc
+	18, // 20: Spotify.Album.copyright:type_name -> Spotify.Copyright
+	19, // 21: Spotify.Album.restriction:type_name -> Spotify.Restriction
+	12, // 22: Spotify.Album.related:type_name -> Spotify.Album
+	20, // 23: Spotify.Album.sale_period:type_name -> Spotify.SalePeriod
+	15, // 24: Spotify.Album.cover_group:type_name -> Spotify.ImageGroup
+	12, // 25: Spotify.Track.album:type_name -> Spotify.Album
+	9,  // 26: Spotify.Track.artist:type_name -> Spotify.Artist
+	21, // 27: Spotify.Track.external_id:type_name -> Spotify.ExternalId
+	19, // 28: Spotify.Track.restriction:type_name -> Spotify.Restriction
+	22, // 29: Spotify.Track.file:type_name -> Spotify.AudioFile
+	13, // 30: Spotify.Track.alternative:type_name -> Spotify.Track
+	20, // 31: Spotify.Track.sale_period:type_name -> Spotify.SalePeriod
+	22, // 32: Spotify.Track.preview:type_name -> Spotify.AudioFile
 	1,  // 33: Spotify.Image.size:type_name -> Spotify.Image.Size
-	12, // 34: Spotify.ImageGroup.image:type_name -> Spotify.Image
-	12, // 35: Spotify.Biography.portrait:type_name -> Spotify.Image
-	13, // 36: Spotify.Biography.portrait_group:type_name -> Spotify.ImageGroup
-	11, // 37: Spotify.Disc.track:type_name -> Spotify.Track
+	14, // 34: Spotify.ImageGroup.image:type_name -> Spotify.Image
+	14, // 35: Spotify.Biography.portrait:type_name -> Spotify.Image
+	15, // 36: Spotify.Biography.portrait_group:type_name -> Spotify.ImageGroup
+	13, // 37: Spotify.Disc.track:type_name -> Spotify.Track
 	2,  // 38: Spotify.Copyright.typ:type_name -> Spotify.Copyright.Type
 	3,  // 39: Spotify.Restriction.typ:type_name -> Spotify.Restriction.Type
-	17, // 40: Spotify.SalePeriod.restriction:type_name -> Spotify.Restriction
-	9,  // 41: Spotify.SalePeriod.start:type_name -> Spotify.Date
-	9,  // 42: Spotify.SalePeriod.end:type_name -> Spotify.Date
+	19, // 40: Spotify.SalePeriod.restriction:type_name -> Spotify.Restriction
+	11, // 41: Spotify.SalePeriod.start:type_name -> Spotify.Date
+	11, // 42: Spotify.SalePeriod.end:type_name -> Spotify.Date
 	4,  // 43: Spotify.AudioFile.format:type_name -> Spotify.AudioFile.Format
-	44, // [44:44] is the sub-list for method output_type
-	44, // [44:44] is the sub-list for method input_type
-	44, // [44:44] is the sub-list for extension type_name
-	44, // [44:44] is the sub-list for extension extendee
-	0,  // [0:44] is the sub-list for field type_name
+	15, // 44: Spotify.Show.cover_image:type_name -> Spotify.ImageGroup
+	24, // 45: Spotify.Show.episode:type_name -> Spotify.Episode
+	18, // 46: Spotify.Show.copyright:type_name -> Spotify.Copyright
+	19, // 47: Spotify.Show.restriction:type_name -> Spotify.Restriction
+	5,  // 48: Spotify.Show.media_type:type_name -> Spotify.Show.MediaType
+	6,  // 49: Spotify.Show.consumption_order:type_name -> Spotify.Show.ConsumptionOrder
+	25, // 50: Spotify.Show.categories:type_name -> Spotify.Category
+	22, // 51: Spotify.Episode.audio:type_name -> Spotify.AudioFile
+	11, // 52: Spotify.Episode.publish_time:type_name -> Spotify.Date
+	15, // 53: Spotify.Episode.covers:type_name -> Spotify.ImageGroup
+	23, // 54: Spotify.Episode.show:type_name -> Spotify.Show
+	22, // 55: Spotify.Episode.audio_preview:type_name -> Spotify.AudioFile
+	19, // 56: Spotify.Episode.restriction:type_name -> Spotify.Restriction
+	25, // 57: Spotify.Category.subcategories:type_name -> Spotify.Category
+	58, // [58:58] is the sub-list for method output_type
+	58, // [58:58] is the sub-list for method input_type
+	58, // [58:58] is the sub-list for extension type_name
+	58, // [58:58] is the sub-list for extension extendee
+	0,  // [0:58] is the sub-list for field type_name
 }
 
 func init() { file_metadata_proto_init() }
@@ -2154,14 +2772,50 @@ func file_metadata_proto_init() {
 				return nil
 			}
 		}
+		file_metadata_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Show); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_metadata_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Episode); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_metadata_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Category); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_metadata_proto_rawDesc,
-			NumEnums:      5,
-			NumMessages:   16,
+			NumEnums:      7,
+			NumMessages:   19,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
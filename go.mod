@@ -10,13 +10,14 @@ require (
 	github.com/arcspace/go-cedar v1.2023.1
 	github.com/badfortrains/mdns v0.0.0-20160325001438-447166384f51
 	github.com/golang/protobuf v1.5.3
+	github.com/h2non/filetype v1.1.3
+	github.com/jfreymuth/vorbis v1.0.2
 	golang.org/x/crypto v0.8.0
 	google.golang.org/protobuf v1.30.0
 )
 
 require (
 	github.com/brynbellomy/klog v0.0.0-20200414031930-87fbf2e555ae // indirect
-	github.com/h2non/filetype v1.1.3 // indirect
 	github.com/miekg/dns v1.1.54 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/rs/cors v1.9.0 // indirect
@@ -2,16 +2,31 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
 	"github.com/arcspace/go-librespot/pkg/respot"
-	"github.com/arcspace/go-librespot/pkg/utils"
+	"github.com/arcspace/go-librespot/pkg/respot/atomicfile"
+	"github.com/arcspace/go-librespot/pkg/respot/audio"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/channel"
+	"github.com/arcspace/go-librespot/pkg/respot/download"
+	"github.com/arcspace/go-librespot/pkg/respot/export"
+	"github.com/arcspace/go-librespot/pkg/respot/images"
+	"github.com/arcspace/go-librespot/pkg/respot/metajson"
+	"github.com/arcspace/go-librespot/pkg/respot/metastore"
+	"github.com/arcspace/go-librespot/pkg/respot/ogg"
+	"github.com/arcspace/go-librespot/pkg/respot/stream"
 )
 
 const (
@@ -19,6 +34,30 @@ const (
 	defaultDeviceName = "librespot"
 )
 
+var (
+	// Indexes the ISRCs and UPCs of everything fetched during this run
+	extIndex = catalog.NewExternalIndex()
+
+	// Optional on-disk metadata cache shared across runs
+	metaStore *metastore.Store
+
+	// Optional on-disk cache of encrypted audio and audio keys shared across runs
+	audioCache *stream.Cache
+
+	// Audio formats used by play unless overridden per call
+	formatPolicy = catalog.PolicyDefault()
+
+	// The account's catalogue ("premium" or "free"), or empty if the session does not report it
+	accountCatalogue string
+
+	// Where play writes audio files, and how they are named
+	outDir       string
+	nameTemplate string
+
+	// Where play decodes audio to, if anywhere (see -decode)
+	decodeTo string
+)
+
 func main() {
 
 	err := main_loop()
@@ -36,8 +75,50 @@ func main_loop() error {
 	password := flag.String("password", "", "spotify password")
 	blobPath := flag.String("blob", "blob.bin", "spotify auth blob")
 	devicename := flag.String("devicename", defaultDeviceName, "name of device")
+	metaPath := flag.String("metacache", "", "file to cache track, album, artist and playlist metadata in")
+	extIndexPath := flag.String("extindex", "", "file to keep the ISRC and UPC index in across runs")
+	cacheDir := flag.String("cachedir", "", "directory to cache encrypted audio and audio keys in")
+	quality := flag.String("quality", "default", "audio formats to prefer: default, low, archive or a list such as OGG_VORBIS_320,OGG_VORBIS_160")
+	premium := flag.String("premium", "auto", "whether the account can stream 320 kbps formats: auto (from the account's product type), true or false")
+	flag.StringVar(&outDir, "outdir", ".", "directory play writes audio files to")
+	flag.StringVar(&nameTemplate, "template", export.DefaultTemplate, "filename template for audio files, e.g. {{.AlbumArtist}}/{{.Album}}/{{.Track}} {{.Title}}.ogg")
+	flag.StringVar(&decodeTo, "decode", "", "after play, decode to PCM: \"wav\" for a WAV file, or a path (file or FIFO) for raw 16-bit PCM")
 	flag.Parse()
 
+	var err error
+	if _, err = catalog.ParseFormatPolicy(*quality, false); err != nil {
+		return err
+	}
+	switch *premium {
+	case "auto", "true", "false":
+	default:
+		return errors.Errorf("-premium must be auto, true or false, not %q", *premium)
+	}
+
+	if *metaPath != "" {
+		store, err := metastore.Open(*metaPath, metastore.Opts{MaxBytes: 256 << 20})
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		metaStore = store
+	}
+
+	if *extIndexPath != "" {
+		if err = loadExtIndex(*extIndexPath); err != nil {
+			return err
+		}
+	}
+
+	if *cacheDir != "" {
+		cache, err := stream.OpenCache(*cacheDir, stream.CacheOpts{})
+		if err != nil {
+			return err
+		}
+		defer cache.Close()
+		audioCache = cache
+	}
+
 	opts := respot.SessionOpts{
 		DeviceName: *devicename,
 		//Context: host,
@@ -80,121 +161,240 @@ func main_loop() error {
 		return err
 	}
 
+	logMissingFeatures(sess)
+
+	// Premium formats follow the account's product type unless -premium says otherwise
+	isPremium := false
+	if ps, ok := interface{}(sess).(productSession); ok {
+		product := ps.ProductType()
+		isPremium = catalog.IsPremiumProduct(product)
+		accountCatalogue = catalog.ProductCatalogue(product)
+	}
+	switch *premium {
+	case "true":
+		isPremium = true
+	case "false":
+		isPremium = false
+	}
+	if formatPolicy, err = catalog.ParseFormatPolicy(*quality, isPremium); err != nil {
+		return err
+	}
+
 	// Command loop
 	reader := bufio.NewReader(os.Stdin)
 
 	printHelp()
 
 	for {
-		fmt.Print("> ")
-		text, _ := reader.ReadString('\n')
+		// The prompt goes to stderr so that --json leaves stdout to the JSON
+		fmt.Fprint(os.Stderr, "> ")
+		text, readErr := reader.ReadString('\n')
+		if readErr == io.EOF && text == "" {
+			return nil
+		}
 		cmds := strings.Split(strings.TrimSpace(text), " ")
 
+		// Any command may be suffixed with --json to print machine-readable output
+		asJSON := false
+		if n := len(cmds); n > 1 && cmds[n-1] == "--json" {
+			asJSON = true
+			cmds = cmds[:n-1]
+		}
+		diag := diagOutput(asJSON)
+
 		switch cmds[0] {
 		case "help":
 			printHelp()
 
 		case "track":
 			if len(cmds) < 2 {
-				fmt.Println("You must specify the Base62 Spotify ID of the track")
+				fmt.Fprintln(diag, "You must specify the Spotify ID or URI of the track")
 			} else {
-				funcTrack(sess, cmds[1])
+				funcTrack(sess, cmds[1], asJSON)
 			}
 
 		case "artist":
 			if len(cmds) < 2 {
-				fmt.Println("You must specify the Base62 Spotify ID of the artist")
+				fmt.Fprintln(diag, "You must specify the Spotify ID or URI of the artist")
 			} else {
-				funcArtist(sess, cmds[1])
+				funcArtist(sess, cmds[1], asJSON)
 			}
 
 		case "album":
 			if len(cmds) < 2 {
-				fmt.Println("You must specify the Base62 Spotify ID of the album")
+				fmt.Fprintln(diag, "You must specify the Spotify ID or URI of the album")
 			} else {
-				funcAlbum(sess, cmds[1])
+				funcAlbum(sess, cmds[1], asJSON)
+			}
+
+		case "episode":
+			if len(cmds) < 2 {
+				fmt.Fprintln(diag, "You must specify the Spotify ID or URI of the episode")
+			} else {
+				funcEpisode(sess, cmds[1])
+			}
+
+		case "isrc", "upc":
+			if len(cmds) < 2 {
+				fmt.Fprintf(diag, "You must specify the %s to look up\n", strings.ToUpper(cmds[0]))
+			} else {
+				funcExternalID(sess, cmds[0], cmds[1], asJSON)
 			}
 
 		case "playlists":
-			funcPlaylists(sess)
+			funcPlaylists(sess, asJSON)
 
 		case "search":
-			funcSearch(sess, cmds[1])
+			if len(cmds) < 2 {
+				fmt.Fprintln(diag, "You must specify a keyword to search for")
+			} else {
+				funcSearch(sess, cmds[1], asJSON)
+			}
 
 		case "play":
 			if len(cmds) < 2 {
-				fmt.Println("You must specify the Base62 Spotify ID of the track")
+				fmt.Fprintln(diag, "You must specify the Spotify ID or URI of the track")
+			} else {
+				policy := formatPolicy
+				if len(cmds) > 2 {
+					if policy, err = catalog.ParseFormatPolicy(cmds[2], formatPolicy.Premium); err != nil {
+						fmt.Println(err)
+						continue
+					}
+				}
+				funcPlay(sess, cmds[1], policy)
+			}
+
+		case "preview":
+			if len(cmds) < 2 {
+				fmt.Fprintln(diag, "You must specify the Spotify ID or URI of the track")
+			} else {
+				funcPreview(sess, cmds[1])
+			}
+
+		case "download":
+			if len(cmds) < 2 {
+				fmt.Fprintln(diag, "You must specify the Spotify ID or URI of an album, playlist, artist or track")
 			} else {
-				funcPlay(sess, cmds[1])
+				funcDownload(sess, cmds[1])
 			}
 
 		default:
-			fmt.Println("Unknown command")
+			fmt.Fprintln(diag, "Unknown command")
+		}
+
+		// Save after every command so the index survives the process being killed
+		if *extIndexPath != "" {
+			if err = saveExtIndex(*extIndexPath); err != nil {
+				fmt.Fprintln(diag, "Error saving external id index:", err)
+			}
 		}
 	}
 }
 
 func printHelp() {
 	fmt.Println("\nAvailable commands:")
-	fmt.Println("play <track>:                   play specified track by spotify base62 id")
-	fmt.Println("track <track>:                  show details on specified track by spotify base62 id")
-	fmt.Println("album <album>:                  show details on specified album by spotify base62 id")
-	fmt.Println("artist <artist>:                show details on specified artist by spotify base62 id")
+	fmt.Println("play <track> [quality]:         play specified track by spotify base62 id, uri or url")
+	fmt.Println("preview <track>:                save the 30-second preview clip of a track (no premium needed)")
+	fmt.Println("download <id>:                  download every track of an album, playlist or artist; resumes if interrupted")
+	fmt.Println("track <track>:                  show details on specified track by spotify base62 id, uri or url")
+	fmt.Println("album <album>:                  show details on specified album by spotify base62 id, uri or url")
+	fmt.Println("artist <artist>:                show details on specified artist by spotify base62 id, uri or url")
 	fmt.Println("search <keyword>:               start a search on the specified keyword")
+	fmt.Println("episode <episode>:              show details on specified podcast episode by spotify base62 id, uri or url")
+	fmt.Println("isrc <code>:                    find tracks by ISRC")
+	fmt.Println("upc <code>:                     find albums by UPC")
 	fmt.Println("playlists:                      show your playlists")
 	fmt.Println("help:                           show this help")
+	fmt.Println("\nAppend --json to track, album, artist, search or playlists for JSON (NDJSON) output.")
 }
 
-func funcTrack(session *respot.Session, trackID string) {
-	fmt.Println("Loading track: ", trackID)
+func funcTrack(session *respot.Session, trackID string, asJSON bool) {
+	diag := diagOutput(asJSON)
+	if !asJSON {
+		fmt.Println("Loading track: ", trackID)
+	}
 
-	track, err := session.Mercury().GetTrack(utils.Base62ToHex(trackID))
+	id, err := catalog.ParseIDAs(catalog.KindTrack, trackID)
 	if err != nil {
-		fmt.Println("Error loading track: ", err)
+		fmt.Fprintln(diag, "Invalid track ID: ", err)
+		return
+	}
+
+	track, err := newSource(session).GetTrack(id)
+	if err != nil {
+		fmt.Fprintln(diag, "Error loading track: ", err)
+		return
+	}
+
+	if asJSON {
+		printJSON(metajson.FromTrack(track))
 		return
 	}
 
 	fmt.Println("Track title: ", track.GetName())
 }
 
-func funcArtist(session *respot.Session, artistID string) {
-	artist, err := session.Mercury().GetArtist(utils.Base62ToHex(artistID))
+func funcArtist(session *respot.Session, artistID string, asJSON bool) {
+	diag := diagOutput(asJSON)
+	id, err := catalog.ParseIDAs(catalog.KindArtist, artistID)
+	if err != nil {
+		fmt.Fprintln(diag, "Invalid artist ID:", err)
+		return
+	}
+
+	graph, err := catalog.HydrateArtist(newSource(session), id, catalog.HydrateOpts{})
 	if err != nil {
-		fmt.Println("Error loading artist:", err)
+		fmt.Fprintln(diag, "Error loading artist:", err)
+		return
+	}
+	artist := graph.Artists[id]
+
+	if asJSON {
+		printJSON(metajson.FromArtist(artist, graph, session.Country))
 		return
 	}
 
 	fmt.Printf("Artist: %s\n", artist.GetName())
 	fmt.Printf("Popularity: %.0f\n", artist.GetPopularity())
 	fmt.Printf("Genre: %s\n", artist.GetGenre())
+	if portrait := images.Best(images.ArtistImages(artist), 640); portrait != nil {
+		fmt.Printf("Portrait: %s\n", images.URL(portrait))
+	}
 
-	if artist.GetTopTrack() != nil && len(artist.GetTopTrack()) > 0 {
-		// Spotify returns top tracks in multiple countries. We take the first
-		// one as example, but we should use the country data returned by the
-		// Spotify server (session.Country())
-		tt := artist.GetTopTrack()[0]
-		fmt.Printf("\nTop tracks (country %s):\n", tt.GetCountry())
-
-		for _, t := range tt.GetTrack() {
-			// To save bandwidth, only track IDs are returned. If you want
-			// the track name, you need to fetch it.
-			fmt.Printf(" => %s\n", utils.ConvertTo62(t.GetGid()))
+	if len(artist.GetTopTrack()) > 0 {
+		fmt.Printf("\nTop tracks (country %s):\n", session.Country)
+		for _, t := range graph.ArtistTopTracks(artist, session.Country) {
+			trackID, _ := catalog.FromGID(catalog.KindTrack, t.GetGid())
+			fmt.Printf(" => %s (%s)\n", t.GetName(), trackID)
 		}
 	}
 
 	fmt.Printf("\nAlbums:\n")
-	for _, ag := range artist.GetAlbumGroup() {
-		for _, a := range ag.GetAlbum() {
-			fmt.Printf(" => %s\n", utils.ConvertTo62(a.GetGid()))
-		}
+	for _, a := range graph.ArtistAlbums(artist) {
+		albumID, _ := catalog.FromGID(catalog.KindAlbum, a.GetGid())
+		fmt.Printf(" => %s (%s)\n", a.GetName(), albumID)
 	}
 
 }
 
-func funcAlbum(session *respot.Session, albumID string) {
-	album, err := session.Mercury().GetAlbum(utils.Base62ToHex(albumID))
+func funcAlbum(session *respot.Session, albumID string, asJSON bool) {
+	diag := diagOutput(asJSON)
+	id, err := catalog.ParseIDAs(catalog.KindAlbum, albumID)
+	if err != nil {
+		fmt.Fprintln(diag, "Invalid album ID:", err)
+		return
+	}
+
+	graph, err := catalog.HydrateAlbum(newSource(session), id, catalog.HydrateOpts{})
 	if err != nil {
-		fmt.Println("Error loading album:", err)
+		fmt.Fprintln(diag, "Error loading album:", err)
+		return
+	}
+	album := graph.Albums[id]
+
+	if asJSON {
+		printJSON(metajson.FromAlbum(album, graph))
 		return
 	}
 
@@ -204,38 +404,119 @@ func funcAlbum(session *respot.Session, albumID string) {
 	fmt.Printf("Date: %d-%d-%d\n", album.GetDate().GetYear(), album.GetDate().GetMonth(), album.GetDate().GetDay())
 	fmt.Printf("Label: %s\n", album.GetLabel())
 	fmt.Printf("Type: %s\n", album.GetTyp())
+	if cover := images.Best(images.AlbumImages(album), 640); cover != nil {
+		fmt.Printf("Cover: %s\n", images.URL(cover))
+	}
 
 	fmt.Printf("Artists: ")
-	for _, artist := range album.GetArtist() {
-		fmt.Printf("%s ", utils.ConvertTo62(artist.GetGid()))
+	for _, stub := range album.GetArtist() {
+		if artist := graph.Artist(stub); artist != nil {
+			fmt.Printf("%s ", artist.GetName())
+		}
 	}
 	fmt.Printf("\n")
 
 	for _, disc := range album.GetDisc() {
 		fmt.Printf("\nDisc %d (%s): \n", disc.GetNumber(), disc.GetName())
 
-		for _, track := range disc.GetTrack() {
-			fmt.Printf(" => %s\n", utils.ConvertTo62(track.GetGid()))
+		for _, stub := range disc.GetTrack() {
+			trackID, _ := catalog.FromGID(catalog.KindTrack, stub.GetGid())
+			fmt.Printf(" => %s (%s)\n", graph.Track(stub).GetName(), trackID)
 		}
 	}
 
 }
 
-func funcPlaylists(session *respot.Session) {
-	fmt.Println("Listing playlists")
+func funcEpisode(session *respot.Session, episodeID string) {
+	id, err := catalog.ParseIDAs(catalog.KindEpisode, episodeID)
+	if err != nil {
+		fmt.Println("Invalid episode ID:", err)
+		return
+	}
+
+	episode, err := newSource(session).(catalog.PodcastSource).GetEpisode(id)
+	if err != nil {
+		fmt.Println("Error loading episode:", err)
+		return
+	}
+
+	fmt.Printf("Episode: %s\n", episode.GetName())
+	fmt.Printf("Show: %s\n", episode.GetShow().GetName())
+	fmt.Printf("Duration: %s\n", time.Duration(episode.GetDuration())*time.Millisecond)
+	fmt.Printf("Audio files: %d\n", len(episode.GetAudio()))
+	fmt.Printf("\n%s\n", episode.GetDescription())
+}
+
+func funcExternalID(session *respot.Session, typ, code string, asJSON bool) {
+	diag := diagOutput(asJSON)
+	finder := catalog.ExternalFinder{
+		Index:  extIndex,
+		Source: newSource(session),
+		Search: searchFunc(session),
+	}
+
+	if typ == catalog.ExtISRC {
+		tracks, err := finder.FindByISRC(code)
+		if err != nil {
+			fmt.Fprintln(diag, "Error finding ISRC:", err)
+			return
+		}
+		for _, t := range tracks {
+			if asJSON {
+				printJSON(metajson.FromTrack(t))
+			} else {
+				trackID, _ := catalog.FromGID(catalog.KindTrack, t.GetGid())
+				fmt.Printf(" => %s (%s)\n", t.GetName(), trackID)
+			}
+		}
+		return
+	}
+
+	albums, err := finder.FindByUPC(code)
+	if err != nil {
+		fmt.Fprintln(diag, "Error finding UPC:", err)
+		return
+	}
+	for _, a := range albums {
+		if asJSON {
+			printJSON(metajson.FromAlbum(a, nil))
+		} else {
+			albumID, _ := catalog.FromGID(catalog.KindAlbum, a.GetGid())
+			fmt.Printf(" => %s (%s)\n", a.GetName(), albumID)
+		}
+	}
+}
+
+func funcPlaylists(session *respot.Session, asJSON bool) {
+	diag := diagOutput(asJSON)
+	if !asJSON {
+		fmt.Println("Listing playlists")
+	}
 
 	playlist, err := session.Mercury().GetRootPlaylist(session.Username)
 
 	if err != nil || playlist.Contents == nil {
-		fmt.Println("Error getting root list: ", err)
+		fmt.Fprintln(diag, "Error getting root list: ", err)
 		return
 	}
 
+	src := newSource(session)
 	items := playlist.Contents.Items
 	for i := 0; i < len(items); i++ {
-		id := strings.TrimPrefix(items[i].GetUri(), "spotify:")
-		id = strings.Replace(id, ":", "/", -1)
-		list, _ := session.Mercury().GetPlaylist(id)
+		id, err := catalog.ParseIDAs(catalog.KindPlaylist, items[i].GetUri())
+		if err != nil {
+			fmt.Fprintln(diag, "Skipping", items[i].GetUri())
+			continue
+		}
+		list, err := src.GetPlaylist(id)
+		if err != nil {
+			fmt.Fprintln(diag, "Error loading playlist", id, err)
+			continue
+		}
+		if asJSON {
+			printJSON(metajson.FromPlaylist(id, list))
+			continue
+		}
 		fmt.Println(list.Attributes.GetName(), id)
 
 		if list.Contents != nil {
@@ -247,21 +528,46 @@ func funcPlaylists(session *respot.Session) {
 	}
 }
 
-func funcSearch(session *respot.Session, keyword string) {
+func funcSearch(session *respot.Session, keyword string, asJSON bool) {
+	diag := diagOutput(asJSON)
 	resp, err := session.Mercury().Search(keyword, 12, session.Country, session.Username)
 
 	if err != nil {
-		fmt.Println("Failed to search:", err)
+		fmt.Fprintln(diag, "Failed to search:", err)
 		return
 	}
 
 	res := resp.Results
 
+	if asJSON {
+		out := metajson.SearchResults{
+			Query:     keyword,
+			Albums:    metajson.SearchHits{Total: int(res.Albums.Total), Hits: []metajson.Ref{}},
+			Artists:   metajson.SearchHits{Total: int(res.Artists.Total), Hits: []metajson.Ref{}},
+			Tracks:    metajson.SearchHits{Total: int(res.Tracks.Total), Hits: []metajson.Ref{}},
+			Playlists: metajson.SearchHits{Total: int(res.Playlists.Total), Hits: []metajson.Ref{}},
+		}
+		for _, album := range res.Albums.Hits {
+			out.Albums.Hits = append(out.Albums.Hits, metajson.RefFromURI(album.Uri, album.Name))
+		}
+		for _, artist := range res.Artists.Hits {
+			out.Artists.Hits = append(out.Artists.Hits, metajson.RefFromURI(artist.Uri, artist.Name))
+		}
+		for _, track := range res.Tracks.Hits {
+			out.Tracks.Hits = append(out.Tracks.Hits, metajson.RefFromURI(track.Uri, track.Name))
+		}
+		for _, list := range res.Playlists.Hits {
+			out.Playlists.Hits = append(out.Playlists.Hits, metajson.RefFromURI(list.Uri, list.Name))
+		}
+		printJSON(out)
+		return
+	}
+
 	fmt.Println("Search results for ", keyword)
 	fmt.Println("=============================")
 
 	if res.Error != nil {
-		fmt.Println("Search result error:", res.Error)
+		fmt.Fprintln(diag, "Search result error:", res.Error)
 	}
 
 	fmt.Printf("Albums: %d (total %d)\n", len(res.Albums.Hits), res.Albums.Total)
@@ -281,14 +587,113 @@ func funcSearch(session *respot.Session, keyword string) {
 	for _, track := range res.Tracks.Hits {
 		fmt.Printf(" => %s (%s)\n", track.Name, track.Uri)
 	}
+
+	fmt.Printf("\nPlaylists: %d (total %d)\n", len(res.Playlists.Hits), res.Playlists.Total)
+
+	for _, list := range res.Playlists.Hits {
+		fmt.Printf(" => %s (%s)\n", list.Name, list.Uri)
+	}
 }
 
-func funcPlay(session *respot.Session, trackID string) {
+func funcPlay(session *respot.Session, trackID string, policy catalog.FormatPolicy) {
 	fmt.Println("Loading track for play: ", trackID)
 
-	asset, err := session.Downloader().PinTrack(trackID)
+	id, err := catalog.ParseIDAs(catalog.KindTrack, trackID)
+	if err != nil {
+		fmt.Println("Invalid track ID: ", err)
+		return
+	}
+
+	// Users in restricted markets get a relinked version of the track if there is one
+	src := newSource(session)
+	var track *Spotify.Track
+	var r io.ReadCloser
+	var label string
+	if pinner := newPinner(session, policy); pinner != nil {
+		asset, err := pinner.PinTrack(id.Base62())
+		if err != nil {
+			fmt.Printf("Error while loading track: %s\n", err)
+			return
+		}
+		track, label = asset.Track, asset.Label()
+		fmt.Printf("Format: %v (%x)\n", asset.Format(), asset.File.GetFileId())
+		if r, err = asset.NewAssetReader(); err != nil {
+			fmt.Printf("NewAssetReader: %s\n", err)
+			return
+		}
+	} else {
+		// The session can't stream a chosen file, so the downloader picks the format itself
+		track, err = catalog.ResolvePlayable(src, id, session.Country, accountCatalogue)
+		if err != nil {
+			fmt.Printf("Error while loading track: %s\n", err)
+			return
+		}
+		var file *Spotify.AudioFile
+		if track, file, err = policy.SelectFile(src, track, session.Country, accountCatalogue); err != nil {
+			fmt.Printf("Error while loading track: %s\n", err)
+			return
+		}
+		fmt.Printf("Format: %v (%x), if the downloader agrees\n", file.GetFormat(), file.GetFileId())
+		playID, _ := catalog.FromGID(catalog.KindTrack, track.GetGid())
+		asset, err := session.Downloader().PinTrack(playID.Base62())
+		if err != nil {
+			fmt.Printf("Error while loading track: %s\n", err)
+			return
+		}
+		label = asset.Label()
+		if r, err = asset.NewAssetReader(); err != nil {
+			fmt.Printf("NewAssetReader: %s\n", err)
+			return
+		}
+	}
+	defer r.Close()
+	if playID, _ := catalog.FromGID(catalog.KindTrack, track.GetGid()); playID != id {
+		fmt.Println("Relinked to: ", playID)
+	}
+
+	// Strip Spotify's proprietary header so the file is a standard Ogg stream
+	or, err := ogg.NewReader(r, ogg.ReaderOpts{StripHeader: true})
 	if err != nil {
-		fmt.Printf("Error while loading track: %s\n", err)
+		fmt.Printf("Error while reading file: %s\n", err)
+		return
+	}
+	if or.HasHeader {
+		norm := or.Normalization
+		fmt.Printf("Normalization: track %.2f dB (peak %.3f), album %.2f dB (peak %.3f)\n",
+			norm.TrackGainDB, norm.TrackPeak, norm.AlbumGainDB, norm.AlbumPeak)
+	}
+
+	buffer, err := ioutil.ReadAll(or)
+	if err != nil {
+		fmt.Printf("Error while reading file: %s\n", err)
+		return
+	}
+
+	path, err := exportTrack(src, track, buffer, label)
+	if err != nil {
+		fmt.Printf("Error while writing file: %s\n", err)
+		return
+	}
+	fmt.Println("Wrote", path)
+
+	if decodeTo != "" {
+		gain := float32(1)
+		if or.HasHeader {
+			gain = or.Normalization.Factor(false, 0)
+		}
+		if err = decodeAudio(buffer, gain, path); err != nil {
+			fmt.Printf("Error while decoding: %s\n", err)
+		}
+	}
+}
+
+func funcPreview(session *respot.Session, trackID string) {
+	fmt.Println("Loading preview: ", trackID)
+
+	previews := stream.Previews{Source: newSource(session)}
+	asset, err := previews.PinPreview(trackID)
+	if err != nil {
+		fmt.Printf("Error while loading preview: %s\n", err)
 		return
 	}
 	r, err := asset.NewAssetReader()
@@ -298,15 +703,268 @@ func funcPlay(session *respot.Session, trackID string) {
 	}
 	defer r.Close()
 
-	buffer, err := ioutil.ReadAll(r)
+	path := filepath.Join(outDir, asset.Label())
+	err = atomicfile.Write(path, 0, 0, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
 	if err != nil {
-		fmt.Printf("Error while reading file: %s\n", err)
+		fmt.Printf("Error while writing file: %s\n", err)
 		return
 	}
+	fmt.Println("Wrote", path)
+}
 
-	err = ioutil.WriteFile(asset.Label(), buffer, os.ModePerm)
+func funcDownload(session *respot.Session, idStr string) {
+	id, err := catalog.ParseID(idStr)
 	if err != nil {
-		fmt.Printf("Error while writing file: %s\n", err)
+		fmt.Println("Invalid ID:", err)
+		return
+	}
+
+	pinner := newPinner(session, formatPolicy)
+	fetch := func(track *Spotify.Track) (io.ReadCloser, error) {
+		if pinner != nil {
+			track, file, err := formatPolicy.SelectFile(pinner.Source, track, session.Country, accountCatalogue)
+			if err != nil {
+				return nil, err
+			}
+			asset, err := pinner.PinFile(track, file)
+			if err != nil {
+				return nil, err
+			}
+			return asset.NewAssetReader()
+		}
+		trackID, err := catalog.FromGID(catalog.KindTrack, track.GetGid())
+		if err != nil {
+			return nil, err
+		}
+		asset, err := session.Downloader().PinTrack(trackID.Base62())
+		if err != nil {
+			return nil, err
+		}
+		return asset.NewAssetReader()
+	}
+
+	mgr := download.New(newSource(session), fetch, download.Opts{
+		Dir:       outDir,
+		Template:  nameTemplate,
+		Country:   session.Country,
+		Catalogue: accountCatalogue,
+		OnEvent: func(ev download.Event) {
+			switch ev.Kind {
+			case download.EventDone:
+				fmt.Printf("[%d/%d] Wrote %s (ETA %v)\n", ev.Completed, ev.Total, ev.Path, ev.ETA.Round(time.Second))
+			case download.EventSkipped:
+				fmt.Printf("[%d/%d] Already have %s\n", ev.Completed, ev.Total, ev.Path)
+			case download.EventFailed:
+				fmt.Printf("[%d/%d] Failed %s: %v\n", ev.Completed, ev.Total, ev.Track, ev.Err)
+			}
+		},
+	})
+	summary, err := mgr.Download(id)
+	if err != nil {
+		fmt.Println("Error while downloading:", err)
 		return
 	}
+	fmt.Printf("Downloaded %d, skipped %d, failed %d of %d tracks (%d bytes)\n",
+		summary.Completed, summary.Skipped, len(summary.Failed), summary.Total, summary.Bytes)
+	if err := summary.Failed[id]; err != nil {
+		fmt.Println("Not everything could be downloaded:", err)
+	}
+}
+
+// exportTrack writes a tagged Ogg file with cover art named by -template under -outdir.
+// Audio that is not Ogg Vorbis is written untagged under its asset label.
+func exportTrack(src catalog.Source, track *Spotify.Track, data []byte, label string) (string, error) {
+	album := track.GetAlbum()
+	if albumID, err := catalog.FromGID(catalog.KindAlbum, album.GetGid()); err == nil {
+		if full, err := src.GetAlbum(albumID); err == nil {
+			album = full
+		}
+	}
+	opts := export.Opts{
+		Dir:      outDir,
+		Template: nameTemplate,
+	}
+	if cover, err := (&images.Fetcher{}).FetchBest(images.AlbumImages(album), 640); err == nil {
+		opts.Cover = cover
+	}
+
+	path, err := export.Export(bytes.NewReader(data), int64(len(data)), track, album, opts)
+	if err == nil {
+		return path, nil
+	}
+	if _, oggErr := ogg.NewDemuxer(bytes.NewReader(data), int64(len(data))); oggErr == nil {
+		return "", err
+	}
+	path = filepath.Join(outDir, label)
+	return path, atomicfile.Write(path, 0, 0, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// decodeAudio decodes an Ogg Vorbis file to a WAV file next to it or to raw s16le PCM, as selected by -decode.
+func decodeAudio(oggData []byte, gain float32, path string) error {
+	dec, err := audio.NewDecoder(bytes.NewReader(oggData), int64(len(oggData)))
+	if err != nil {
+		return err
+	}
+	dec.SetGain(gain)
+
+	var sink audio.Sink
+	var out *os.File
+	if decodeTo == "wav" {
+		if out, err = os.Create(strings.TrimSuffix(path, filepath.Ext(path)) + ".wav"); err != nil {
+			return err
+		}
+		sink = &audio.WAVSink{W: out}
+	} else {
+		// Raw PCM goes to a file or FIFO, e.g. one read by `aplay -f cd`.
+		// O_TRUNC keeps a longer earlier file from trailing the new audio; it
+		// has no effect on a FIFO.
+		if out, err = os.OpenFile(decodeTo, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644); err != nil {
+			return err
+		}
+		sink = &audio.PipeSink{W: out}
+	}
+	defer out.Close()
+
+	format := dec.Format()
+	fmt.Printf("Decoding %d Hz, %d channels to %s\n", format.SampleRate, format.Channels, out.Name())
+	_, err = audio.Copy(sink, dec)
+	return err
+}
+
+func printJSON(v interface{}) {
+	if err := metajson.NewEncoder(os.Stdout).Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, "Error encoding JSON:", err)
+	}
+}
+
+// diagOutput returns where progress and error messages go: stderr in JSON
+// mode, so that stdout carries nothing but JSON.
+func diagOutput(asJSON bool) io.Writer {
+	if asJSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// productSession is implemented by sessions that keep the product type
+// ("premium", "free", ...) the AP reports for the account after login.
+type productSession interface {
+	ProductType() string
+}
+
+// streamingSession is implemented by sessions that expose what stream.Pinner
+// needs to fetch a chosen audio file itself: an access token for
+// storage-resolve and the AP's audio keys.
+type streamingSession interface {
+	AccessToken() (string, error)
+	AudioKeys() stream.KeySource
+}
+
+// channelSession is implemented by sessions whose packet loop dispatches AP
+// channel traffic, so that audio can be streamed over the AP when the CDN fails.
+type channelSession interface {
+	Channels() *channel.Manager
+}
+
+// logMissingFeatures says which of the optional session interfaces above the
+// session lacks, since the fallbacks taken without them are otherwise silent.
+func logMissingFeatures(session *respot.Session) {
+	s := interface{}(session)
+	if _, ok := s.(productSession); !ok {
+		log.Println("Session does not report the product type; -premium=auto assumes a free account")
+	}
+	if _, ok := s.(streamingSession); !ok {
+		log.Println("Session cannot stream a chosen file; play and download leave the format to Downloader() and -cachedir is unused")
+	} else if _, ok := s.(channelSession); !ok {
+		log.Println("Session does not dispatch AP channels; audio is only fetched from the CDN")
+	}
+}
+
+// newPinner returns a Pinner over the session, or nil if the session can't
+// stream a chosen file and pinning is left to Downloader().
+func newPinner(session *respot.Session, policy catalog.FormatPolicy) *stream.Pinner {
+	ss, ok := interface{}(session).(streamingSession)
+	if !ok {
+		return nil
+	}
+	p := &stream.Pinner{
+		Resolver:  &stream.Resolver{Token: ss.AccessToken},
+		Keys:      ss.AudioKeys(),
+		Source:    newSource(session),
+		Country:   session.Country,
+		Catalogue: accountCatalogue,
+		Policy:    policy,
+		Cache:     audioCache,
+	}
+	if cs, ok := interface{}(session).(channelSession); ok {
+		p.Channels = cs.Channels()
+	}
+	return p
+}
+
+// loadExtIndex merges the index saved at path into extIndex; a missing file is an empty index
+func loadExtIndex(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return errors.Wrapf(extIndex.Load(f), "reading %s", path)
+}
+
+// saveExtIndex replaces the index saved at path with extIndex
+func saveExtIndex(path string) error {
+	return atomicfile.Write(path, 0, 0, extIndex.Save)
+}
+
+// newSource returns a catalog source over the session that reads through metaStore and feeds extIndex
+func newSource(session *respot.Session) catalog.Source {
+	src := catalog.NewSource(session.Mercury())
+	if metaStore != nil {
+		src = metastore.CachedSource(src, metaStore, 7*24*time.Hour)
+	}
+	return extIndex.IndexingSource(src)
+}
+
+// searchFunc adapts Mercury().Search for catalog lookups
+func searchFunc(session *respot.Session) catalog.SearchFunc {
+	return func(query string, kind catalog.Kind) ([]catalog.ID, error) {
+		resp, err := session.Mercury().Search(query, 12, session.Country, session.Username)
+		if err != nil {
+			return nil, err
+		}
+
+		var uris []string
+		switch kind {
+		case catalog.KindTrack:
+			for _, hit := range resp.Results.Tracks.Hits {
+				uris = append(uris, hit.Uri)
+			}
+		case catalog.KindAlbum:
+			for _, hit := range resp.Results.Albums.Hits {
+				uris = append(uris, hit.Uri)
+			}
+		case catalog.KindArtist:
+			for _, hit := range resp.Results.Artists.Hits {
+				uris = append(uris, hit.Uri)
+			}
+		}
+
+		var ids []catalog.ID
+		for _, uri := range uris {
+			if id, err := catalog.ParseIDAs(kind, uri); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
 }
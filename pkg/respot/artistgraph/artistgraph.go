@@ -0,0 +1,286 @@
+// Package artistgraph crawls Artist.related breadth-first into a graph that can
+// be exported as Graphviz DOT, GraphML or JSON, and resumed from a saved crawl.
+package artistgraph
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/atomicfile"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+)
+
+// Node is an artist in the graph.
+type Node struct {
+	ID         string   `json:"id"` // base62
+	Name       string   `json:"name"`
+	Popularity float32  `json:"popularity"`
+	Genres     []string `json:"genres,omitempty"`
+	Depth      int      `json:"depth"`    // hops from the nearest seed
+	Fetched    bool     `json:"fetched"`  // whether the artist itself has been fetched (Name may come from a related stub)
+	Expanded   bool     `json:"expanded"` // whether its related artists have been fetched
+	Error      string   `json:"error,omitempty"`
+}
+
+// Edge links an artist to one of its related artists.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the result (or in-progress state) of a crawl.
+type Graph struct {
+	Nodes map[string]*Node `json:"nodes"`
+	Edges []Edge           `json:"edges"`
+
+	edgeSet map[Edge]struct{}
+}
+
+// NewGraph returns an empty graph.
+func NewGraph() *Graph {
+	return &Graph{
+		Nodes:   make(map[string]*Node),
+		edgeSet: make(map[Edge]struct{}),
+	}
+}
+
+func (g *Graph) addEdge(e Edge) {
+	if g.edgeSet == nil {
+		g.edgeSet = make(map[Edge]struct{}, len(g.Edges))
+		for _, existing := range g.Edges {
+			g.edgeSet[existing] = struct{}{}
+		}
+	}
+	if _, dup := g.edgeSet[e]; dup {
+		return
+	}
+	g.edgeSet[e] = struct{}{}
+	g.Edges = append(g.Edges, e)
+}
+
+// SortedNodes returns the nodes ordered by depth, then ID.
+func (g *Graph) SortedNodes() []*Node {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Depth != nodes[j].Depth {
+			return nodes[i].Depth < nodes[j].Depth
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// SortedEdges returns the edges ordered by source, then target, so exports are
+// stable regardless of the order in which fetches completed.
+func (g *Graph) SortedEdges() []Edge {
+	edges := append([]Edge(nil), g.Edges...)
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// pending returns the nodes that are within depth but not yet expanded.
+func (g *Graph) pending(maxDepth int) []*Node {
+	var nodes []*Node
+	for _, n := range g.SortedNodes() {
+		if !n.Expanded && n.Error == "" && n.Depth <= maxDepth {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// Opts configures a Crawler.
+type Opts struct {
+	Depth      int    // max hops from the seeds to expand (default 2)
+	MaxNodes   int    // stop adding nodes beyond this many (default 500)
+	Workers    int    // max concurrent artist fetches (default 8)
+	Checkpoint string // if set, the graph is saved here after every level so a crawl can be resumed
+}
+
+// Crawler expands artists breadth-first through Artist.related.
+type Crawler struct {
+	src  catalog.Source
+	opts Opts
+}
+
+// NewCrawler returns a Crawler fetching artists from src.
+func NewCrawler(src catalog.Source, opts Opts) *Crawler {
+	if opts.Depth <= 0 {
+		opts.Depth = 2
+	}
+	if opts.MaxNodes <= 0 {
+		opts.MaxNodes = 500
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 8
+	}
+	return &Crawler{src: src, opts: opts}
+}
+
+// Crawl starts a new crawl from the given seed artists.
+func (c *Crawler) Crawl(seeds ...catalog.ID) (*Graph, error) {
+	g := NewGraph()
+	for _, seed := range seeds {
+		if seed.Kind() != catalog.KindArtist {
+			return nil, errors.Wrapf(catalog.ErrKindMismatch, "%v is not an artist", seed)
+		}
+		g.Nodes[seed.Base62()] = &Node{ID: seed.Base62()}
+	}
+	return g, c.Resume(g)
+}
+
+// Resume continues a crawl, expanding every node of g within depth that has
+// not been expanded yet.  Nodes whose fetch failed earlier are tried again.
+// g is updated in place.
+func (c *Crawler) Resume(g *Graph) error {
+	for _, n := range g.Nodes {
+		n.Error = ""
+	}
+	for {
+		level := g.pending(c.opts.Depth - 1)
+		if len(level) == 0 {
+			break
+		}
+		c.expand(g, level)
+		if err := c.checkpoint(g); err != nil {
+			return err
+		}
+	}
+
+	// Nodes at the outer edge are not expanded but still get their attributes filled in
+	var edge []*Node
+	for _, n := range g.SortedNodes() {
+		if n.Depth == c.opts.Depth && !n.Fetched && n.Error == "" {
+			edge = append(edge, n)
+		}
+	}
+	if len(edge) > 0 {
+		c.fetch(edge, func(n *Node, artist *Spotify.Artist) {
+			setAttrs(n, artist)
+		})
+	}
+	return c.checkpoint(g)
+}
+
+func (c *Crawler) checkpoint(g *Graph) error {
+	if c.opts.Checkpoint == "" {
+		return nil
+	}
+	return g.Save(c.opts.Checkpoint)
+}
+
+type fetched struct {
+	node   *Node
+	artist *Spotify.Artist
+	err    error
+}
+
+// fetch retrieves the artists of nodes with bounded concurrency, calling merge for each success from a single goroutine.
+func (c *Crawler) fetch(nodes []*Node, merge func(*Node, *Spotify.Artist)) {
+	work := make(chan *Node)
+	results := make(chan fetched)
+	wg := sync.WaitGroup{}
+	for i := 0; i < c.opts.Workers && i < len(nodes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range work {
+				id, err := catalog.FromBase62(catalog.KindArtist, n.ID)
+				var artist *Spotify.Artist
+				if err == nil {
+					artist, err = c.src.GetArtist(id)
+				}
+				results <- fetched{n, artist, err}
+			}
+		}()
+	}
+	go func() {
+		for _, n := range nodes {
+			work <- n
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+	for r := range results {
+		if r.err != nil {
+			r.node.Error = r.err.Error()
+			continue
+		}
+		merge(r.node, r.artist)
+	}
+}
+
+func (c *Crawler) expand(g *Graph, level []*Node) {
+	c.fetch(level, func(n *Node, artist *Spotify.Artist) {
+		setAttrs(n, artist)
+		n.Expanded = true
+		for _, rel := range artist.GetRelated() {
+			relID, err := catalog.FromGID(catalog.KindArtist, rel.GetGid())
+			if err != nil {
+				continue
+			}
+			key := relID.Base62()
+			if g.Nodes[key] == nil {
+				if len(g.Nodes) >= c.opts.MaxNodes {
+					continue
+				}
+				g.Nodes[key] = &Node{
+					ID:    key,
+					Name:  rel.GetName(),
+					Depth: n.Depth + 1,
+				}
+			}
+			g.addEdge(Edge{From: n.ID, To: key})
+		}
+	})
+}
+
+func setAttrs(n *Node, artist *Spotify.Artist) {
+	n.Name = artist.GetName()
+	n.Popularity = artist.GetPopularity()
+	n.Genres = artist.GetGenre()
+	n.Fetched = true
+	n.Error = ""
+}
+
+// Save writes the graph as JSON to path, atomically replacing any previous file.
+func (g *Graph) Save(path string) error {
+	err := atomicfile.Write(path, 0, 0, g.WriteJSON)
+	return errors.Wrap(err, "saving artist graph")
+}
+
+// Load reads a graph saved by Save (or written by WriteJSON).
+func Load(path string) (*Graph, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g := NewGraph()
+	if err = json.Unmarshal(buf, g); err != nil {
+		return nil, errors.Wrapf(err, "loading artist graph %s", path)
+	}
+	if g.Nodes == nil {
+		g.Nodes = make(map[string]*Node)
+	}
+	// Checkpoints from before Node.Fetched only recorded expanded nodes as fetched
+	for _, n := range g.Nodes {
+		if n.Expanded {
+			n.Fetched = true
+		}
+	}
+	g.edgeSet = nil
+	return g, nil
+}
@@ -0,0 +1,176 @@
+package artistgraph_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/artistgraph"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/golang/protobuf/proto"
+)
+
+func artistID(b byte) catalog.ID {
+	id, _ := catalog.FromGID(catalog.KindArtist, bytes.Repeat([]byte{b}, catalog.GIDLen))
+	return id
+}
+
+// key returns the graph key of artist b.
+func key(b byte) string {
+	return artistID(b).Base62()
+}
+
+// serve serves artist b, named after it, as related to the given artists.
+func serve(srv *mercurytest.Server, b byte, related ...byte) {
+	artist := &Spotify.Artist{
+		Gid:        artistID(b).GID(),
+		Name:       proto.String(string('A' + b)),
+		Popularity: proto.Float32(float32(b)),
+		Genre:      []string{"rock"},
+	}
+	for _, r := range related {
+		artist.Related = append(artist.Related, &Spotify.Artist{Gid: artistID(r).GID(), Name: proto.String(string('a' + r))})
+	}
+	srv.HandleArtist(artistID(b).Hex(), artist)
+}
+
+// testServer serves a chain 0 -> 1 -> 2 -> 3, with 0 also related to 4 and 2 back to 0.
+func testServer() *mercurytest.Server {
+	srv := mercurytest.New()
+	serve(srv, 0, 1, 4)
+	serve(srv, 1, 2)
+	serve(srv, 2, 3, 0)
+	serve(srv, 3)
+	serve(srv, 4)
+	return srv
+}
+
+func depths(g *artistgraph.Graph) map[string]int {
+	out := make(map[string]int)
+	for k, n := range g.Nodes {
+		out[k] = n.Depth
+	}
+	return out
+}
+
+func edges(g *artistgraph.Graph) []artistgraph.Edge {
+	return g.SortedEdges()
+}
+
+func sortedEdges(es ...artistgraph.Edge) []artistgraph.Edge {
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].From != es[j].From {
+			return es[i].From < es[j].From
+		}
+		return es[i].To < es[j].To
+	})
+	return es
+}
+
+func TestCrawl(t *testing.T) {
+	c := artistgraph.NewCrawler(catalog.NewSource(testServer()), artistgraph.Opts{Depth: 2})
+	g, err := c.Crawl(artistID(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{key(0): 0, key(1): 1, key(4): 1, key(2): 2}
+	if got := depths(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("depths %v, want %v", got, want)
+	}
+	wantEdges := sortedEdges(
+		artistgraph.Edge{From: key(0), To: key(1)},
+		artistgraph.Edge{From: key(0), To: key(4)},
+		artistgraph.Edge{From: key(1), To: key(2)},
+	)
+	if got := edges(g); !reflect.DeepEqual(got, wantEdges) {
+		t.Errorf("edges %v, want %v", got, wantEdges)
+	}
+	for k, n := range g.Nodes {
+		if !n.Fetched || n.Error != "" {
+			t.Errorf("%s: fetched %v, error %q", k, n.Fetched, n.Error)
+		}
+		if n.Expanded != (n.Depth < 2) {
+			t.Errorf("%s at depth %d: expanded %v", k, n.Depth, n.Expanded)
+		}
+	}
+	// The outer edge is filled in from the artist itself, not its related stub
+	if n := g.Nodes[key(2)]; n.Name != "C" || n.Popularity != 2 || !reflect.DeepEqual(n.Genres, []string{"rock"}) {
+		t.Errorf("outer node %+v", n)
+	}
+
+	if _, err = c.Crawl(catalog.UserID("bob")); err == nil {
+		t.Error("crawled from a user")
+	}
+}
+
+func TestCrawlMaxNodes(t *testing.T) {
+	c := artistgraph.NewCrawler(catalog.NewSource(testServer()), artistgraph.Opts{Depth: 5, MaxNodes: 3})
+	g, err := c.Crawl(artistID(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 3 {
+		t.Errorf("crawled %d nodes, want 3", len(g.Nodes))
+	}
+	for _, e := range g.Edges {
+		if g.Nodes[e.From] == nil || g.Nodes[e.To] == nil {
+			t.Errorf("edge %v leaves the graph", e)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	srv := mercurytest.New()
+	serve(srv, 0, 1, 4)
+	serve(srv, 4)
+	// 1 is missing for now
+	checkpoint := filepath.Join(t.TempDir(), "graph.json")
+	c := artistgraph.NewCrawler(catalog.NewSource(srv), artistgraph.Opts{Depth: 2, Checkpoint: checkpoint})
+	g, err := c.Crawl(artistID(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := g.Nodes[key(1)]; n.Error == "" || n.Expanded || n.Name != "b" {
+		t.Errorf("unfetchable node %+v", n)
+	}
+
+	// A fresh process picks the crawl up from its checkpoint
+	srv = testServer()
+	g, err = artistgraph.Load(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := g.Nodes[key(0)]; !n.Expanded || !n.Fetched {
+		t.Errorf("checkpoint lost the seed's state: %+v", n)
+	}
+	c = artistgraph.NewCrawler(catalog.NewSource(srv), artistgraph.Opts{Depth: 3, Checkpoint: checkpoint})
+	if err = c.Resume(g); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{key(0): 0, key(1): 1, key(4): 1, key(2): 2, key(3): 3}
+	if got := depths(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("depths %v, want %v", got, want)
+	}
+	if n := g.Nodes[key(1)]; n.Error != "" || !n.Expanded || n.Name != "B" {
+		t.Errorf("retried node %+v", n)
+	}
+
+	// The seed was not fetched again
+	for _, uri := range srv.Requests() {
+		if uri == mercurytest.ArtistPrefix+artistID(0).Hex() {
+			t.Error("refetched an expanded node")
+		}
+	}
+	// The back edge 2 -> 0 was recorded once
+	saved, err := artistgraph.Load(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(edges(saved), edges(g)) || len(g.Edges) != 5 {
+		t.Errorf("saved edges %v, crawled %v", edges(saved), edges(g))
+	}
+}
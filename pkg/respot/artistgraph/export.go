@@ -0,0 +1,120 @@
+package artistgraph
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteJSON writes the graph as indented JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the graph in Graphviz DOT format, labelling nodes with name and popularity.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph related_artists {")
+	fmt.Fprintln(bw, "  node [shape=ellipse];")
+	for _, n := range g.SortedNodes() {
+		fmt.Fprintf(bw, "  %s [label=%s, popularity=%g, depth=%d, genres=%s];\n",
+			dotQuote(n.ID), dotQuote(n.Name), n.Popularity, n.Depth, dotQuote(strings.Join(n.Genres, ";")))
+	}
+	for _, e := range g.SortedEdges() {
+		fmt.Fprintf(bw, "  %s -> %s;\n", dotQuote(e.From), dotQuote(e.To))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote returns s as a DOT quoted string.  Unlike Go quoting, DOT only
+// escapes the quote itself; a backslash is escaped too so that it is not read
+// as one of the label escapes such as \n or \l.
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph as GraphML with name, popularity, genres and depth node attributes.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "name", For: "node", Name: "name", Type: "string"},
+			{ID: "popularity", For: "node", Name: "popularity", Type: "double"},
+			{ID: "genres", For: "node", Name: "genres", Type: "string"},
+			{ID: "depth", For: "node", Name: "depth", Type: "int"},
+		},
+		Graph: graphMLGraph{
+			ID:          "related_artists",
+			EdgeDefault: "directed",
+		},
+	}
+	for _, n := range g.SortedNodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: n.ID,
+			Data: []graphMLData{
+				{Key: "name", Value: n.Name},
+				{Key: "popularity", Value: fmt.Sprint(n.Popularity)},
+				{Key: "genres", Value: strings.Join(n.Genres, ";")},
+				{Key: "depth", Value: fmt.Sprint(n.Depth)},
+			},
+		})
+	}
+	for _, e := range g.SortedEdges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: e.From, Target: e.To})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
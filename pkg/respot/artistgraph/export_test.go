@@ -0,0 +1,97 @@
+package artistgraph_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"github.com/arcspace/go-librespot/pkg/respot/artistgraph"
+)
+
+func exportGraph() *artistgraph.Graph {
+	g := artistgraph.NewGraph()
+	g.Nodes["b"] = &artistgraph.Node{ID: "b", Name: `C:\Music "Live"`, Popularity: 0.5, Depth: 1, Genres: []string{"a", "b"}}
+	g.Nodes["a"] = &artistgraph.Node{ID: "a", Name: "Sigur Rós\n", Depth: 0}
+	g.Edges = []artistgraph.Edge{{From: "b", To: "a"}, {From: "a", To: "b"}}
+	return g
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportGraph().WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph related_artists {
+  node [shape=ellipse];
+  "a" [label="Sigur Rós
+", popularity=0, depth=0, genres=""];
+  "b" [label="C:\\Music \"Live\"", popularity=0.5, depth=1, genres="a;b"];
+  "a" -> "b";
+  "b" -> "a";
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportGraph().WriteGraphML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Keys []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"key"`
+		Graph struct {
+			Directed string `xml:"edgedefault,attr"`
+			Nodes    []struct {
+				ID   string `xml:"id,attr"`
+				Data []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Keys) != 4 || doc.Graph.Directed != "directed" || len(doc.Graph.Nodes) != 2 || len(doc.Graph.Edges) != 2 {
+		t.Fatalf("got %+v", doc)
+	}
+	b := doc.Graph.Nodes[1]
+	data := make(map[string]string)
+	for _, d := range b.Data {
+		data[d.Key] = d.Value
+	}
+	want := map[string]string{"name": `C:\Music "Live"`, "popularity": "0.5", "genres": "a;b", "depth": "1"}
+	if b.ID != "b" || !reflect.DeepEqual(data, want) {
+		t.Errorf("node %s has %v, want %v", b.ID, data, want)
+	}
+	if e := doc.Graph.Edges[0]; e.Source != "a" || e.Target != "b" {
+		t.Errorf("first edge %+v", e)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	g := exportGraph()
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	back := artistgraph.NewGraph()
+	if err := json.Unmarshal(buf.Bytes(), back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back.Nodes, g.Nodes) || !reflect.DeepEqual(back.Edges, g.Edges) {
+		t.Errorf("round trip gave %+v", back)
+	}
+}
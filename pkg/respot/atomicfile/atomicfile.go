@@ -0,0 +1,60 @@
+// Package atomicfile writes files so that they appear complete or not at all.
+package atomicfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Default permissions of written files and the directories created for them
+const (
+	DefaultFileMode os.FileMode = 0o644
+	DefaultDirMode  os.FileMode = 0o755
+)
+
+// Write creates path (and its directory) via a temporary file in the same
+// directory that is synced and renamed into place once write succeeds.
+// Zero modes mean DefaultFileMode and DefaultDirMode.
+func Write(path string, fileMode, dirMode os.FileMode, write func(w io.Writer) error) error {
+	if fileMode == 0 {
+		fileMode = DefaultFileMode
+	}
+	if dirMode == 0 {
+		dirMode = DefaultDirMode
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Chmod(fileMode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// WriteBytes is Write for data already in memory.
+func WriteBytes(path string, fileMode, dirMode os.FileMode, data []byte) error {
+	return Write(path, fileMode, dirMode, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
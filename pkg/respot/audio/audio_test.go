@@ -0,0 +1,185 @@
+package audio_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-librespot/pkg/respot/audio"
+)
+
+// testdata/test.ogg holds the Vorbis packets of the jfreymuth/vorbis test
+// stream (one second of mono 44.1 kHz beeps), four to a page.
+func testDecoder(t *testing.T) *audio.Decoder {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "test.ogg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := audio.NewDecoder(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
+func decodeAll(t *testing.T, dec *audio.Decoder) []float32 {
+	t.Helper()
+	var sink audio.MemorySink
+	if _, err := audio.Copy(&sink, dec); err != nil {
+		t.Fatal(err)
+	}
+	return sink.Samples
+}
+
+func TestDecode(t *testing.T) {
+	dec := testDecoder(t)
+	if f := dec.Format(); f != (audio.Format{SampleRate: 44100, Channels: 1}) {
+		t.Errorf("format %+v", f)
+	}
+	if d, err := dec.Duration(); err != nil || d != time.Second {
+		t.Errorf("duration %v, err %v", d, err)
+	}
+	samples := decodeAll(t, dec)
+	if len(samples) != 44100 {
+		t.Fatalf("decoded %d samples, want 44100", len(samples))
+	}
+
+	// From the reference decode shipped with jfreymuth/vorbis
+	for i, want := range map[int]float32{
+		0:     0.005767822265625,
+		100:   -0.02606201171875,
+		1000:  0.73016357421875,
+		3000:  -0.38330078125,
+		12345: 0.00152587890625,
+		30000: 0,
+		42000: 0.0699462890625,
+		44099: 0.014007568359375,
+	} {
+		if got := samples[i]; math.Abs(float64(got-want)) > 2e-5 {
+			t.Errorf("sample %d is %g, want %g", i, got, want)
+		}
+	}
+}
+
+func TestSeekIsSampleAccurate(t *testing.T) {
+	want := decodeAll(t, testDecoder(t))
+	dec := testDecoder(t)
+	buf := make([]float32, 600)
+	for target := 0; target < len(want)+100; target += 997 {
+		if err := dec.Seek(time.Duration(target) * time.Second / 44100); err != nil {
+			t.Fatal(err)
+		}
+		// Truncating t to a whole nanosecond can land a sample early
+		at := int(int64(time.Duration(target)*time.Second/44100) * 44100 / int64(time.Second))
+
+		n, err := readFull(dec, buf)
+		if target >= len(want) {
+			if err != io.EOF {
+				t.Errorf("seeking to %d past the end: read %d, err %v", target, n, err)
+			}
+			continue
+		}
+		if end := len(want) - at; end < len(buf) && n != end {
+			t.Errorf("seeking to %d: read %d samples before the end, want %d", target, n, end)
+		}
+		for i, s := range buf[:n] {
+			if math.Abs(float64(s-want[at+i])) > 1e-6 {
+				t.Errorf("seeking to %d: sample %d is %g, want %g", target, at+i, s, want[at+i])
+				break
+			}
+		}
+	}
+}
+
+// readFull reads until buf is full or the stream ends.
+func readFull(dec *audio.Decoder, buf []float32) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := dec.ReadFloat32(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func TestWAVSinkSizes(t *testing.T) {
+	for _, enc := range []audio.Encoding{audio.Int16, audio.Float32} {
+		f, err := os.Create(filepath.Join(t.TempDir(), "out.wav"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink := &audio.WAVSink{W: f, Encoding: enc}
+		if err = sink.Open(audio.Format{SampleRate: 48000, Channels: 2}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if err = sink.Write(make([]float32, 10)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		data, _ := os.ReadFile(f.Name())
+
+		bps := enc.BytesPerSample()
+		dataLen := 30 * bps
+		if len(data) != 44+dataLen {
+			t.Fatalf("%v: file is %d bytes, want %d", enc, len(data), 44+dataLen)
+		}
+		le32 := func(off int) int { return int(binary.LittleEndian.Uint32(data[off:])) }
+		le16 := func(off int) int { return int(binary.LittleEndian.Uint16(data[off:])) }
+		tag := 1
+		if enc == audio.Float32 {
+			tag = 3
+		}
+		if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+			t.Errorf("%v: bad chunk ids in %q", enc, data[:44])
+		}
+		for _, c := range []struct {
+			name      string
+			got, want int
+		}{
+			{"riff size", le32(4), 36 + dataLen},
+			{"format tag", le16(20), tag},
+			{"channels", le16(22), 2},
+			{"sample rate", le32(24), 48000},
+			{"byte rate", le32(28), 48000 * 2 * bps},
+			{"block align", le16(32), 2 * bps},
+			{"bits per sample", le16(34), 8 * bps},
+			{"data size", le32(40), dataLen},
+		} {
+			if c.got != c.want {
+				t.Errorf("%v: %s is %d, want %d", enc, c.name, c.got, c.want)
+			}
+		}
+	}
+}
+
+func TestPipeSinkInt16(t *testing.T) {
+	var out bytes.Buffer
+	sink := &audio.PipeSink{W: &out}
+	if err := sink.Write([]float32{0, 1, -1, 0.5, 2, -2}); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]int16, out.Len()/2)
+	binary.Read(&out, binary.LittleEndian, got)
+	want := []int16{0, 32767, -32767, 16384, 32767, -32768}
+	if len(got) != len(want) {
+		t.Fatalf("wrote %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sample %d encoded as %d, want %d", i, got[i], want[i])
+		}
+	}
+}
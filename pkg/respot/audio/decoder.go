@@ -0,0 +1,218 @@
+// Package audio decodes the Vorbis stream of a pinned asset to PCM in pure Go
+// and writes it to pluggable sinks (WAV files, raw PCM pipes, memory).
+//
+// It is optional: the core packages never import it.
+package audio
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/pkg/respot/ogg"
+	"github.com/jfreymuth/vorbis"
+)
+
+// Decoder decodes an Ogg Vorbis stream to interleaved float32 samples.
+type Decoder struct {
+	demux   *ogg.Demuxer
+	vorbis  vorbis.Decoder
+	pending []float32 // decoded samples not yet returned
+	pos     int64     // frame index of the first pending sample
+	skip    int64     // frames still to drop after a seek
+	total   int64     // total frames, or -1 if unknown
+	gain    float32
+
+	// After a seek, output is held until a packet with a granule position
+	// says where it belongs.
+	syncing bool
+	held    []float32
+	target  int64 // frame seeked to
+	from    int64 // granule position the demuxer was positioned at
+}
+
+// NewDecoder prepares to decode the stream in r, which is size bytes long.
+// A leading Spotify header is skipped automatically.
+func NewDecoder(r io.ReaderAt, size int64) (*Decoder, error) {
+	demux, err := ogg.NewDemuxer(r, size)
+	if err != nil {
+		return nil, err
+	}
+	dec := &Decoder{
+		demux: demux,
+		total: -1,
+		gain:  1,
+	}
+	h := demux.Headers
+	for _, packet := range [][]byte{h.Identification, h.Comment, h.Setup} {
+		if err = dec.vorbis.ReadHeader(packet); err != nil {
+			return nil, errors.Wrap(err, "audio: reading vorbis headers")
+		}
+	}
+	if total, err := demux.TotalSamples(); err == nil {
+		dec.total = total
+	}
+	return dec, nil
+}
+
+// Format returns the layout of the decoded samples.
+func (dec *Decoder) Format() Format {
+	return Format{
+		SampleRate: dec.vorbis.SampleRate(),
+		Channels:   dec.vorbis.Channels(),
+	}
+}
+
+// Headers returns the Vorbis headers of the stream, including its comments.
+func (dec *Decoder) Headers() ogg.VorbisHeaders {
+	return dec.demux.Headers
+}
+
+// SetGain scales every decoded sample by factor, e.g. ogg.Normalization.Factor().
+func (dec *Decoder) SetGain(factor float32) {
+	dec.gain = factor
+}
+
+// Duration returns the exact duration of the stream.
+func (dec *Decoder) Duration() (time.Duration, error) {
+	return dec.demux.Duration()
+}
+
+// Seek positions the decoder at t, accurate to the sample.
+//
+// The first packet decoded after a seek only primes the decoder and yields no
+// output, and how much output it would have yielded depends on its block size
+// and that of the packet before it.  So rather than trusting the position the
+// demuxer seeked to, decoded output is held until a packet with a granule
+// position, which gives the frame its output ends at.
+func (dec *Decoder) Seek(t time.Duration) error {
+	rate := int64(dec.vorbis.SampleRate())
+	target := int64(t) * rate / int64(time.Second)
+	return dec.seek(target, target)
+}
+
+// seek positions the demuxer at the last page ending before sample before and
+// arranges for output to resume at target.
+func (dec *Decoder) seek(target, before int64) error {
+	at, err := dec.demux.SeekSample(before)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	dec.vorbis.Clear()
+	dec.pending, dec.held = nil, nil
+	dec.target, dec.from = target, at
+	dec.pos, dec.skip = at, target-at
+
+	// From the start of the stream, output starts at frame 0
+	dec.syncing = at > 0
+	return nil
+}
+
+// fill decodes packets until samples are pending.
+func (dec *Decoder) fill() error {
+	channels := int64(dec.vorbis.Channels())
+	for len(dec.pending) == 0 {
+		if dec.total >= 0 && dec.pos >= dec.total {
+			return io.EOF
+		}
+		packet, err := dec.demux.ReadPacket()
+		if err != nil {
+			return err
+		}
+		samples, err := dec.vorbis.Decode(packet.Data)
+		if err != nil {
+			continue // skip corrupt packets rather than abort playback
+		}
+		if dec.syncing {
+			if samples, err = dec.sync(samples, packet.Granule); err != nil {
+				return err
+			}
+			if dec.syncing {
+				continue
+			}
+		}
+
+		// The final page's granule position trims padding off the last block
+		if dec.total >= 0 {
+			if remain := (dec.total - dec.pos) * channels; int64(len(samples)) > remain {
+				samples = samples[:remain]
+			}
+		}
+		if dec.skip > 0 {
+			drop := dec.skip * channels
+			if drop > int64(len(samples)) {
+				drop = int64(len(samples))
+			}
+			dec.skip -= drop / channels
+			dec.pos += drop / channels
+			samples = samples[drop:]
+		}
+		dec.pending = samples
+	}
+	return nil
+}
+
+// sync holds samples decoded after a seek until granule places them.  It
+// returns the held samples once placed, with dec.pos and dec.skip set.
+func (dec *Decoder) sync(samples []float32, granule int64) ([]float32, error) {
+	dec.held = append(dec.held, samples...)
+	if granule < 0 {
+		return nil, nil
+	}
+	channels := int64(dec.vorbis.Channels())
+	start := granule - int64(len(dec.held))/channels
+
+	// The last page's granule position trims its final packet, so it can't
+	// place what came before it, and output starting after target has lost
+	// samples to the priming packet.  Either way start a page earlier.
+	if (dec.total >= 0 && granule >= dec.total) || start > dec.target {
+		if err := dec.seek(dec.target, dec.from); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	samples, dec.held = dec.held, nil
+	dec.syncing = false
+	dec.pos, dec.skip = start, dec.target-start
+	return samples, nil
+}
+
+// ReadFloat32 fills buf with interleaved samples in [-1, 1] and returns the
+// number of samples (not frames) written.  It returns io.EOF at the end of the stream.
+func (dec *Decoder) ReadFloat32(buf []float32) (int, error) {
+	if err := dec.fill(); err != nil {
+		return 0, err
+	}
+	n := copy(buf, dec.pending)
+	if dec.gain != 1 {
+		for i := range buf[:n] {
+			buf[i] *= dec.gain
+		}
+	}
+	dec.pending = dec.pending[n:]
+	dec.pos += int64(n / dec.vorbis.Channels())
+	return n, nil
+}
+
+// ReadInt16 is like ReadFloat32 but converts to signed 16-bit samples, clipping as needed.
+func (dec *Decoder) ReadInt16(buf []int16) (int, error) {
+	tmp := make([]float32, len(buf))
+	n, err := dec.ReadFloat32(tmp)
+	for i, s := range tmp[:n] {
+		buf[i] = FloatToInt16(s)
+	}
+	return n, err
+}
+
+// FloatToInt16 converts a sample in [-1, 1] to 16 bits, clipping values outside that range.
+func FloatToInt16(s float32) int16 {
+	v := math.Round(float64(s) * 32767)
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
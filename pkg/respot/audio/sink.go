@@ -0,0 +1,198 @@
+package audio
+
+import (
+	"io"
+	"math"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// Encoding is the sample format written by a sink.
+type Encoding int
+
+const (
+	Int16   Encoding = iota // signed 16-bit little endian
+	Float32                 // 32-bit IEEE float little endian
+)
+
+// BytesPerSample returns the size of one sample.
+func (enc Encoding) BytesPerSample() int {
+	if enc == Float32 {
+		return 4
+	}
+	return 2
+}
+
+// Format describes interleaved PCM audio.
+type Format struct {
+	SampleRate int
+	Channels   int
+}
+
+// Sink consumes decoded audio.
+type Sink interface {
+
+	// Open is called once before any samples are written.
+	Open(format Format) error
+
+	// Write consumes interleaved float32 samples in [-1, 1].
+	Write(samples []float32) error
+
+	// Close flushes the sink.
+	Close() error
+}
+
+// Copy decodes dec to the end and writes everything to sink, then closes it.
+// It returns the number of frames written.
+func Copy(sink Sink, dec *Decoder) (int64, error) {
+	format := dec.Format()
+	if err := sink.Open(format); err != nil {
+		return 0, err
+	}
+	buf := make([]float32, 4096*format.Channels)
+	var frames int64
+	for {
+		n, err := dec.ReadFloat32(buf)
+		if n > 0 {
+			if werr := sink.Write(buf[:n]); werr != nil {
+				sink.Close()
+				return frames, werr
+			}
+			frames += int64(n / format.Channels)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			sink.Close()
+			return frames, err
+		}
+	}
+	return frames, sink.Close()
+}
+
+// encode appends samples to buf in the given encoding.
+func encode(buf []byte, samples []float32, enc Encoding) []byte {
+	for _, s := range samples {
+		if enc == Float32 {
+			buf = appendLE32(buf, math.Float32bits(s))
+		} else {
+			buf = appendLE16(buf, uint16(FloatToInt16(s)))
+		}
+	}
+	return buf
+}
+
+// PipeSink writes raw interleaved PCM with no header, e.g. into a FIFO read by
+// `aplay -f S16_LE -c 2 -r 44100` or ffplay.
+type PipeSink struct {
+	W        io.Writer
+	Encoding Encoding
+
+	buf []byte
+}
+
+func (s *PipeSink) Open(format Format) error {
+	return nil
+}
+
+func (s *PipeSink) Write(samples []float32) error {
+	s.buf = encode(s.buf[:0], samples, s.Encoding)
+	_, err := s.W.Write(s.buf)
+	return err
+}
+
+func (s *PipeSink) Close() error {
+	return nil
+}
+
+// MemorySink collects decoded samples in memory.
+type MemorySink struct {
+	Format  Format
+	Samples []float32
+}
+
+func (s *MemorySink) Open(format Format) error {
+	s.Format = format
+	return nil
+}
+
+func (s *MemorySink) Write(samples []float32) error {
+	s.Samples = append(s.Samples, samples...)
+	return nil
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+// WAVSink writes a RIFF WAVE file.  The sizes in the header are filled in on Close.
+type WAVSink struct {
+	W        io.WriteSeeker
+	Encoding Encoding
+
+	format    Format
+	dataBytes int64
+	buf       []byte
+}
+
+const wavHeaderLen = 44
+
+func (s *WAVSink) Open(format Format) error {
+	s.format = format
+	s.dataBytes = 0
+	return s.writeHeader()
+}
+
+func (s *WAVSink) writeHeader() error {
+	bytesPerSample := s.Encoding.BytesPerSample()
+	formatTag := uint16(1) // PCM
+	if s.Encoding == Float32 {
+		formatTag = 3 // IEEE float
+	}
+	hdr := make([]byte, 0, wavHeaderLen)
+	hdr = append(hdr, "RIFF"...)
+	hdr = appendLE32(hdr, uint32(wavHeaderLen-8+s.dataBytes))
+	hdr = append(hdr, "WAVEfmt "...)
+	hdr = appendLE32(hdr, 16)
+	hdr = appendLE16(hdr, formatTag)
+	hdr = appendLE16(hdr, uint16(s.format.Channels))
+	hdr = appendLE32(hdr, uint32(s.format.SampleRate))
+	hdr = appendLE32(hdr, uint32(s.format.SampleRate*s.format.Channels*bytesPerSample))
+	hdr = appendLE16(hdr, uint16(s.format.Channels*bytesPerSample))
+	hdr = appendLE16(hdr, uint16(8*bytesPerSample))
+	hdr = append(hdr, "data"...)
+	hdr = appendLE32(hdr, uint32(s.dataBytes))
+	_, err := s.W.Write(hdr)
+	return err
+}
+
+func (s *WAVSink) Write(samples []float32) error {
+	s.buf = encode(s.buf[:0], samples, s.Encoding)
+	n, err := s.W.Write(s.buf)
+	s.dataBytes += int64(n)
+	return err
+}
+
+// Close rewrites the header with the final sizes.  It does not close W.
+func (s *WAVSink) Close() error {
+	if s.dataBytes > math.MaxUint32-wavHeaderLen {
+		return errors.New("audio: too much data for a WAV file")
+	}
+	if _, err := s.W.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+	_, err := s.W.Seek(0, io.SeekEnd)
+	return err
+}
+
+func appendLE16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendLE32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
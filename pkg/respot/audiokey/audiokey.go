@@ -0,0 +1,261 @@
+// Package audiokey implements the access point audio key exchange: requesting
+// the AES key of an audio file, correlating replies by sequence number, and
+// retrying, timing out and caching as needed.
+package audiokey
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// AP packet commands used by the exchange
+const (
+	CmdRequestKey = 0x0c // file_id(20) | gid(16) | seq(4) | 0x0000
+	CmdAesKey     = 0x0d // seq(4) | key(16)
+	CmdAesKeyErr  = 0x0e // seq(4) | code(2)
+)
+
+// Sizes of the fields of the exchange
+const (
+	FileIDLen = 20
+	GIDLen    = 16
+	KeyLen    = 16
+)
+
+// Error codes carried by CmdAesKeyErr
+const (
+	CodeUnavailable = 0x0001 // the file cannot be played by this account / in this country
+	CodeRateLimited = 0x0002 // too many key requests
+)
+
+var (
+	ErrUnavailable = errors.New("audio key: not available in your country or for your account")
+	ErrRateLimited = errors.New("audio key: rate limited")
+	ErrTimeout     = errors.New("audio key: request timed out")
+)
+
+// KeyError is returned when the AP refuses a key.  It matches ErrUnavailable or
+// ErrRateLimited with the standard library's errors.Is according to its code;
+// IsUnavailable checks the same through go-cedar's Cause.
+type KeyError struct {
+	Code uint16
+}
+
+func (e *KeyError) Error() string {
+	switch e.Code {
+	case CodeUnavailable:
+		return ErrUnavailable.Error()
+	case CodeRateLimited:
+		return ErrRateLimited.Error()
+	}
+	return fmt.Sprintf("audio key: error code 0x%04x", e.Code)
+}
+
+func (e *KeyError) Is(target error) bool {
+	switch target {
+	case ErrUnavailable:
+		return e.Code == CodeUnavailable
+	case ErrRateLimited:
+		return e.Code == CodeRateLimited
+	}
+	return false
+}
+
+// IsUnavailable reports whether err says the AP will never hand out the key,
+// so that asking again is pointless.
+func IsUnavailable(err error) bool {
+	cause := errors.Cause(err)
+	if ke, ok := cause.(*KeyError); ok {
+		return ke.Code == CodeUnavailable
+	}
+	return cause == ErrUnavailable
+}
+
+// Sender sends a packet to the access point.
+type Sender interface {
+	SendPacket(cmd byte, payload []byte) error
+}
+
+// Opts configures a Client.
+type Opts struct {
+	Timeout    time.Duration // per attempt (default 5s)
+	MaxRetries int           // retries after an error packet or timeout (default 3)
+	Backoff    time.Duration // delay before the first retry, doubled for each further one (default 500ms)
+}
+
+// Client requests audio keys over an AP connection.  Incoming CmdAesKey and
+// CmdAesKeyErr packets must be handed to Dispatch by the connection's packet loop.
+type Client struct {
+	conn Sender
+	opts Opts
+
+	mu      sync.Mutex
+	seq     uint32
+	pending map[uint32]chan reply
+	cache   map[cacheKey][]byte
+}
+
+type cacheKey struct {
+	gid    [GIDLen]byte
+	fileID [FileIDLen]byte
+}
+
+type reply struct {
+	key []byte
+	err error
+}
+
+// NewClient returns a Client sending requests over conn.
+func NewClient(conn Sender, opts Opts) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	} else if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 500 * time.Millisecond
+	}
+	return &Client{
+		conn:    conn,
+		opts:    opts,
+		pending: make(map[uint32]chan reply),
+		cache:   make(map[cacheKey][]byte),
+	}
+}
+
+// Key returns the AES key for an audio file of a track, from the cache if possible.
+// Errors match ErrUnavailable, ErrRateLimited or ErrTimeout where applicable.
+func (c *Client) Key(gid, fileID []byte) ([]byte, error) {
+	if len(gid) != GIDLen || len(fileID) != FileIDLen {
+		return nil, errors.Errorf("audio key: bad gid (%d bytes) or file id (%d bytes)", len(gid), len(fileID))
+	}
+	var ck cacheKey
+	copy(ck.gid[:], gid)
+	copy(ck.fileID[:], fileID)
+
+	c.mu.Lock()
+	key := c.cache[ck]
+	c.mu.Unlock()
+	if key != nil {
+		return key, nil
+	}
+
+	backoff := c.opts.Backoff
+	var err error
+	for attempt := 0; attempt <= c.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		key, err = c.request(gid, fileID)
+		if err == nil {
+			c.mu.Lock()
+			c.cache[ck] = key
+			c.mu.Unlock()
+			return key, nil
+		}
+		if IsUnavailable(err) {
+			break // retrying will not help
+		}
+	}
+	return nil, err
+}
+
+// request makes a single attempt.
+func (c *Client) request(gid, fileID []byte) ([]byte, error) {
+	ch := make(chan reply, 1)
+	c.mu.Lock()
+	seq := c.seq
+	c.seq++
+	c.pending[seq] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+	}()
+
+	if err := c.conn.SendPacket(CmdRequestKey, EncodeRequest(gid, fileID, seq)); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.key, r.err
+	case <-timer.C:
+		return nil, ErrTimeout
+	}
+}
+
+// Dispatch handles an incoming packet, returning false if it is not part of the key exchange.
+func (c *Client) Dispatch(cmd byte, payload []byte) bool {
+	if cmd != CmdAesKey && cmd != CmdAesKeyErr {
+		return false
+	}
+	if len(payload) < 4 {
+		return true
+	}
+	seq := binary.BigEndian.Uint32(payload)
+
+	c.mu.Lock()
+	ch := c.pending[seq]
+	c.mu.Unlock()
+	if ch == nil {
+		return true // late reply to a timed out request
+	}
+
+	var r reply
+	switch {
+	case cmd == CmdAesKey && len(payload) >= 4+KeyLen:
+		r.key = append([]byte(nil), payload[4:4+KeyLen]...)
+	case cmd == CmdAesKeyErr && len(payload) >= 6:
+		r.err = &KeyError{Code: binary.BigEndian.Uint16(payload[4:])}
+	default:
+		r.err = errors.Errorf("audio key: malformed reply (cmd 0x%02x, %d bytes)", cmd, len(payload))
+	}
+	select {
+	case ch <- r:
+	default:
+	}
+	return true
+}
+
+// Forget drops a cached key, e.g. after it failed to decrypt.
+func (c *Client) Forget(gid, fileID []byte) {
+	var ck cacheKey
+	copy(ck.gid[:], gid)
+	copy(ck.fileID[:], fileID)
+	c.mu.Lock()
+	delete(c.cache, ck)
+	c.mu.Unlock()
+}
+
+// EncodeRequest builds the CmdRequestKey payload.
+func EncodeRequest(gid, fileID []byte, seq uint32) []byte {
+	buf := make([]byte, 0, FileIDLen+GIDLen+4+2)
+	buf = append(buf, fileID...)
+	buf = append(buf, gid...)
+	buf = append(buf, byte(seq>>24), byte(seq>>16), byte(seq>>8), byte(seq))
+	return append(buf, 0x00, 0x00)
+}
+
+// DecodeRequest parses a CmdRequestKey payload.
+func DecodeRequest(payload []byte) (gid, fileID []byte, seq uint32, err error) {
+	if len(payload) < FileIDLen+GIDLen+4 {
+		return nil, nil, 0, errors.Errorf("audio key: short request (%d bytes)", len(payload))
+	}
+	fileID = payload[:FileIDLen]
+	gid = payload[FileIDLen : FileIDLen+GIDLen]
+	seq = binary.BigEndian.Uint32(payload[FileIDLen+GIDLen:])
+	return gid, fileID, seq, nil
+}
@@ -0,0 +1,112 @@
+package audiokey_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-librespot/pkg/respot/audiokey"
+	"github.com/arcspace/go-librespot/pkg/respot/audiokey/audiokeytest"
+)
+
+var (
+	testGID    = bytes.Repeat([]byte{0x01}, audiokey.GIDLen)
+	testFileID = bytes.Repeat([]byte{0xf1}, audiokey.FileIDLen)
+	testKey    = bytes.Repeat([]byte{0x4b}, audiokey.KeyLen)
+)
+
+func newClient(opts audiokey.Opts) (*audiokeytest.AP, *audiokey.Client) {
+	if opts.Backoff == 0 {
+		opts.Backoff = time.Millisecond
+	}
+	ap := audiokeytest.New()
+	c := audiokey.NewClient(ap, opts)
+	ap.Attach(c)
+	return ap, c
+}
+
+func TestKeyCached(t *testing.T) {
+	ap, c := newClient(audiokey.Opts{})
+	ap.SetKey(testFileID, testKey)
+	for i := 0; i < 2; i++ {
+		key, err := c.Key(testGID, testFileID)
+		if err != nil || !bytes.Equal(key, testKey) {
+			t.Fatalf("got key %x, err %v", key, err)
+		}
+	}
+	if n := ap.Requests(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+
+	c.Forget(testGID, testFileID)
+	if _, err := c.Key(testGID, testFileID); err != nil || ap.Requests() != 2 {
+		t.Errorf("after Forget: err %v, %d requests", err, ap.Requests())
+	}
+}
+
+func TestKeyRetriesRateLimited(t *testing.T) {
+	ap, c := newClient(audiokey.Opts{MaxRetries: 3})
+	ap.SetKey(testFileID, testKey)
+	ap.Fail(testFileID, audiokey.CodeRateLimited, audiokey.CodeRateLimited)
+	if key, err := c.Key(testGID, testFileID); err != nil || !bytes.Equal(key, testKey) {
+		t.Fatalf("got key %x, err %v", key, err)
+	}
+	if n := ap.Requests(); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+
+	// Once the retries are used up, the last refusal is returned
+	c.Forget(testGID, testFileID)
+	ap.Fail(testFileID, audiokey.CodeRateLimited, audiokey.CodeRateLimited, audiokey.CodeRateLimited, audiokey.CodeRateLimited)
+	_, err := c.Key(testGID, testFileID)
+	if ke, ok := err.(*audiokey.KeyError); !ok || ke.Code != audiokey.CodeRateLimited || !errors.Is(err, audiokey.ErrRateLimited) {
+		t.Errorf("got %v, want a rate limit KeyError", err)
+	}
+	if n := ap.Requests(); n != 3+4 {
+		t.Errorf("sent %d requests, want 7", n)
+	}
+}
+
+func TestKeyUnavailableNotRetried(t *testing.T) {
+	ap, c := newClient(audiokey.Opts{})
+	_, err := c.Key(testGID, testFileID)
+	if !audiokey.IsUnavailable(err) || !errors.Is(err, audiokey.ErrUnavailable) {
+		t.Errorf("got %v, want ErrUnavailable", err)
+	}
+	if n := ap.Requests(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestKeyTimeout(t *testing.T) {
+	ap, c := newClient(audiokey.Opts{Timeout: 50 * time.Millisecond, MaxRetries: 1})
+	ap.SetKey(testFileID, testKey)
+
+	// A dropped request is retried
+	ap.Drop(1)
+	if _, err := c.Key(testGID, testFileID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reply that arrives after the timeout is ignored
+	c.Forget(testGID, testFileID)
+	ap.SetLatency(200 * time.Millisecond)
+	start := time.Now()
+	if _, err := c.Key(testGID, testFileID); err != audiokey.ErrTimeout {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 190*time.Millisecond {
+		t.Errorf("gave up after %v, want about 100ms", elapsed)
+	}
+	if n := ap.Requests(); n != 4 {
+		t.Errorf("sent %d requests, want 4", n)
+	}
+}
+
+func TestKeyBadIDs(t *testing.T) {
+	_, c := newClient(audiokey.Opts{})
+	if _, err := c.Key(testGID[:4], testFileID); err == nil {
+		t.Error("accepted a short gid")
+	}
+}
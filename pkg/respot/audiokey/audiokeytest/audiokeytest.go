@@ -0,0 +1,215 @@
+// Package audiokeytest provides a fake access point for the audio key exchange
+// and channel streaming, so that code built on audiokey.Client and
+// channel.Manager can be exercised without a Spotify account.
+package audiokeytest
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-librespot/pkg/respot/audiokey"
+	"github.com/arcspace/go-librespot/pkg/respot/channel"
+)
+
+// Dispatcher receives reply packets; *audiokey.Client and *channel.Manager implement it.
+type Dispatcher interface {
+	Dispatch(cmd byte, payload []byte) bool
+}
+
+// PacketSize is the most file data the AP puts in one channel packet.
+const PacketSize = 4096
+
+// AP answers CmdRequestKey packets with scripted keys, error codes, silence and
+// latency, and CmdStreamChunk packets with the (encrypted) files added to it.
+// Use it as the Sender of a Client or Manager and Attach them.
+type AP struct {
+	mu       sync.Mutex
+	clients  []Dispatcher
+	keys     map[string][]byte
+	files    map[string][]byte
+	failures map[string][]uint16 // error codes to answer with before the key, per file id
+	drop     int
+	latency  time.Duration
+	requests int
+	chunks   int
+}
+
+// New returns an AP that knows no keys or files; requests for unknown files get
+// CodeUnavailable or a channel error.
+func New() *AP {
+	return &AP{
+		keys:     make(map[string][]byte),
+		files:    make(map[string][]byte),
+		failures: make(map[string][]uint16),
+	}
+}
+
+// Attach adds a receiver of replies; each reply goes to the first that accepts it.
+func (ap *AP) Attach(client Dispatcher) {
+	ap.mu.Lock()
+	ap.clients = append(ap.clients, client)
+	ap.mu.Unlock()
+}
+
+// AddFile registers the encrypted contents of a file served over channels.
+func (ap *AP) AddFile(fileID, data []byte) {
+	ap.mu.Lock()
+	ap.files[hex.EncodeToString(fileID)] = data
+	ap.mu.Unlock()
+}
+
+// SetKey registers the key for a file.
+func (ap *AP) SetKey(fileID, key []byte) {
+	ap.mu.Lock()
+	ap.keys[hex.EncodeToString(fileID)] = key
+	ap.mu.Unlock()
+}
+
+// Fail makes the next requests for fileID fail with the given error codes, in order.
+func (ap *AP) Fail(fileID []byte, codes ...uint16) {
+	ap.mu.Lock()
+	k := hex.EncodeToString(fileID)
+	ap.failures[k] = append(ap.failures[k], codes...)
+	ap.mu.Unlock()
+}
+
+// Drop makes the AP ignore the next n key or chunk requests, so that they time out.
+func (ap *AP) Drop(n int) {
+	ap.mu.Lock()
+	ap.drop += n
+	ap.mu.Unlock()
+}
+
+// SetLatency delays every reply by d.
+func (ap *AP) SetLatency(d time.Duration) {
+	ap.mu.Lock()
+	ap.latency = d
+	ap.mu.Unlock()
+}
+
+// Requests returns how many key requests have been received.
+func (ap *AP) Requests() int {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.requests
+}
+
+// ChunkRequests returns how many chunk requests have been received.
+func (ap *AP) ChunkRequests() int {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.chunks
+}
+
+// deliver hands packets, in order, to the first attached client that accepts each.
+func (ap *AP) deliver(latency time.Duration, cmds []byte, payloads [][]byte) {
+	ap.mu.Lock()
+	clients := ap.clients
+	ap.mu.Unlock()
+	go func() {
+		time.Sleep(latency)
+		for i, cmd := range cmds {
+			for _, client := range clients {
+				if client.Dispatch(cmd, payloads[i]) {
+					break
+				}
+			}
+		}
+	}()
+}
+
+// SendPacket implements audiokey.Sender and channel.Sender.
+func (ap *AP) SendPacket(cmd byte, payload []byte) error {
+	switch cmd {
+	case audiokey.CmdRequestKey:
+		return ap.requestKey(payload)
+	case channel.CmdStreamChunk:
+		return ap.streamChunk(payload)
+	}
+	return nil
+}
+
+func (ap *AP) requestKey(payload []byte) error {
+	_, fileID, seq, err := audiokey.DecodeRequest(payload)
+	if err != nil {
+		return err
+	}
+	k := hex.EncodeToString(fileID)
+
+	ap.mu.Lock()
+	ap.requests++
+	latency := ap.latency
+	if ap.drop > 0 {
+		ap.drop--
+		ap.mu.Unlock()
+		return nil
+	}
+	reply := make([]byte, 4, 4+audiokey.KeyLen)
+	binary.BigEndian.PutUint32(reply, seq)
+	replyCmd := byte(audiokey.CmdAesKey)
+	if codes := ap.failures[k]; len(codes) > 0 {
+		ap.failures[k] = codes[1:]
+		replyCmd = audiokey.CmdAesKeyErr
+		reply = append(reply, byte(codes[0]>>8), byte(codes[0]))
+	} else if key := ap.keys[k]; key != nil {
+		reply = append(reply, key...)
+	} else {
+		replyCmd = audiokey.CmdAesKeyErr
+		reply = append(reply, 0, audiokey.CodeUnavailable)
+	}
+	ap.mu.Unlock()
+
+	ap.deliver(latency, []byte{replyCmd}, [][]byte{reply})
+	return nil
+}
+
+// streamChunk answers on the requested channel with the file size header, the
+// requested words in packets of up to PacketSize bytes and an empty packet.
+func (ap *AP) streamChunk(payload []byte) error {
+	id, fileID, start, end, err := channel.DecodeRequest(payload)
+	if err != nil {
+		return err
+	}
+
+	ap.mu.Lock()
+	ap.chunks++
+	latency := ap.latency
+	data, ok := ap.files[hex.EncodeToString(fileID)]
+	if ap.drop > 0 {
+		ap.drop--
+		ap.mu.Unlock()
+		return nil
+	}
+	ap.mu.Unlock()
+
+	if !ok {
+		ap.deliver(latency, []byte{channel.CmdChannelError}, [][]byte{{byte(id >> 8), byte(id), 0, 1}})
+		return nil
+	}
+
+	words := (len(data) + channel.WordSize - 1) / channel.WordSize
+	var sizeHdr [4]byte
+	binary.BigEndian.PutUint32(sizeHdr[:], uint32(words))
+	cmds := []byte{channel.CmdStreamChunkRes}
+	payloads := [][]byte{channel.EncodeHeaders(id, channel.Header{ID: channel.HeaderFileSize, Data: sizeHdr[:]})}
+
+	from, to := int(start)*channel.WordSize, int(end)*channel.WordSize
+	if to > len(data) {
+		to = len(data)
+	}
+	for off := from; off < to; off += PacketSize {
+		n := to - off
+		if n > PacketSize {
+			n = PacketSize
+		}
+		cmds = append(cmds, channel.CmdStreamChunkRes)
+		payloads = append(payloads, channel.EncodeData(id, data[off:off+n]))
+	}
+	cmds = append(cmds, channel.CmdStreamChunkRes)
+	payloads = append(payloads, channel.EncodeData(id, nil))
+
+	ap.deliver(latency, cmds, payloads)
+	return nil
+}
@@ -0,0 +1,290 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arcspace/go-librespot/Spotify"
+)
+
+// ReleaseGroup identifies which of an artist's release lists an album came from.
+type ReleaseGroup int
+
+const (
+	GroupAlbum ReleaseGroup = iota
+	GroupSingle
+	GroupCompilation
+	GroupAppearsOn
+)
+
+var releaseGroupNames = [...]string{
+	GroupAlbum:       "album",
+	GroupSingle:      "single",
+	GroupCompilation: "compilation",
+	GroupAppearsOn:   "appears_on",
+}
+
+func (g ReleaseGroup) String() string {
+	if g < 0 || int(g) >= len(releaseGroupNames) {
+		return "unknown"
+	}
+	return releaseGroupNames[g]
+}
+
+// AllReleaseGroups lists every ReleaseGroup in the order Discography walks them.
+var AllReleaseGroups = []ReleaseGroup{GroupAlbum, GroupSingle, GroupCompilation, GroupAppearsOn}
+
+// Release is a single entry of an artist's discography.
+type Release struct {
+	ID    ID
+	Group ReleaseGroup
+	Album *Spotify.Album // fully fetched album
+}
+
+// DiscographyOpts controls what Discography returns.
+type DiscographyOpts struct {
+	Groups      []ReleaseGroup // groups to walk (default AllReleaseGroups)
+	Country     string         // if set, pick the variant of each release that is playable here and skip releases with none
+	Catalogue   string         // account catalogue used with Country (e.g. "premium")
+	NewestFirst bool           // sort by descending Album.date instead of ascending
+	Unsorted    bool           // keep the artist's listing order and fetch albums a page at a time as Next needs them
+	PageSize    int            // max albums fetched per page when Unsorted (default 50)
+	Workers     int            // max concurrent album fetches (default 8)
+}
+
+// DiscographyIter steps through the releases of an artist:
+//
+//	it := catalog.Discography(src, artistID, opts)
+//	for it.Next() {
+//		rel := it.Release()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// Sorting by date needs every album, so unless opts.Unsorted is set the first
+// call to Next fetches them all.
+type DiscographyIter struct {
+	src      Source
+	artistID ID
+	opts     DiscographyOpts
+	started  bool
+	h        *hydrator
+	pending  []candidates // album groups not fetched yet
+	releases []Release    // fetched releases not yet returned
+	seen     map[string]struct{}
+	failed   map[ID]error
+	cur      Release
+	err      error
+}
+
+// candidates are the variants of a single release (one AlbumGroup).
+type candidates struct {
+	group ReleaseGroup
+	ids   []ID
+}
+
+// Discography returns an iterator over the releases of an artist across the
+// album, single, compilation and appears-on groups.  Each AlbumGroup yields at
+// most one release (its market-preferred variant), regional duplicates are
+// dropped, and releases are ordered by Album.date unless opts.Unsorted is set.
+func Discography(src Source, artistID ID, opts DiscographyOpts) *DiscographyIter {
+	if len(opts.Groups) == 0 {
+		opts.Groups = AllReleaseGroups
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = 50
+	}
+	return &DiscographyIter{
+		src:      src,
+		artistID: artistID,
+		opts:     opts,
+	}
+}
+
+// Next advances to the next release, returning false when there are no more or an error occurred.
+func (it *DiscographyIter) Next() bool {
+	if !it.started {
+		it.started = true
+		if it.err = it.start(); it.err != nil {
+			return false
+		}
+	}
+	for len(it.releases) == 0 && len(it.pending) > 0 {
+		it.fetchPage()
+	}
+	if len(it.releases) == 0 {
+		return false
+	}
+	it.cur = it.releases[0]
+	it.releases = it.releases[1:]
+	return true
+}
+
+// Release returns the release Next() advanced to.
+func (it *DiscographyIter) Release() Release {
+	return it.cur
+}
+
+// Err returns the error that stopped iteration, if any.  Releases that were
+// merely skipped because none of their variants could be fetched are not
+// errors; see Failed.
+func (it *DiscographyIter) Err() error {
+	return it.err
+}
+
+// Failed returns the albums that failed to fetch for releases that were
+// skipped as a result.  It is complete once Next has returned false.
+func (it *DiscographyIter) Failed() map[ID]error {
+	return it.failed
+}
+
+func artistGroups(artist *Spotify.Artist, group ReleaseGroup) []*Spotify.AlbumGroup {
+	switch group {
+	case GroupAlbum:
+		return artist.GetAlbumGroup()
+	case GroupSingle:
+		return artist.GetSingleGroup()
+	case GroupCompilation:
+		return artist.GetCompilationGroup()
+	case GroupAppearsOn:
+		return artist.GetAppearsOnGroup()
+	}
+	return nil
+}
+
+// start fetches the artist and lists the releases to fetch.
+func (it *DiscographyIter) start() error {
+	artist, err := it.src.GetArtist(it.artistID)
+	if err != nil {
+		return err
+	}
+	for _, group := range it.opts.Groups {
+		for _, ag := range artistGroups(artist, group) {
+			c := candidates{group: group}
+			for _, stub := range ag.GetAlbum() {
+				if id, err := FromGID(KindAlbum, stub.GetGid()); err == nil {
+					c.ids = append(c.ids, id)
+				}
+			}
+			// Without a country to check, only the preferred (first) variant is needed
+			if it.opts.Country == "" && len(c.ids) > 1 {
+				c.ids = c.ids[:1]
+			}
+			it.pending = append(it.pending, c)
+		}
+	}
+
+	hydrateOpts := HydrateOpts{Workers: it.opts.Workers}
+	hydrateOpts.normalize()
+	it.h = &hydrator{src: it.src, opts: hydrateOpts, graph: newGraph(it.artistID)}
+	it.seen = make(map[string]struct{})
+	it.failed = make(map[ID]error)
+	return nil
+}
+
+// fetchPage fetches the next page of pending releases, or all of them if the
+// releases are to be sorted, and queues the ones that make the cut.
+func (it *DiscographyIter) fetchPage() {
+	page := it.pending
+	if it.opts.Unsorted {
+		n, count := 0, 0
+		for n < len(page) && (n == 0 || count+len(page[n].ids) <= it.opts.PageSize) {
+			count += len(page[n].ids)
+			n++
+		}
+		page = page[:n]
+	}
+	it.pending = it.pending[len(page):]
+
+	var toFetch []ID
+	for _, c := range page {
+		toFetch = append(toFetch, c.ids...)
+	}
+	it.h.fetch(toFetch)
+	g := it.h.graph
+
+	for _, c := range page {
+		var pick ID
+		var album *Spotify.Album
+		for _, id := range c.ids {
+			a := g.Albums[id]
+			if a == nil {
+				continue
+			}
+			if it.opts.Country == "" || IsAlbumPlayable(a, it.opts.Country, it.opts.Catalogue) {
+				pick, album = id, a
+				break
+			}
+		}
+		if album == nil {
+			for _, id := range c.ids {
+				if err := g.Errors[id]; err != nil {
+					it.failed[id] = err
+				}
+			}
+			continue
+		}
+		dupe := false
+		for _, key := range releaseKeys(c.group, album) {
+			if _, dupe = it.seen[key]; dupe {
+				break
+			}
+		}
+		if dupe {
+			continue
+		}
+		for _, key := range releaseKeys(c.group, album) {
+			it.seen[key] = struct{}{}
+		}
+		it.releases = append(it.releases, Release{ID: pick, Group: c.group, Album: album})
+	}
+
+	if !it.opts.Unsorted {
+		sort.SliceStable(it.releases, func(i, j int) bool {
+			ci := compareDates(it.releases[i].Album.GetDate(), it.releases[j].Album.GetDate())
+			if it.opts.NewestFirst {
+				return ci > 0
+			}
+			return ci < 0
+		})
+	}
+}
+
+// releaseKeys returns the keys under which two regional variants of the same release collide:
+// a shared UPC, or the same group, title and track count.
+func releaseKeys(group ReleaseGroup, album *Spotify.Album) []string {
+	var keys []string
+	for _, ext := range album.GetExternalId() {
+		if strings.EqualFold(ext.GetTyp(), "upc") && ext.GetId() != "" {
+			keys = append(keys, "upc:"+ext.GetId())
+		}
+	}
+	numTracks := 0
+	for _, disc := range album.GetDisc() {
+		numTracks += len(disc.GetTrack())
+	}
+	title := strings.ToLower(strings.TrimSpace(album.GetName()))
+	keys = append(keys, fmt.Sprintf("%v:%s:%d", group, title, numTracks))
+	return keys
+}
+
+// compareDates orders possibly partial dates, treating missing fields as zero.
+func compareDates(a, b *Spotify.Date) int {
+	fields := [][2]int32{
+		{a.GetYear(), b.GetYear()},
+		{a.GetMonth(), b.GetMonth()},
+		{a.GetDay(), b.GetDay()},
+	}
+	for _, f := range fields {
+		switch {
+		case f[0] < f[1]:
+			return -1
+		case f[0] > f[1]:
+			return 1
+		}
+	}
+	return 0
+}
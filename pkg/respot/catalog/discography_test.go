@@ -0,0 +1,233 @@
+package catalog_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/golang/protobuf/proto"
+)
+
+// countingSource is a plain (unbatched) Source that records album fetches.
+type countingSource struct {
+	catalog.Source
+
+	mu     sync.Mutex
+	albums []catalog.ID
+}
+
+func (s *countingSource) GetAlbum(id catalog.ID) (*Spotify.Album, error) {
+	s.mu.Lock()
+	s.albums = append(s.albums, id)
+	s.mu.Unlock()
+	return s.Source.GetAlbum(id)
+}
+
+func (s *countingSource) fetched() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.albums)
+}
+
+// testArtist serves an artist and its albums, each named by its one-byte gid.
+type testArtist struct {
+	t      *testing.T
+	srv    *mercurytest.Server
+	artist *Spotify.Artist
+}
+
+func newTestArtist(t *testing.T) *testArtist {
+	return &testArtist{t: t, srv: mercurytest.New(), artist: &Spotify.Artist{Gid: gid(0xa0)}}
+}
+
+// add lists a release with the given variants in group and serves each
+// variant's album unless it is nil.
+func (a *testArtist) add(group catalog.ReleaseGroup, variants ...*Spotify.Album) {
+	ag := &Spotify.AlbumGroup{}
+	for _, album := range variants {
+		ag.Album = append(ag.Album, &Spotify.Album{Gid: album.Gid})
+		if album.Name != nil {
+			id, _ := catalog.FromGID(catalog.KindAlbum, album.Gid)
+			a.srv.HandleAlbum(id.Hex(), album)
+		}
+	}
+	switch group {
+	case catalog.GroupAlbum:
+		a.artist.AlbumGroup = append(a.artist.AlbumGroup, ag)
+	case catalog.GroupSingle:
+		a.artist.SingleGroup = append(a.artist.SingleGroup, ag)
+	case catalog.GroupCompilation:
+		a.artist.CompilationGroup = append(a.artist.CompilationGroup, ag)
+	case catalog.GroupAppearsOn:
+		a.artist.AppearsOnGroup = append(a.artist.AppearsOnGroup, ag)
+	}
+}
+
+func (a *testArtist) walk(src catalog.Source, opts catalog.DiscographyOpts) ([]string, *catalog.DiscographyIter) {
+	id, _ := catalog.FromGID(catalog.KindArtist, a.artist.Gid)
+	a.srv.HandleArtist(id.Hex(), a.artist)
+	if src == nil {
+		src = catalog.NewSource(a.srv)
+	}
+	it := catalog.Discography(src, id, opts)
+	var names []string
+	for it.Next() {
+		rel := it.Release()
+		if rel.Album == nil || !reflect.DeepEqual(rel.ID.GID(), rel.Album.GetGid()) {
+			a.t.Errorf("release %v carries album %v", rel.ID, rel.Album)
+		}
+		names = append(names, rel.Group.String()+":"+rel.Album.GetName())
+	}
+	return names, it
+}
+
+func album(b byte, name string, ymd ...int32) *Spotify.Album {
+	a := &Spotify.Album{Gid: gid(b)}
+	if name != "" {
+		a.Name = proto.String(name)
+	}
+	if len(ymd) > 0 {
+		a.Date = date(ymd...)
+	}
+	return a
+}
+
+func TestDiscographySorts(t *testing.T) {
+	a := newTestArtist(t)
+	a.add(catalog.GroupAlbum, album(1, "2001", 2001, 4, 2))
+	a.add(catalog.GroupAlbum, album(2, "1999-05", 1999, 5))
+	a.add(catalog.GroupSingle, album(3, "1999", 1999))
+	a.add(catalog.GroupAppearsOn, album(4, "2001-01", 2001, 1))
+
+	names, it := a.walk(nil, catalog.DiscographyOpts{})
+	want := []string{"single:1999", "album:1999-05", "appears_on:2001-01", "album:2001"}
+	if it.Err() != nil || !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, err %v; want %q", names, it.Err(), want)
+	}
+
+	names, _ = a.walk(nil, catalog.DiscographyOpts{NewestFirst: true})
+	want = []string{"album:2001", "appears_on:2001-01", "album:1999-05", "single:1999"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("newest first: got %q, want %q", names, want)
+	}
+
+	names, _ = a.walk(nil, catalog.DiscographyOpts{Groups: []catalog.ReleaseGroup{catalog.GroupSingle, catalog.GroupAlbum}, Unsorted: true})
+	want = []string{"single:1999", "album:2001", "album:1999-05"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("unsorted singles and albums: got %q, want %q", names, want)
+	}
+}
+
+func TestDiscographyPicksVariant(t *testing.T) {
+	gb := []*Spotify.Restriction{streaming("GB", "")}
+	de := []*Spotify.Restriction{streaming("DE", "")}
+	a := newTestArtist(t)
+	gbOnly, deOnly := album(1, "GB", 2000), album(2, "DE", 2000)
+	gbOnly.Restriction, deOnly.Restriction = gb, de
+	a.add(catalog.GroupAlbum, gbOnly, deOnly)
+	nowhere := album(3, "nowhere", 2001)
+	nowhere.Restriction = []*Spotify.Restriction{streaming("FR", "")}
+	a.add(catalog.GroupAlbum, nowhere)
+
+	for _, c := range []struct {
+		country string
+		want    []string
+		fetched int
+	}{
+		{"DE", []string{"album:DE"}, 3},
+		{"GB", []string{"album:GB"}, 3},
+		{"", []string{"album:GB", "album:nowhere"}, 2}, // only the preferred variant is fetched
+	} {
+		src := &countingSource{Source: catalog.NewSource(a.srv)}
+		names, it := a.walk(src, catalog.DiscographyOpts{Country: c.country})
+		if it.Err() != nil || !reflect.DeepEqual(names, c.want) {
+			t.Errorf("%q: got %q, err %v; want %q", c.country, names, it.Err(), c.want)
+		}
+		if n := src.fetched(); n != c.fetched {
+			t.Errorf("%q: fetched %d albums, want %d", c.country, n, c.fetched)
+		}
+		if len(it.Failed()) != 0 {
+			t.Errorf("%q: unplayable releases reported as failed: %v", c.country, it.Failed())
+		}
+	}
+}
+
+func TestDiscographyDedupes(t *testing.T) {
+	upc := func(a *Spotify.Album, code string) *Spotify.Album {
+		a.ExternalId = []*Spotify.ExternalId{{Typ: proto.String("upc"), Id: proto.String(code)}}
+		return a
+	}
+	tracks := func(a *Spotify.Album, n int) *Spotify.Album {
+		disc := &Spotify.Disc{}
+		for i := 0; i < n; i++ {
+			disc.Track = append(disc.Track, &Spotify.Track{Gid: gid(byte(0x80 + i))})
+		}
+		a.Disc = []*Spotify.Disc{disc}
+		return a
+	}
+	a := newTestArtist(t)
+	a.add(catalog.GroupAlbum, upc(album(1, "Record", 2000), "123"))
+	a.add(catalog.GroupSingle, upc(album(2, "Record (single)", 2000), "123")) // same UPC
+	a.add(catalog.GroupAlbum, tracks(album(3, "Other", 2001), 2))
+	a.add(catalog.GroupAlbum, tracks(album(4, " other ", 2001), 2))     // same title and length
+	a.add(catalog.GroupAlbum, tracks(album(5, "Other", 2002), 3))       // longer
+	a.add(catalog.GroupCompilation, tracks(album(6, "Other", 2003), 2)) // another group
+
+	names, it := a.walk(nil, catalog.DiscographyOpts{})
+	want := []string{"album:Record", "album:Other", "album:Other", "compilation:Other"}
+	if it.Err() != nil || !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, err %v; want %q", names, it.Err(), want)
+	}
+}
+
+func TestDiscographyPages(t *testing.T) {
+	a := newTestArtist(t)
+	for i := byte(1); i <= 5; i++ {
+		a.add(catalog.GroupAlbum, album(i, string('0'+i), 2005-int32(i)))
+	}
+	src := &countingSource{Source: catalog.NewSource(a.srv)}
+	id, _ := catalog.FromGID(catalog.KindArtist, a.artist.Gid)
+	a.srv.HandleArtist(id.Hex(), a.artist)
+	it := catalog.Discography(src, id, catalog.DiscographyOpts{Unsorted: true, PageSize: 2})
+
+	var names []string
+	for _, fetched := range []int{2, 2, 4, 4, 5} {
+		if !it.Next() {
+			t.Fatalf("stopped after %q: %v", names, it.Err())
+		}
+		names = append(names, it.Release().Album.GetName())
+		if n := src.fetched(); n != fetched {
+			t.Errorf("after %d releases, fetched %d albums; want %d", len(names), n, fetched)
+		}
+	}
+	if it.Next() || it.Err() != nil {
+		t.Errorf("went on past the last release, err %v", it.Err())
+	}
+	if want := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want the listing order %q", names, want)
+	}
+}
+
+func TestDiscographyFailures(t *testing.T) {
+	a := newTestArtist(t)
+	a.add(catalog.GroupAlbum, album(1, "ok", 2000))
+	a.add(catalog.GroupAlbum, album(2, "", 2001)) // not served
+	names, it := a.walk(nil, catalog.DiscographyOpts{})
+	if it.Err() != nil || !reflect.DeepEqual(names, []string{"album:ok"}) {
+		t.Errorf("got %q, err %v", names, it.Err())
+	}
+	missing, _ := catalog.FromGID(catalog.KindAlbum, gid(2))
+	if failed := it.Failed(); len(failed) != 1 || failed[missing] == nil {
+		t.Errorf("failed %v, want only %v", failed, missing)
+	}
+
+	// Failing to fetch the artist stops the walk
+	id, _ := catalog.FromGID(catalog.KindArtist, gid(0xa1))
+	it = catalog.Discography(catalog.NewSource(a.srv), id, catalog.DiscographyOpts{})
+	if it.Next() || it.Err() == nil {
+		t.Error("no error for a missing artist")
+	}
+}
@@ -0,0 +1,321 @@
+package catalog
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+)
+
+// External ID types as they appear in Spotify.ExternalId.typ
+const (
+	ExtISRC = "isrc"
+	ExtUPC  = "upc"
+)
+
+// ExternalIndex maps ISRCs to tracks and UPCs to albums.  It is safe for concurrent use.
+type ExternalIndex struct {
+	mu   sync.RWMutex
+	isrc map[string][]ID
+	upc  map[string][]ID
+}
+
+// NewExternalIndex returns an empty index.
+func NewExternalIndex() *ExternalIndex {
+	return &ExternalIndex{
+		isrc: make(map[string][]ID),
+		upc:  make(map[string][]ID),
+	}
+}
+
+// NormalizeISRC uppercases an ISRC and strips separators, e.g. "us-rc1-76-07839" => "USRC17607839".
+func NormalizeISRC(isrc string) string {
+	return strings.ToUpper(stripSeparators(isrc))
+}
+
+// NormalizeUPC strips separators and leading zeros so UPC-A and EAN-13 forms of a code match.
+func NormalizeUPC(upc string) string {
+	return strings.TrimLeft(stripSeparators(upc), "0")
+}
+
+func stripSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '.' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+func addUnique(m map[string][]ID, key string, id ID) {
+	for _, existing := range m[key] {
+		if existing == id {
+			return
+		}
+	}
+	m[key] = append(m[key], id)
+}
+
+// AddTrack indexes the ISRCs of a track.
+func (x *ExternalIndex) AddTrack(t *Spotify.Track) {
+	id, err := FromGID(KindTrack, t.GetGid())
+	if err != nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, ext := range t.GetExternalId() {
+		if strings.EqualFold(ext.GetTyp(), ExtISRC) && ext.GetId() != "" {
+			addUnique(x.isrc, NormalizeISRC(ext.GetId()), id)
+		}
+	}
+}
+
+// AddAlbum indexes the UPCs of an album.
+func (x *ExternalIndex) AddAlbum(a *Spotify.Album) {
+	id, err := FromGID(KindAlbum, a.GetGid())
+	if err != nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, ext := range a.GetExternalId() {
+		if strings.EqualFold(ext.GetTyp(), ExtUPC) && ext.GetId() != "" {
+			addUnique(x.upc, NormalizeUPC(ext.GetId()), id)
+		}
+	}
+}
+
+// AddGraph indexes every track and album of a hydrated graph.
+func (x *ExternalIndex) AddGraph(g *Graph) {
+	for _, t := range g.Tracks {
+		x.AddTrack(t)
+	}
+	for _, a := range g.Albums {
+		x.AddAlbum(a)
+	}
+}
+
+// TracksByISRC returns the indexed tracks with the given ISRC.
+func (x *ExternalIndex) TracksByISRC(isrc string) []ID {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return append([]ID(nil), x.isrc[NormalizeISRC(isrc)]...)
+}
+
+// AlbumsByUPC returns the indexed albums with the given UPC.
+func (x *ExternalIndex) AlbumsByUPC(upc string) []ID {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return append([]ID(nil), x.upc[NormalizeUPC(upc)]...)
+}
+
+type externalIndexFile struct {
+	ISRC map[string][]ID `json:"isrc"`
+	UPC  map[string][]ID `json:"upc"`
+}
+
+// Save writes the index as JSON so it can be restored with Load.
+func (x *ExternalIndex) Save(w io.Writer) error {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return json.NewEncoder(w).Encode(externalIndexFile{ISRC: x.isrc, UPC: x.upc})
+}
+
+// Load merges an index previously written by Save.
+func (x *ExternalIndex) Load(r io.Reader) error {
+	var file externalIndexFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return errors.Wrap(err, "loading external id index")
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for code, ids := range file.ISRC {
+		for _, id := range ids {
+			addUnique(x.isrc, NormalizeISRC(code), id)
+		}
+	}
+	for code, ids := range file.UPC {
+		for _, id := range ids {
+			addUnique(x.upc, NormalizeUPC(code), id)
+		}
+	}
+	return nil
+}
+
+// IndexingSource returns a Source that adds everything fetched through src to x.
+// The returned Source is a BatchSource if src is.
+func (x *ExternalIndex) IndexingSource(src Source) Source {
+	is := &indexingSource{Source: src, index: x}
+	if batch, ok := src.(BatchSource); ok {
+		return &indexingBatchSource{is, batch}
+	}
+	return is
+}
+
+type indexingSource struct {
+	Source
+	index *ExternalIndex
+}
+
+type indexingBatchSource struct {
+	*indexingSource
+	batch BatchSource
+}
+
+func (src *indexingBatchSource) GetTracks(ids []ID) ([]*Spotify.Track, error) {
+	tracks, err := src.batch.GetTracks(ids)
+	for _, t := range tracks {
+		if t != nil {
+			src.index.AddTrack(t)
+		}
+	}
+	return tracks, err
+}
+
+func (src *indexingBatchSource) GetAlbums(ids []ID) ([]*Spotify.Album, error) {
+	albums, err := src.batch.GetAlbums(ids)
+	for _, a := range albums {
+		if a != nil {
+			src.index.AddAlbum(a)
+		}
+	}
+	return albums, err
+}
+
+func (src *indexingBatchSource) GetArtists(ids []ID) ([]*Spotify.Artist, error) {
+	return src.batch.GetArtists(ids)
+}
+
+func (src *indexingSource) GetTrack(id ID) (*Spotify.Track, error) {
+	t, err := src.Source.GetTrack(id)
+	if err == nil {
+		src.index.AddTrack(t)
+	}
+	return t, err
+}
+
+func (src *indexingSource) GetAlbum(id ID) (*Spotify.Album, error) {
+	a, err := src.Source.GetAlbum(id)
+	if err == nil {
+		src.index.AddAlbum(a)
+	}
+	return a, err
+}
+
+func (src *indexingSource) GetShow(id ID) (*Spotify.Show, error) {
+	if ps, ok := src.Source.(PodcastSource); ok {
+		return ps.GetShow(id)
+	}
+	return nil, errors.ErrUnsupported
+}
+
+func (src *indexingSource) GetEpisode(id ID) (*Spotify.Episode, error) {
+	if ps, ok := src.Source.(PodcastSource); ok {
+		return ps.GetEpisode(id)
+	}
+	return nil, errors.ErrUnsupported
+}
+
+// SearchFunc runs a catalog search for query and returns the IDs of the hits of the given kind.
+type SearchFunc func(query string, kind Kind) ([]ID, error)
+
+// ExternalFinder looks up tracks by ISRC and albums by UPC, consulting Index
+// before falling back to a catalog search with "isrc:" / "upc:" query syntax.
+type ExternalFinder struct {
+	Index  *ExternalIndex
+	Source Source
+	Search SearchFunc // optional; without it only the index is consulted
+}
+
+// FindByISRC returns the tracks with the given ISRC, or errors.Err404 if there are none.
+func (f *ExternalFinder) FindByISRC(isrc string) ([]*Spotify.Track, error) {
+	isrc = NormalizeISRC(isrc)
+	ids := f.Index.TracksByISRC(isrc)
+	if len(ids) == 0 && f.Search != nil {
+		hits, err := f.Search(ExtISRC+":"+isrc, KindTrack)
+		if err != nil {
+			return nil, err
+		}
+		ids = hits
+	}
+
+	var tracks []*Spotify.Track
+	for _, id := range ids {
+		t, err := f.Source.GetTrack(id)
+		if err != nil {
+			return nil, err
+		}
+		// Search may be fuzzy, so only keep exact matches
+		if hasExternalID(t.GetExternalId(), ExtISRC, isrc, NormalizeISRC) {
+			f.Index.AddTrack(t)
+			tracks = append(tracks, t)
+		}
+	}
+	if len(tracks) == 0 {
+		return nil, errors.Wrapf(errors.Err404, "isrc %s", isrc)
+	}
+	return tracks, nil
+}
+
+// FindByUPC returns the albums with the given UPC, or errors.Err404 if there are none.
+//
+// The search is for the code as given (a 12-digit UPC-A or 13-digit EAN-13),
+// and for its EAN-13 form if a UPC-A finds nothing, since the search backend
+// matches codes literally.  Leading zeros only stop mattering when comparing.
+// A query only counts as finding something if one of its hits carries the code.
+func (f *ExternalFinder) FindByUPC(code string) ([]*Spotify.Album, error) {
+	query := stripSeparators(code)
+	upc := NormalizeUPC(code)
+	albums, err := f.albumsWithUPC(f.Index.AlbumsByUPC(upc), upc)
+	if err == nil && len(albums) == 0 && f.Search != nil {
+		queries := []string{query}
+		if len(query) == 12 {
+			queries = append(queries, "0"+query)
+		}
+		for _, q := range queries {
+			hits, searchErr := f.Search(ExtUPC+":"+q, KindAlbum)
+			if searchErr != nil {
+				return nil, searchErr
+			}
+			if albums, err = f.albumsWithUPC(hits, upc); err != nil || len(albums) > 0 {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(albums) == 0 {
+		return nil, errors.Wrapf(errors.Err404, "upc %s", query)
+	}
+	return albums, nil
+}
+
+// albumsWithUPC fetches the given albums and keeps (and indexes) those carrying upc.
+func (f *ExternalFinder) albumsWithUPC(ids []ID, upc string) ([]*Spotify.Album, error) {
+	var albums []*Spotify.Album
+	for _, id := range ids {
+		a, err := f.Source.GetAlbum(id)
+		if err != nil {
+			return nil, err
+		}
+		if hasExternalID(a.GetExternalId(), ExtUPC, upc, NormalizeUPC) {
+			f.Index.AddAlbum(a)
+			albums = append(albums, a)
+		}
+	}
+	return albums, nil
+}
+
+func hasExternalID(ids []*Spotify.ExternalId, typ, code string, normalize func(string) string) bool {
+	for _, ext := range ids {
+		if strings.EqualFold(ext.GetTyp(), typ) && normalize(ext.GetId()) == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package catalog_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/golang/protobuf/proto"
+)
+
+func extIDs(typ string, codes ...string) []*Spotify.ExternalId {
+	var ids []*Spotify.ExternalId
+	for _, code := range codes {
+		ids = append(ids, &Spotify.ExternalId{Typ: proto.String(typ), Id: proto.String(code)})
+	}
+	return ids
+}
+
+func idOf(kind catalog.Kind, b byte) catalog.ID {
+	id, _ := catalog.FromGID(kind, gid(b))
+	return id
+}
+
+// fakeSearch answers queries from a fixed table and records them.
+type fakeSearch struct {
+	hits    map[string][]catalog.ID
+	queries []string
+}
+
+func (s *fakeSearch) search(query string, kind catalog.Kind) ([]catalog.ID, error) {
+	s.queries = append(s.queries, query)
+	return s.hits[query], nil
+}
+
+func TestExternalIndex(t *testing.T) {
+	x := catalog.NewExternalIndex()
+	x.AddTrack(&Spotify.Track{Gid: gid(1), ExternalId: extIDs("ISRC", "us-rc1-76-07839")})
+	x.AddTrack(&Spotify.Track{Gid: gid(2), ExternalId: extIDs(catalog.ExtISRC, "USRC17607839")})
+	x.AddTrack(&Spotify.Track{Gid: gid(2), ExternalId: extIDs(catalog.ExtISRC, "USRC17607839")})
+	x.AddAlbum(&Spotify.Album{Gid: gid(3), ExternalId: extIDs(catalog.ExtUPC, "0602547924001")})
+
+	want := []catalog.ID{idOf(catalog.KindTrack, 1), idOf(catalog.KindTrack, 2)}
+	if got := x.TracksByISRC("usrc17607839"); !reflect.DeepEqual(got, want) {
+		t.Errorf("TracksByISRC gave %v, want %v", got, want)
+	}
+	// UPC-A and EAN-13 forms are the same code
+	wantAlbums := []catalog.ID{idOf(catalog.KindAlbum, 3)}
+	if got := x.AlbumsByUPC("602547924001"); !reflect.DeepEqual(got, wantAlbums) {
+		t.Errorf("AlbumsByUPC gave %v, want %v", got, wantAlbums)
+	}
+
+	var buf bytes.Buffer
+	if err := x.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	y := catalog.NewExternalIndex()
+	y.AddTrack(&Spotify.Track{Gid: gid(4), ExternalId: extIDs(catalog.ExtISRC, "USRC17607839")})
+	if err := y.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want = append([]catalog.ID{idOf(catalog.KindTrack, 4)}, want...)
+	if got := y.TracksByISRC("USRC17607839"); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded TracksByISRC gave %v, want %v", got, want)
+	}
+	if got := y.AlbumsByUPC("0602547924001"); !reflect.DeepEqual(got, wantAlbums) {
+		t.Errorf("loaded AlbumsByUPC gave %v, want %v", got, wantAlbums)
+	}
+	if err := y.Load(bytes.NewBufferString("{")); err == nil {
+		t.Error("loaded a truncated index")
+	}
+}
+
+func TestIndexingSource(t *testing.T) {
+	srv := mercurytest.New()
+	srv.HandleTrack(idOf(catalog.KindTrack, 1).Hex(), &Spotify.Track{Gid: gid(1), ExternalId: extIDs(catalog.ExtISRC, "GBAYE0601498")})
+	srv.HandleAlbum(idOf(catalog.KindAlbum, 2).Hex(), &Spotify.Album{Gid: gid(2), ExternalId: extIDs(catalog.ExtUPC, "724384960650")})
+
+	x := catalog.NewExternalIndex()
+	src := x.IndexingSource(catalog.NewSource(srv))
+	batch, ok := src.(catalog.BatchSource)
+	if !ok {
+		t.Fatal("indexing source over a batch source is not a BatchSource")
+	}
+	if _, err := batch.GetTracks([]catalog.ID{idOf(catalog.KindTrack, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.GetAlbum(idOf(catalog.KindAlbum, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if got := x.TracksByISRC("GBAYE0601498"); len(got) != 1 {
+		t.Errorf("batched track was not indexed: %v", got)
+	}
+	if got := x.AlbumsByUPC("0724384960650"); len(got) != 1 {
+		t.Errorf("album was not indexed: %v", got)
+	}
+}
+
+func TestFindByISRC(t *testing.T) {
+	srv := mercurytest.New()
+	srv.HandleTrack(idOf(catalog.KindTrack, 1).Hex(), &Spotify.Track{Gid: gid(1), ExternalId: extIDs(catalog.ExtISRC, "USRC17607839")})
+	srv.HandleTrack(idOf(catalog.KindTrack, 2).Hex(), &Spotify.Track{Gid: gid(2), ExternalId: extIDs(catalog.ExtISRC, "USRC17607840")})
+	search := &fakeSearch{hits: map[string][]catalog.ID{
+		"isrc:USRC17607839": {idOf(catalog.KindTrack, 2), idOf(catalog.KindTrack, 1)},
+	}}
+	f := catalog.ExternalFinder{Index: catalog.NewExternalIndex(), Source: catalog.NewSource(srv), Search: search.search}
+
+	// Fuzzy search hits are dropped
+	tracks, err := f.FindByISRC("us-rc1-76-07839")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || !bytes.Equal(tracks[0].GetGid(), gid(1)) {
+		t.Errorf("found %v", tracks)
+	}
+
+	// The second lookup is answered from the index
+	if _, err = f.FindByISRC("USRC17607839"); err != nil {
+		t.Fatal(err)
+	}
+	if len(search.queries) != 1 {
+		t.Errorf("searched %v", search.queries)
+	}
+
+	if _, err = f.FindByISRC("USRC17600000"); errors.Cause(err) != errors.Err404 {
+		t.Errorf("unknown ISRC gave %v", err)
+	}
+}
+
+func TestFindByUPC(t *testing.T) {
+	srv := mercurytest.New()
+	srv.HandleAlbum(idOf(catalog.KindAlbum, 1).Hex(), &Spotify.Album{Gid: gid(1), ExternalId: extIDs(catalog.ExtUPC, "0724384960650")})
+	srv.HandleAlbum(idOf(catalog.KindAlbum, 2).Hex(), &Spotify.Album{Gid: gid(2), ExternalId: extIDs(catalog.ExtUPC, "724384960667")})
+	search := &fakeSearch{hits: map[string][]catalog.ID{
+		// The literal UPC-A query only matches another code fuzzily
+		"upc:724384960650":  {idOf(catalog.KindAlbum, 2)},
+		"upc:0724384960650": {idOf(catalog.KindAlbum, 1)},
+	}}
+	f := catalog.ExternalFinder{Index: catalog.NewExternalIndex(), Source: catalog.NewSource(srv), Search: search.search}
+
+	albums, err := f.FindByUPC("7 24384 96065 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(albums) != 1 || !bytes.Equal(albums[0].GetGid(), gid(1)) {
+		t.Errorf("found %v", albums)
+	}
+	if want := []string{"upc:724384960650", "upc:0724384960650"}; !reflect.DeepEqual(search.queries, want) {
+		t.Errorf("searched %v, want %v", search.queries, want)
+	}
+
+	// An EAN-13 is only searched as given, and later found through the index
+	search.queries = nil
+	if _, err = f.FindByUPC("0724384960650"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.FindByUPC("0000000000000"); errors.Cause(err) != errors.Err404 {
+		t.Errorf("unknown UPC gave %v", err)
+	}
+	if want := []string{"upc:0000000000000"}; !reflect.DeepEqual(search.queries, want) {
+		t.Errorf("searched %v, want %v", search.queries, want)
+	}
+
+	// Without a search only the index is consulted
+	f.Search = nil
+	if _, err = f.FindByUPC("724384960667"); errors.Cause(err) != errors.Err404 {
+		t.Errorf("unindexed UPC gave %v", err)
+	}
+}
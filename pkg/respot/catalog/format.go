@@ -0,0 +1,176 @@
+package catalog
+
+import (
+	"strings"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+)
+
+// ErrNoAcceptableFormat is returned when neither a track nor any of its alternatives has a file the policy accepts.
+var ErrNoAcceptableFormat = errors.New("no audio file in an acceptable format")
+
+// FormatPolicy picks which of a track's audio files to stream.
+type FormatPolicy struct {
+	// Prefer lists acceptable formats, most preferred first.  Formats not listed are never chosen.
+	Prefer []Spotify.AudioFile_Format
+
+	// Premium must be set for formats that require a premium account (320 kbps) to be chosen.
+	Premium bool
+}
+
+// PolicyDefault favours 160 kbps Vorbis, the quality every account can stream.
+func PolicyDefault() FormatPolicy {
+	return FormatPolicy{
+		Prefer: []Spotify.AudioFile_Format{
+			Spotify.AudioFile_OGG_VORBIS_160,
+			Spotify.AudioFile_OGG_VORBIS_96,
+			Spotify.AudioFile_OGG_VORBIS_320,
+		},
+	}
+}
+
+// PolicyLowBandwidth favours 96 kbps Vorbis for constrained devices.
+func PolicyLowBandwidth() FormatPolicy {
+	return FormatPolicy{
+		Prefer: []Spotify.AudioFile_Format{
+			Spotify.AudioFile_OGG_VORBIS_96,
+			Spotify.AudioFile_OGG_VORBIS_160,
+		},
+	}
+}
+
+// PolicyArchive favours the highest available quality.
+func PolicyArchive() FormatPolicy {
+	return FormatPolicy{
+		Prefer: []Spotify.AudioFile_Format{
+			Spotify.AudioFile_OGG_VORBIS_320,
+			Spotify.AudioFile_MP3_320,
+			Spotify.AudioFile_OGG_VORBIS_160,
+			Spotify.AudioFile_MP3_256,
+			Spotify.AudioFile_MP3_160,
+			Spotify.AudioFile_OGG_VORBIS_96,
+			Spotify.AudioFile_MP3_96,
+		},
+		Premium: true,
+	}
+}
+
+// ParseFormatPolicy parses "default", "low", "archive" or a comma separated
+// list of format names such as "OGG_VORBIS_320,OGG_VORBIS_160".
+// The returned policy has Premium set to premium.
+func ParseFormatPolicy(s string, premium bool) (FormatPolicy, error) {
+	var policy FormatPolicy
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "default":
+		policy = PolicyDefault()
+	case "low":
+		policy = PolicyLowBandwidth()
+	case "archive":
+		policy = PolicyArchive()
+	default:
+		for _, name := range strings.Split(s, ",") {
+			format, ok := Spotify.AudioFile_Format_value[strings.ToUpper(strings.TrimSpace(name))]
+			if !ok {
+				return FormatPolicy{}, errors.Errorf("unknown audio format %q", name)
+			}
+			policy.Prefer = append(policy.Prefer, Spotify.AudioFile_Format(format))
+		}
+	}
+	policy.Premium = premium
+	return policy, nil
+}
+
+// FormatBitrate returns the nominal bitrate of a format in kbps, or 0 if unknown.
+func FormatBitrate(format Spotify.AudioFile_Format) int {
+	switch format {
+	case Spotify.AudioFile_OGG_VORBIS_96, Spotify.AudioFile_MP3_96:
+		return 96
+	case Spotify.AudioFile_OGG_VORBIS_160, Spotify.AudioFile_MP3_160, Spotify.AudioFile_MP3_160_ENC:
+		return 160
+	case Spotify.AudioFile_MP3_256:
+		return 256
+	case Spotify.AudioFile_OGG_VORBIS_320, Spotify.AudioFile_MP3_320:
+		return 320
+	case Spotify.AudioFile_AAC_24, Spotify.AudioFile_AAC_24_NORM:
+		return 24
+	case Spotify.AudioFile_AAC_48:
+		return 48
+	}
+	return 0
+}
+
+// Allows reports whether the policy may choose format.
+func (p FormatPolicy) Allows(format Spotify.AudioFile_Format) bool {
+	if FormatBitrate(format) >= 320 && !p.Premium {
+		return false
+	}
+	for _, f := range p.Prefer {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Choose returns the most preferred acceptable file among files, or nil if there is none.
+func (p FormatPolicy) Choose(files []*Spotify.AudioFile) *Spotify.AudioFile {
+	for _, format := range p.Prefer {
+		if !p.Allows(format) {
+			continue
+		}
+		for _, file := range files {
+			if file.GetFormat() == format && len(file.GetFileId()) > 0 {
+				return file
+			}
+		}
+	}
+	return nil
+}
+
+// SelectFile returns the track and file to stream for track under the policy.
+// If track itself has no acceptable file, its alternatives are tried in order,
+// skipping those that are not playable in country (see IsPlayable);
+// alternatives that are only stubs are fetched from src (which may be nil to skip that).
+func (p FormatPolicy) SelectFile(src Source, track *Spotify.Track, country, catalogue string) (*Spotify.Track, *Spotify.AudioFile, error) {
+	if file := p.Choose(track.GetFile()); file != nil {
+		return track, file, nil
+	}
+	for _, alt := range track.GetAlternative() {
+		if len(alt.GetFile()) == 0 && src != nil {
+			id, err := FromGID(KindTrack, alt.GetGid())
+			if err != nil {
+				continue
+			}
+			if alt, err = src.GetTrack(id); err != nil {
+				continue
+			}
+		}
+		if !IsPlayable(alt, country, catalogue) {
+			continue
+		}
+		if file := p.Choose(alt.GetFile()); file != nil {
+			return alt, file, nil
+		}
+	}
+	return nil, nil, errors.Wrapf(ErrNoAcceptableFormat, "track %x", track.GetGid())
+}
+
+// IsPremiumProduct reports whether an account's product type, as sent by the
+// AP in its product info after login, can stream premium formats.
+func IsPremiumProduct(productType string) bool {
+	switch strings.ToLower(productType) {
+	case "premium", "unlimited":
+		return true
+	}
+	return false
+}
+
+// ProductCatalogue returns the catalogue ("premium" or "free") that restrictions
+// are evaluated against for an account's product type.
+func ProductCatalogue(productType string) string {
+	if IsPremiumProduct(productType) {
+		return "premium"
+	}
+	return "free"
+}
@@ -0,0 +1,93 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestSelectFileSkipsUnplayableAlternatives(t *testing.T) {
+	file := func(id byte, format Spotify.AudioFile_Format) *Spotify.AudioFile {
+		return &Spotify.AudioFile{FileId: []byte{id}, Format: format.Enum()}
+	}
+	track := &Spotify.Track{
+		Name: proto.String("only MP3"),
+		File: []*Spotify.AudioFile{file(1, Spotify.AudioFile_MP3_160)},
+		Alternative: []*Spotify.Track{
+			{
+				Name: proto.String("forbidden here"),
+				File: []*Spotify.AudioFile{file(2, Spotify.AudioFile_OGG_VORBIS_160)},
+				Restriction: []*Spotify.Restriction{{
+					CountriesForbidden: proto.String("SE"),
+					Typ:                Spotify.Restriction_STREAMING.Enum(),
+				}},
+			},
+			{
+				Name: proto.String("playable"),
+				File: []*Spotify.AudioFile{file(3, Spotify.AudioFile_OGG_VORBIS_96)},
+			},
+		},
+	}
+
+	tests := []struct {
+		country string
+		want    byte
+	}{
+		{"SE", 3},
+		{"DE", 2},
+		{"", 2}, // unknown country passes every country list
+	}
+	for _, tt := range tests {
+		_, got, err := PolicyDefault().SelectFile(nil, track, tt.country, "")
+		if err != nil {
+			t.Errorf("%q: %v", tt.country, err)
+			continue
+		}
+		if got.GetFileId()[0] != tt.want {
+			t.Errorf("%q: chose file %x, want %x", tt.country, got.GetFileId(), tt.want)
+		}
+	}
+}
+
+func TestPolicyPremium(t *testing.T) {
+	files := []*Spotify.AudioFile{
+		{FileId: []byte{1}, Format: Spotify.AudioFile_OGG_VORBIS_320.Enum()},
+		{FileId: []byte{2}, Format: Spotify.AudioFile_OGG_VORBIS_160.Enum()},
+	}
+	for _, tt := range []struct {
+		product string
+		want    byte
+	}{
+		{"premium", 1},
+		{"free", 2},
+		{"", 2},
+	} {
+		policy, err := ParseFormatPolicy("archive", IsPremiumProduct(tt.product))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := policy.Choose(files); got.GetFileId()[0] != tt.want {
+			t.Errorf("%q account got file %x, want %x", tt.product, got.GetFileId(), tt.want)
+		}
+	}
+}
+
+func TestPoliciesAreFresh(t *testing.T) {
+	p := PolicyArchive()
+	p.Prefer[0] = Spotify.AudioFile_MP3_96
+	p.Premium = false
+	if q := PolicyArchive(); q.Prefer[0] != Spotify.AudioFile_OGG_VORBIS_320 || !q.Premium {
+		t.Errorf("changing a policy changed the next one: %v", q)
+	}
+	parsed, err := ParseFormatPolicy("archive", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Premium || parsed.Prefer[0] != Spotify.AudioFile_OGG_VORBIS_320 {
+		t.Errorf("parsed %v", parsed)
+	}
+	if _, err = ParseFormatPolicy("OGG_VORBIS_320,FLAC", true); err == nil {
+		t.Error("parsed an unknown format")
+	}
+}
@@ -0,0 +1,337 @@
+package catalog
+
+import (
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+)
+
+// BatchSource is implemented by a Source that can fetch many items per request
+// (e.g. via a Mercury multi-get).  Results are returned in the order of ids.
+type BatchSource interface {
+	Source
+	GetTracks(ids []ID) ([]*Spotify.Track, error)
+	GetAlbums(ids []ID) ([]*Spotify.Album, error)
+	GetArtists(ids []ID) ([]*Spotify.Artist, error)
+}
+
+// HydrateOpts bounds the work done by HydrateAlbum and HydrateArtist.
+type HydrateOpts struct {
+	Workers   int // max concurrent fetches (default 8)
+	Depth     int // how many reference hops to follow from the root (default 1)
+	BatchSize int // max items per batched fetch when the Source is a BatchSource (default 50)
+}
+
+func (opts *HydrateOpts) normalize() {
+	if opts.Workers <= 0 {
+		opts.Workers = 8
+	}
+	if opts.Depth <= 0 {
+		opts.Depth = 1
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 50
+	}
+}
+
+// Graph holds fully fetched metadata keyed by ID.  Nested messages in the
+// stored objects remain GID stubs; use the Graph to resolve them.
+type Graph struct {
+	Root    ID
+	Tracks  map[ID]*Spotify.Track
+	Albums  map[ID]*Spotify.Album
+	Artists map[ID]*Spotify.Artist
+	Errors  map[ID]error // items that failed to fetch
+
+	mu sync.Mutex
+}
+
+func newGraph(root ID) *Graph {
+	return &Graph{
+		Root:    root,
+		Tracks:  make(map[ID]*Spotify.Track),
+		Albums:  make(map[ID]*Spotify.Album),
+		Artists: make(map[ID]*Spotify.Artist),
+		Errors:  make(map[ID]error),
+	}
+}
+
+// Track returns the full track for the given stub (or nil if it was not fetched).
+func (g *Graph) Track(stub *Spotify.Track) *Spotify.Track {
+	id, _ := FromGID(KindTrack, stub.GetGid())
+	return g.Tracks[id]
+}
+
+// Album returns the full album for the given stub (or nil if it was not fetched).
+func (g *Graph) Album(stub *Spotify.Album) *Spotify.Album {
+	id, _ := FromGID(KindAlbum, stub.GetGid())
+	return g.Albums[id]
+}
+
+// Artist returns the full artist for the given stub (or nil if it was not fetched).
+func (g *Graph) Artist(stub *Spotify.Artist) *Spotify.Artist {
+	id, _ := FromGID(KindArtist, stub.GetGid())
+	return g.Artists[id]
+}
+
+// AlbumTracks returns the fetched tracks of an album in disc order, skipping any that failed.
+func (g *Graph) AlbumTracks(album *Spotify.Album) []*Spotify.Track {
+	var tracks []*Spotify.Track
+	for _, disc := range album.GetDisc() {
+		for _, stub := range disc.GetTrack() {
+			if t := g.Track(stub); t != nil {
+				tracks = append(tracks, t)
+			}
+		}
+	}
+	return tracks
+}
+
+// ArtistTopTracks returns the fetched top tracks of an artist for the given country,
+// or for the first listed country if country is empty or not present.
+func (g *Graph) ArtistTopTracks(artist *Spotify.Artist, country string) []*Spotify.Track {
+	var top *Spotify.TopTracks
+	for _, tt := range artist.GetTopTrack() {
+		if top == nil || tt.GetCountry() == country {
+			top = tt
+		}
+	}
+	var tracks []*Spotify.Track
+	for _, stub := range top.GetTrack() {
+		if t := g.Track(stub); t != nil {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// ArtistAlbums returns the fetched albums listed in an artist's album_group.
+func (g *Graph) ArtistAlbums(artist *Spotify.Artist) []*Spotify.Album {
+	var albums []*Spotify.Album
+	for _, ag := range artist.GetAlbumGroup() {
+		for _, stub := range ag.GetAlbum() {
+			if a := g.Album(stub); a != nil {
+				albums = append(albums, a)
+			}
+		}
+	}
+	return albums
+}
+
+// HydrateAlbum fetches an album along with its tracks and artists (and their
+// references, up to opts.Depth hops) into a single Graph.
+// An error is only returned if the album itself can't be fetched; see Graph.Errors otherwise.
+func HydrateAlbum(src Source, albumID ID, opts HydrateOpts) (*Graph, error) {
+	if err := expectKind(albumID, KindAlbum); err != nil {
+		return nil, err
+	}
+	return hydrate(src, albumID, opts)
+}
+
+// HydrateArtist fetches an artist along with its top tracks and album_group albums
+// (and their references, up to opts.Depth hops) into a single Graph.
+// An error is only returned if the artist itself can't be fetched; see Graph.Errors otherwise.
+func HydrateArtist(src Source, artistID ID, opts HydrateOpts) (*Graph, error) {
+	if err := expectKind(artistID, KindArtist); err != nil {
+		return nil, err
+	}
+	return hydrate(src, artistID, opts)
+}
+
+func hydrate(src Source, root ID, opts HydrateOpts) (*Graph, error) {
+	opts.normalize()
+	g := newGraph(root)
+	h := &hydrator{src: src, opts: opts, graph: g}
+
+	h.fetch([]ID{root})
+	if err := g.Errors[root]; err != nil {
+		return nil, err
+	}
+
+	level := []ID{root}
+	for depth := 0; depth < opts.Depth && len(level) > 0; depth++ {
+		var next []ID
+		seen := make(map[ID]struct{})
+		for _, id := range level {
+			for _, ref := range g.refs(id) {
+				if _, dup := seen[ref]; dup || g.has(ref) {
+					continue
+				}
+				seen[ref] = struct{}{}
+				next = append(next, ref)
+			}
+		}
+		h.fetch(next)
+		level = next
+	}
+	return g, nil
+}
+
+func (g *Graph) has(id ID) bool {
+	if _, failed := g.Errors[id]; failed {
+		return true
+	}
+	switch id.kind {
+	case KindTrack:
+		return g.Tracks[id] != nil
+	case KindAlbum:
+		return g.Albums[id] != nil
+	case KindArtist:
+		return g.Artists[id] != nil
+	}
+	return false
+}
+
+// refs returns the IDs that the given (already fetched) item references.
+func (g *Graph) refs(id ID) []ID {
+	var refs []ID
+	add := func(kind Kind, gid []byte) {
+		if ref, err := FromGID(kind, gid); err == nil {
+			refs = append(refs, ref)
+		}
+	}
+	switch id.kind {
+	case KindTrack:
+		t := g.Tracks[id]
+		add(KindAlbum, t.GetAlbum().GetGid())
+		for _, a := range t.GetArtist() {
+			add(KindArtist, a.GetGid())
+		}
+	case KindAlbum:
+		a := g.Albums[id]
+		for _, disc := range a.GetDisc() {
+			for _, t := range disc.GetTrack() {
+				add(KindTrack, t.GetGid())
+			}
+		}
+		for _, artist := range a.GetArtist() {
+			add(KindArtist, artist.GetGid())
+		}
+	case KindArtist:
+		a := g.Artists[id]
+		for _, tt := range a.GetTopTrack() {
+			for _, t := range tt.GetTrack() {
+				add(KindTrack, t.GetGid())
+			}
+		}
+		for _, ag := range a.GetAlbumGroup() {
+			for _, album := range ag.GetAlbum() {
+				add(KindAlbum, album.GetGid())
+			}
+		}
+	}
+	return refs
+}
+
+type hydrator struct {
+	src   Source
+	opts  HydrateOpts
+	graph *Graph
+}
+
+// fetch retrieves ids into the graph using a bounded pool of workers.
+func (h *hydrator) fetch(ids []ID) {
+	var jobs [][]ID
+	if _, ok := h.src.(BatchSource); ok {
+		byKind := make(map[Kind][]ID)
+		for _, id := range ids {
+			byKind[id.kind] = append(byKind[id.kind], id)
+		}
+		for _, group := range byKind {
+			for len(group) > 0 {
+				n := h.opts.BatchSize
+				if n > len(group) {
+					n = len(group)
+				}
+				jobs = append(jobs, group[:n])
+				group = group[n:]
+			}
+		}
+	} else {
+		for _, id := range ids {
+			jobs = append(jobs, []ID{id})
+		}
+	}
+
+	jobCh := make(chan []ID)
+	wg := sync.WaitGroup{}
+	for i := 0; i < h.opts.Workers && i < len(jobs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				h.fetchJob(job)
+			}
+		}()
+	}
+	for _, job := range jobs {
+		jobCh <- job
+	}
+	close(jobCh)
+	wg.Wait()
+}
+
+func (h *hydrator) fetchJob(ids []ID) {
+	g := h.graph
+	if batch, ok := h.src.(BatchSource); ok && len(ids) > 1 {
+		var err error
+		var tracks []*Spotify.Track
+		var albums []*Spotify.Album
+		var artists []*Spotify.Artist
+		switch ids[0].kind {
+		case KindTrack:
+			tracks, err = batch.GetTracks(ids)
+		case KindAlbum:
+			albums, err = batch.GetAlbums(ids)
+		case KindArtist:
+			artists, err = batch.GetArtists(ids)
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		for i, id := range ids {
+			switch {
+			case err != nil:
+				g.Errors[id] = err
+			case i < len(tracks) && tracks[i] != nil:
+				g.Tracks[id] = tracks[i]
+			case i < len(albums) && albums[i] != nil:
+				g.Albums[id] = albums[i]
+			case i < len(artists) && artists[i] != nil:
+				g.Artists[id] = artists[i]
+			default:
+				g.Errors[id] = errors.Err404
+			}
+		}
+		return
+	}
+
+	for _, id := range ids {
+		var err error
+		var track *Spotify.Track
+		var album *Spotify.Album
+		var artist *Spotify.Artist
+		switch id.kind {
+		case KindTrack:
+			track, err = h.src.GetTrack(id)
+		case KindAlbum:
+			album, err = h.src.GetAlbum(id)
+		case KindArtist:
+			artist, err = h.src.GetArtist(id)
+		default:
+			err = ErrKindMismatch
+		}
+		g.mu.Lock()
+		switch {
+		case err != nil:
+			g.Errors[id] = err
+		case track != nil:
+			g.Tracks[id] = track
+		case album != nil:
+			g.Albums[id] = album
+		case artist != nil:
+			g.Artists[id] = artist
+		}
+		g.mu.Unlock()
+	}
+}
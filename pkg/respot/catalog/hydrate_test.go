@@ -0,0 +1,297 @@
+package catalog_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/golang/protobuf/proto"
+)
+
+// Catalog of testCatalog: album 1 by artist 1 has tracks 1 and 2, and track 3
+// is missing.  Artist 1 also has album 2 (tracks 4 and 5) and top track 4.
+func testCatalog() *mercurytest.Server {
+	srv := mercurytest.New()
+	artist := func(b byte) *Spotify.Artist { return &Spotify.Artist{Gid: gid(b)} }
+	album := func(b byte) *Spotify.Album { return &Spotify.Album{Gid: gid(b)} }
+	track := func(b byte) *Spotify.Track { return &Spotify.Track{Gid: gid(b)} }
+
+	srv.HandleArtist(idOf(catalog.KindArtist, 1).Hex(), &Spotify.Artist{
+		Gid:        gid(1),
+		Name:       proto.String("Artist"),
+		TopTrack:   []*Spotify.TopTracks{{Country: proto.String("SE"), Track: []*Spotify.Track{track(4)}}},
+		AlbumGroup: []*Spotify.AlbumGroup{{Album: []*Spotify.Album{album(1), album(2)}}},
+	})
+	srv.HandleAlbum(idOf(catalog.KindAlbum, 1).Hex(), &Spotify.Album{
+		Gid:    gid(1),
+		Name:   proto.String("One"),
+		Artist: []*Spotify.Artist{artist(1)},
+		Disc:   []*Spotify.Disc{{Track: []*Spotify.Track{track(2), track(1)}}, {Track: []*Spotify.Track{track(3)}}},
+	})
+	srv.HandleAlbum(idOf(catalog.KindAlbum, 2).Hex(), &Spotify.Album{
+		Gid:    gid(2),
+		Name:   proto.String("Two"),
+		Artist: []*Spotify.Artist{artist(1)},
+		Disc:   []*Spotify.Disc{{Track: []*Spotify.Track{track(4), track(5)}}},
+	})
+	for b, a := range map[byte]byte{1: 1, 2: 1, 4: 2, 5: 2} {
+		srv.HandleTrack(idOf(catalog.KindTrack, b).Hex(), &Spotify.Track{
+			Gid:    gid(b),
+			Name:   proto.String(string('0' + b)),
+			Album:  album(a),
+			Artist: []*Spotify.Artist{artist(1)},
+		})
+	}
+	return srv
+}
+
+func graphIDs(g *catalog.Graph) []string {
+	var ids []string
+	for id := range g.Tracks {
+		ids = append(ids, id.String())
+	}
+	for id := range g.Albums {
+		ids = append(ids, id.String())
+	}
+	for id := range g.Artists {
+		ids = append(ids, id.String())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func idStrings(ids ...catalog.ID) []string {
+	var out []string
+	for _, id := range ids {
+		out = append(out, id.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHydrateDepth(t *testing.T) {
+	album1 := idOf(catalog.KindAlbum, 1)
+	for _, tt := range []struct {
+		depth int
+		want  []catalog.ID
+	}{
+		// The album, its tracks and artist
+		{1, []catalog.ID{album1, idOf(catalog.KindTrack, 1), idOf(catalog.KindTrack, 2), idOf(catalog.KindArtist, 1)}},
+		// and the artist's other album and top track
+		{2, []catalog.ID{album1, idOf(catalog.KindTrack, 1), idOf(catalog.KindTrack, 2), idOf(catalog.KindArtist, 1),
+			idOf(catalog.KindAlbum, 2), idOf(catalog.KindTrack, 4)}},
+		// and that album's remaining track
+		{3, []catalog.ID{album1, idOf(catalog.KindTrack, 1), idOf(catalog.KindTrack, 2), idOf(catalog.KindArtist, 1),
+			idOf(catalog.KindAlbum, 2), idOf(catalog.KindTrack, 4), idOf(catalog.KindTrack, 5)}},
+	} {
+		g, err := catalog.HydrateAlbum(catalog.NewSource(testCatalog()), album1, catalog.HydrateOpts{Depth: tt.depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := graphIDs(g), idStrings(tt.want...); !equalStrings(got, want) {
+			t.Errorf("depth %d fetched %v, want %v", tt.depth, got, want)
+		}
+		if _, failed := g.Errors[idOf(catalog.KindTrack, 3)]; !failed {
+			t.Errorf("depth %d: missing track not in Errors", tt.depth)
+		}
+	}
+}
+
+func TestHydrateGraph(t *testing.T) {
+	g, err := catalog.HydrateArtist(catalog.NewSource(testCatalog()), idOf(catalog.KindArtist, 1), catalog.HydrateOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	artist := g.Artists[g.Root]
+	var names []string
+	for _, a := range g.ArtistAlbums(artist) {
+		names = append(names, a.GetName())
+	}
+	if !equalStrings(names, []string{"One", "Two"}) {
+		t.Errorf("albums %v", names)
+	}
+	if top := g.ArtistTopTracks(artist, "US"); len(top) != 1 || top[0].GetName() != "4" {
+		t.Errorf("top tracks %v", top)
+	}
+
+	// Depth 1 from the artist doesn't reach the albums' tracks
+	if tracks := g.AlbumTracks(g.Albums[idOf(catalog.KindAlbum, 1)]); len(tracks) != 0 {
+		t.Errorf("album tracks %v", tracks)
+	}
+	g, err = catalog.HydrateArtist(catalog.NewSource(testCatalog()), idOf(catalog.KindArtist, 1), catalog.HydrateOpts{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, t := range g.AlbumTracks(g.Albums[idOf(catalog.KindAlbum, 1)]) {
+		names = append(names, t.GetName())
+	}
+	if !equalStrings(names, []string{"2", "1"}) {
+		t.Errorf("album tracks %v, want disc order", names)
+	}
+	if a := g.Album(g.Tracks[idOf(catalog.KindTrack, 5)].GetAlbum()); a.GetName() != "Two" {
+		t.Errorf("track's album %v", a)
+	}
+}
+
+func TestHydrateBatches(t *testing.T) {
+	album1 := idOf(catalog.KindAlbum, 1)
+	srv := testCatalog()
+	g, err := catalog.HydrateAlbum(catalog.NewSource(srv), album1, catalog.HydrateOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The root, one multi-get for the three tracks, and the lone artist
+	reqs := srv.Requests()
+	sort.Strings(reqs)
+	want := []string{
+		mercurytest.AlbumPrefix + album1.Hex(),
+		mercurytest.ArtistPrefix + idOf(catalog.KindArtist, 1).Hex(),
+		"hm://metadata/3/tracks",
+	}
+	if !equalStrings(reqs, want) {
+		t.Errorf("requested %q, want %q", reqs, want)
+	}
+	if err := g.Errors[idOf(catalog.KindTrack, 3)]; errors.Cause(err) != errors.Err404 {
+		t.Errorf("missing track in a batch: %v", err)
+	}
+
+	// Batches of one item are plain requests
+	srv = testCatalog()
+	if _, err = catalog.HydrateAlbum(catalog.NewSource(srv), album1, catalog.HydrateOpts{BatchSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var multi, single int
+	for _, uri := range srv.Requests() {
+		if uri == "hm://metadata/3/tracks" {
+			multi++
+		} else {
+			single++
+		}
+	}
+	if multi != 1 || single != 3 {
+		t.Errorf("%d multi-gets and %d requests: %q", multi, single, srv.Requests())
+	}
+
+	// A failed batch fails each of its items
+	errDown := errors.New("down")
+	src := &failingTracks{catalog.NewSource(testCatalog()).(catalog.BatchSource), errDown}
+	if g, err = catalog.HydrateAlbum(src, album1, catalog.HydrateOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []byte{1, 2, 3} {
+		if err := g.Errors[idOf(catalog.KindTrack, b)]; err != errDown {
+			t.Errorf("track %d: %v", b, err)
+		}
+	}
+	if g.Artists[idOf(catalog.KindArtist, 1)] == nil {
+		t.Error("artist lost with the tracks' batch")
+	}
+}
+
+// failingTracks is a BatchSource whose track batches fail.
+type failingTracks struct {
+	catalog.BatchSource
+	err error
+}
+
+func (src *failingTracks) GetTracks(ids []catalog.ID) ([]*Spotify.Track, error) {
+	return nil, src.err
+}
+
+func TestHydrateErrors(t *testing.T) {
+	srv := testCatalog()
+	if _, err := catalog.HydrateAlbum(catalog.NewSource(srv), idOf(catalog.KindAlbum, 9), catalog.HydrateOpts{}); err == nil {
+		t.Error("hydrated a missing album")
+	}
+	if _, err := catalog.HydrateAlbum(catalog.NewSource(srv), idOf(catalog.KindArtist, 1), catalog.HydrateOpts{}); errors.Cause(err) != catalog.ErrKindMismatch {
+		t.Errorf("hydrated an artist as an album: %v", err)
+	}
+
+	// Without batching, each missing item fails on its own
+	src := &concurrencySource{Source: catalog.NewSource(srv)}
+	g, err := catalog.HydrateAlbum(src, idOf(catalog.KindAlbum, 1), catalog.HydrateOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Errors) != 1 || g.Errors[idOf(catalog.KindTrack, 3)] == nil || len(g.Tracks) != 2 {
+		t.Errorf("errors %v, %d tracks", g.Errors, len(g.Tracks))
+	}
+}
+
+// concurrencySource is a plain (unbatched) Source that records how many
+// fetches were in flight at once.
+type concurrencySource struct {
+	catalog.Source
+	delay time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (src *concurrencySource) enter() {
+	src.mu.Lock()
+	if src.inFlight++; src.inFlight > src.max {
+		src.max = src.inFlight
+	}
+	src.mu.Unlock()
+	time.Sleep(src.delay)
+}
+
+func (src *concurrencySource) exit() {
+	src.mu.Lock()
+	src.inFlight--
+	src.mu.Unlock()
+}
+
+func (src *concurrencySource) GetTrack(id catalog.ID) (*Spotify.Track, error) {
+	src.enter()
+	defer src.exit()
+	return src.Source.GetTrack(id)
+}
+
+func (src *concurrencySource) GetAlbum(id catalog.ID) (*Spotify.Album, error) {
+	src.enter()
+	defer src.exit()
+	return src.Source.GetAlbum(id)
+}
+
+func (src *concurrencySource) GetArtist(id catalog.ID) (*Spotify.Artist, error) {
+	src.enter()
+	defer src.exit()
+	return src.Source.GetArtist(id)
+}
+
+func TestHydrateWorkers(t *testing.T) {
+	for _, workers := range []int{1, 2} {
+		src := &concurrencySource{Source: catalog.NewSource(testCatalog()), delay: 10 * time.Millisecond}
+		g, err := catalog.HydrateAlbum(src, idOf(catalog.KindAlbum, 1), catalog.HydrateOpts{Workers: workers, Depth: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(g.Tracks) != 4 {
+			t.Errorf("fetched %d tracks", len(g.Tracks))
+		}
+		if src.max != workers {
+			t.Errorf("%d workers had %d fetches in flight", workers, src.max)
+		}
+	}
+}
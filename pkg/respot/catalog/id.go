@@ -0,0 +1,318 @@
+package catalog
+
+import (
+	"encoding/hex"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// Kind is the type of catalog entity an ID refers to.
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindTrack
+	KindAlbum
+	KindArtist
+	KindPlaylist
+	KindUser
+	KindEpisode
+	KindShow
+	KindLocal
+)
+
+var kindNames = [...]string{
+	KindUnknown:  "unknown",
+	KindTrack:    "track",
+	KindAlbum:    "album",
+	KindArtist:   "artist",
+	KindPlaylist: "playlist",
+	KindUser:     "user",
+	KindEpisode:  "episode",
+	KindShow:     "show",
+	KindLocal:    "local",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return kindNames[KindUnknown]
+	}
+	return kindNames[k]
+}
+
+// hasGID reports if IDs of this kind are backed by a 16 byte GID.
+func (k Kind) hasGID() bool {
+	switch k {
+	case KindTrack, KindAlbum, KindArtist, KindPlaylist, KindEpisode, KindShow:
+		return true
+	}
+	return false
+}
+
+func parseKind(s string) Kind {
+	for k, name := range kindNames {
+		if k != int(KindUnknown) && name == s {
+			return Kind(k)
+		}
+	}
+	return KindUnknown
+}
+
+const (
+	// GIDLen is the byte length of a Spotify GID.
+	GIDLen = 16
+
+	base62Len   = 22
+	base62Chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+var (
+	ErrBadID        = errors.New("invalid spotify id")
+	ErrKindMismatch = errors.New("spotify id is of the wrong kind")
+)
+
+// ID identifies a Spotify catalog entity and carries its kind, so that it can be
+// rendered as a URI, URL, base62, hex or raw GID without ambiguity.
+//
+// Users are identified by name rather than GID, and local files by the
+// colon-separated tail of their URI.  Playlists may carry an owner when parsed
+// from a legacy "spotify:user:<owner>:playlist:<id>" URI.
+type ID struct {
+	kind  Kind
+	gid   [GIDLen]byte
+	owner string
+	name  string
+}
+
+// FromGID returns the ID of the given kind for a raw 16 byte GID.
+func FromGID(kind Kind, gid []byte) (ID, error) {
+	if len(gid) != GIDLen {
+		return ID{}, errors.Wrapf(ErrBadID, "gid has length %d", len(gid))
+	}
+	id := ID{kind: kind}
+	copy(id.gid[:], gid)
+	return id, nil
+}
+
+// FromBase62 returns the ID of the given kind for a 22 character base62 string.
+func FromBase62(kind Kind, s string) (ID, error) {
+	if len(s) != base62Len {
+		return ID{}, errors.Wrapf(ErrBadID, "%q is not base62", s)
+	}
+	n := new(big.Int)
+	base := big.NewInt(62)
+	for i := 0; i < len(s); i++ {
+		v := strings.IndexByte(base62Chars, s[i])
+		if v < 0 {
+			return ID{}, errors.Wrapf(ErrBadID, "%q is not base62", s)
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(v)))
+	}
+	if n.BitLen() > GIDLen*8 {
+		return ID{}, errors.Wrapf(ErrBadID, "%q overflows a gid", s)
+	}
+	id := ID{kind: kind}
+	n.FillBytes(id.gid[:])
+	return id, nil
+}
+
+// FromHex returns the ID of the given kind for a 32 character hex string.
+func FromHex(kind Kind, s string) (ID, error) {
+	if len(s) != 2*GIDLen {
+		return ID{}, errors.Wrapf(ErrBadID, "%q is not a hex gid", s)
+	}
+	gid, err := hex.DecodeString(s)
+	if err != nil {
+		return ID{}, errors.Wrapf(ErrBadID, "%q is not a hex gid", s)
+	}
+	return FromGID(kind, gid)
+}
+
+// UserID returns the ID of the given user name.
+func UserID(username string) ID {
+	return ID{kind: KindUser, name: username}
+}
+
+// ParseID parses a "spotify:" URI, an open.spotify.com URL, a base62 string or
+// a hex string.  Bare base62 and hex strings yield an ID of KindUnknown.
+func ParseID(s string) (ID, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "spotify:"):
+		return parseURI(s)
+	case strings.Contains(s, "open.spotify.com"), strings.Contains(s, "play.spotify.com"):
+		return parseURL(s)
+	case len(s) == base62Len:
+		return FromBase62(KindUnknown, s)
+	case len(s) == 2*GIDLen:
+		return FromHex(KindUnknown, s)
+	}
+	return ID{}, errors.Wrapf(ErrBadID, "unrecognized id %q", s)
+}
+
+// ParseIDAs is ParseID but requires the result to be of the given kind.
+// Bare base62 or hex strings are assumed to be of that kind.
+func ParseIDAs(kind Kind, s string) (ID, error) {
+	id, err := ParseID(s)
+	if err != nil {
+		return ID{}, err
+	}
+	if id.kind == KindUnknown {
+		id.kind = kind
+	} else if id.kind != kind {
+		return ID{}, errors.Wrapf(ErrKindMismatch, "%s is a %v, not a %v", s, id.kind, kind)
+	}
+	return id, nil
+}
+
+func parseURI(uri string) (ID, error) {
+	parts := strings.Split(uri, ":")[1:]
+	return parseParts(parts, uri)
+}
+
+func parseURL(s string) (ID, error) {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return ID{}, errors.Wrapf(ErrBadID, "bad url %q", s)
+	}
+	var parts []string
+	for _, p := range strings.Split(u.Path, "/") {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	// Skip locale and embed prefixes, e.g. "/intl-de/track/..." or "/embed/track/..."
+	for len(parts) > 0 && (strings.HasPrefix(parts[0], "intl-") || parts[0] == "embed") {
+		parts = parts[1:]
+	}
+	return parseParts(parts, s)
+}
+
+func parseParts(parts []string, orig string) (ID, error) {
+	if len(parts) < 2 {
+		return ID{}, errors.Wrapf(ErrBadID, "unrecognized id %q", orig)
+	}
+	kind := parseKind(parts[0])
+	switch {
+	case kind == KindLocal:
+		return ID{kind: KindLocal, name: strings.Join(parts[1:], ":")}, nil
+	case kind == KindUser && len(parts) == 2:
+		return UserID(parts[1]), nil
+	case kind == KindUser && len(parts) == 4 && parts[2] == "playlist":
+		id, err := FromBase62(KindPlaylist, parts[3])
+		id.owner = parts[1]
+		return id, err
+	case kind.hasGID() && len(parts) == 2:
+		return FromBase62(kind, parts[1])
+	}
+	return ID{}, errors.Wrapf(ErrBadID, "unrecognized id %q", orig)
+}
+
+// Kind returns the kind of entity this ID refers to.
+func (id ID) Kind() Kind {
+	return id.kind
+}
+
+// IsValid reports if id refers to something (vs the zero ID).
+func (id ID) IsValid() bool {
+	switch {
+	case id.kind == KindUser || id.kind == KindLocal:
+		return id.name != ""
+	case id.kind == KindUnknown:
+		return id.gid != [GIDLen]byte{}
+	}
+	return id.kind.hasGID()
+}
+
+// GID returns the raw 16 byte GID, or nil for users and local files.
+func (id ID) GID() []byte {
+	if !id.kind.hasGID() && id.kind != KindUnknown {
+		return nil
+	}
+	return append([]byte(nil), id.gid[:]...)
+}
+
+// Hex returns the GID as a 32 character lowercase hex string.
+func (id ID) Hex() string {
+	return hex.EncodeToString(id.gid[:])
+}
+
+// Base62 returns the GID as a 22 character base62 string.
+func (id ID) Base62() string {
+	n := new(big.Int).SetBytes(id.gid[:])
+	base := big.NewInt(62)
+	mod := new(big.Int)
+	var buf [base62Len]byte
+	for i := base62Len - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		buf[i] = base62Chars[mod.Int64()]
+	}
+	return string(buf[:])
+}
+
+// Owner returns the owner of a playlist parsed from a legacy user playlist URI.
+func (id ID) Owner() string {
+	return id.owner
+}
+
+// Name returns the user name of a user ID or the URI tail of a local file.
+func (id ID) Name() string {
+	return id.name
+}
+
+// URI returns the "spotify:<kind>:<id>" form.
+func (id ID) URI() string {
+	switch id.kind {
+	case KindUser, KindLocal:
+		return "spotify:" + id.kind.String() + ":" + id.name
+	case KindPlaylist:
+		if id.owner != "" {
+			return "spotify:user:" + id.owner + ":playlist:" + id.Base62()
+		}
+	}
+	return "spotify:" + id.kind.String() + ":" + id.Base62()
+}
+
+// URL returns the https://open.spotify.com form.
+func (id ID) URL() string {
+	switch id.kind {
+	case KindUser, KindLocal:
+		return "https://open.spotify.com/" + id.kind.String() + "/" + strings.ReplaceAll(id.name, ":", "/")
+	}
+	return "https://open.spotify.com/" + id.kind.String() + "/" + id.Base62()
+}
+
+// PlaylistPath returns the path that Mercury().GetPlaylist() expects, e.g. "user/bob/playlist/<base62>".
+func (id ID) PlaylistPath() string {
+	if id.owner != "" {
+		return "user/" + id.owner + "/playlist/" + id.Base62()
+	}
+	return "playlist/" + id.Base62()
+}
+
+// String returns the URI form of id.
+func (id ID) String() string {
+	return id.URI()
+}
+
+// MarshalText encodes id as its URI.
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.URI()), nil
+}
+
+// UnmarshalText decodes any form accepted by ParseID.
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
@@ -0,0 +1,139 @@
+package catalog_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+)
+
+const (
+	trackB62 = "4uLU6hMCjMI75M1A2tKUQC"
+	trackHex = "93bc414a606747b2b612491ef83d5a3e"
+	listB62  = "37i9dQZF1DXcBWIGoYBM5M"
+	listHex  = "666f726d6174f102ed6cab5416b14d3e"
+)
+
+func TestParseID(t *testing.T) {
+	for _, c := range []struct {
+		in    string
+		kind  catalog.Kind
+		hex   string
+		owner string
+		name  string
+		uri   string // defaults to in
+	}{
+		{in: "spotify:track:" + trackB62, kind: catalog.KindTrack, hex: trackHex},
+		{in: "spotify:album:" + trackB62, kind: catalog.KindAlbum, hex: trackHex},
+		{in: "spotify:artist:" + trackB62, kind: catalog.KindArtist, hex: trackHex},
+		{in: "spotify:episode:" + trackB62, kind: catalog.KindEpisode, hex: trackHex},
+		{in: "spotify:show:" + trackB62, kind: catalog.KindShow, hex: trackHex},
+		{in: "spotify:playlist:" + listB62, kind: catalog.KindPlaylist, hex: listHex},
+		{in: "spotify:user:bob:playlist:" + listB62, kind: catalog.KindPlaylist, hex: listHex, owner: "bob"},
+		{in: "spotify:user:bob", kind: catalog.KindUser, name: "bob"},
+		{in: "spotify:local:Artist:Album:Title:215", kind: catalog.KindLocal, name: "Artist:Album:Title:215"},
+		{in: "  spotify:track:" + trackB62 + "\n", kind: catalog.KindTrack, hex: trackHex, uri: "spotify:track:" + trackB62},
+
+		{in: "https://open.spotify.com/track/" + trackB62, kind: catalog.KindTrack, hex: trackHex, uri: "spotify:track:" + trackB62},
+		{in: "https://open.spotify.com/track/" + trackB62 + "?si=a1b2c3d4e5f6", kind: catalog.KindTrack, hex: trackHex, uri: "spotify:track:" + trackB62},
+		{in: "https://open.spotify.com/intl-de/album/" + trackB62 + "?si=x&nd=1", kind: catalog.KindAlbum, hex: trackHex, uri: "spotify:album:" + trackB62},
+		{in: "open.spotify.com/embed/playlist/" + listB62, kind: catalog.KindPlaylist, hex: listHex, uri: "spotify:playlist:" + listB62},
+		{in: "https://open.spotify.com/user/bob/playlist/" + listB62, kind: catalog.KindPlaylist, hex: listHex, owner: "bob", uri: "spotify:user:bob:playlist:" + listB62},
+		{in: "https://play.spotify.com/artist/" + trackB62, kind: catalog.KindArtist, hex: trackHex, uri: "spotify:artist:" + trackB62},
+		{in: "https://open.spotify.com/user/bob", kind: catalog.KindUser, name: "bob", uri: "spotify:user:bob"},
+
+		{in: trackB62, kind: catalog.KindUnknown, hex: trackHex, uri: "spotify:unknown:" + trackB62},
+		{in: trackHex, kind: catalog.KindUnknown, hex: trackHex, uri: "spotify:unknown:" + trackB62},
+	} {
+		id, err := catalog.ParseID(c.in)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		uri := c.uri
+		if uri == "" {
+			uri = c.in
+		}
+		if id.Kind() != c.kind || id.Owner() != c.owner || id.Name() != c.name || id.URI() != uri {
+			t.Errorf("%q: got %v %q owner %q name %q", c.in, id.Kind(), id.URI(), id.Owner(), id.Name())
+		}
+		if c.hex != "" && id.Hex() != c.hex {
+			t.Errorf("%q: hex %s, want %s", c.in, id.Hex(), c.hex)
+		}
+		if !id.IsValid() {
+			t.Errorf("%q: not valid", c.in)
+		}
+
+		// Every form parses back to the same ID, less the owner of a
+		// legacy playlist URI where the form has no room for it
+		var forms []string
+		if c.kind != catalog.KindUnknown {
+			forms = append(forms, id.URI(), id.URL())
+		}
+		if c.kind == catalog.KindUser || c.kind == catalog.KindLocal {
+			if id.GID() != nil {
+				t.Errorf("%q: has a gid", c.in)
+			}
+		} else {
+			forms = append(forms, id.Base62(), id.Hex())
+		}
+		for _, form := range forms {
+			back, err := catalog.ParseID(form)
+			if back.Kind() == catalog.KindUnknown {
+				back, err = catalog.ParseIDAs(c.kind, form)
+			}
+			if err != nil || back.Kind() != id.Kind() || back.Hex() != id.Hex() || back.Name() != id.Name() {
+				t.Errorf("%q: %q parsed back as %v, err %v", c.in, form, back, err)
+			}
+		}
+		if gid := id.GID(); gid != nil {
+			back, err := catalog.FromGID(id.Kind(), gid)
+			if err != nil || back.Hex() != id.Hex() || hex.EncodeToString(gid) != c.hex {
+				t.Errorf("%q: gid %x round trips to %v", c.in, gid, back)
+			}
+		}
+	}
+}
+
+func TestParseIDRejects(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"spotify:",
+		"spotify:track",
+		"spotify:track:",
+		"spotify:track:" + trackB62[1:],
+		"spotify:track:" + trackB62 + "x",
+		"spotify:track:4uLU6hMCjMI75M1A2tKU-C",
+		"spotify:track:7N42dgm5tFLK9N8MT7fHC8", // 2^128
+		"spotify:banana:" + trackB62,
+		"spotify:track:" + trackB62 + ":extra",
+		"spotify:user:bob:playlist",
+		"spotify:user:bob:album:" + trackB62,
+		"https://open.spotify.com/",
+		"https://open.spotify.com/track",
+		"https://open.spotify.com/intl-de/",
+		"https://example.com/track/" + trackB62,
+		trackHex[2:],
+		"zz" + trackHex[2:],
+	} {
+		if id, err := catalog.ParseID(in); err == nil {
+			t.Errorf("%q parsed as %v", in, id)
+		} else if errors.Cause(err) != catalog.ErrBadID {
+			t.Errorf("%q: got %v, want ErrBadID", in, err)
+		}
+	}
+
+	if _, err := catalog.ParseID("spotify:track:7N42dgm5tFLK9N8MT7fHC7"); err != nil {
+		t.Errorf("the largest gid was rejected: %v", err)
+	}
+	if _, err := catalog.FromGID(catalog.KindTrack, make([]byte, 15)); errors.Cause(err) != catalog.ErrBadID {
+		t.Errorf("short gid: %v", err)
+	}
+	if _, err := catalog.ParseIDAs(catalog.KindAlbum, "spotify:track:"+trackB62); errors.Cause(err) != catalog.ErrKindMismatch {
+		t.Errorf("track parsed as an album: %v", err)
+	}
+	if id, err := catalog.ParseIDAs(catalog.KindAlbum, trackB62); err != nil || id.Kind() != catalog.KindAlbum {
+		t.Errorf("bare base62 as an album: %v, %v", id, err)
+	}
+}
@@ -0,0 +1,118 @@
+package catalog
+
+import (
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/golang/protobuf/proto"
+)
+
+// Sender is implemented by Mercury clients that can send an arbitrary request
+// with a body.  The reply body is returned; replies with a status outside 2xx
+// are returned as errors.
+type Sender interface {
+	Send(method, uri, contentType string, body []byte) ([]byte, error)
+}
+
+// Content types of Mercury multi-get requests and replies
+const (
+	MultiGetRequestType = "vnd.spotify/mercury-mget-request"
+	MultiGetReplyType   = "vnd.spotify/mercury-mget-reply"
+)
+
+// Prefixes of the Mercury URIs of track, album and artist metadata, and of show and episode metadata
+const (
+	metadataPrefix        = "hm://metadata/3/"
+	podcastMetadataPrefix = "hm://metadata/4/"
+)
+
+// multiGetSource is a mercurySource whose Mercury client is also a Sender, so
+// that tracks, albums, artists, shows and episodes can be fetched many per request.
+type multiGetSource struct {
+	mercurySource
+	sender Sender
+}
+
+func (src *multiGetSource) GetTracks(ids []ID) ([]*Spotify.Track, error) {
+	tracks := make([]*Spotify.Track, len(ids))
+	err := src.multiGet(metadataPrefix, KindTrack, ids, func(i int, body []byte) error {
+		tracks[i] = &Spotify.Track{}
+		return proto.Unmarshal(body, tracks[i])
+	})
+	return tracks, err
+}
+
+func (src *multiGetSource) GetAlbums(ids []ID) ([]*Spotify.Album, error) {
+	albums := make([]*Spotify.Album, len(ids))
+	err := src.multiGet(metadataPrefix, KindAlbum, ids, func(i int, body []byte) error {
+		albums[i] = &Spotify.Album{}
+		return proto.Unmarshal(body, albums[i])
+	})
+	return albums, err
+}
+
+func (src *multiGetSource) GetArtists(ids []ID) ([]*Spotify.Artist, error) {
+	artists := make([]*Spotify.Artist, len(ids))
+	err := src.multiGet(metadataPrefix, KindArtist, ids, func(i int, body []byte) error {
+		artists[i] = &Spotify.Artist{}
+		return proto.Unmarshal(body, artists[i])
+	})
+	return artists, err
+}
+
+func (src *multiGetSource) GetShows(ids []ID) ([]*Spotify.Show, error) {
+	shows := make([]*Spotify.Show, len(ids))
+	err := src.multiGet(podcastMetadataPrefix, KindShow, ids, func(i int, body []byte) error {
+		shows[i] = &Spotify.Show{}
+		return proto.Unmarshal(body, shows[i])
+	})
+	return shows, err
+}
+
+func (src *multiGetSource) GetEpisodes(ids []ID) ([]*Spotify.Episode, error) {
+	episodes := make([]*Spotify.Episode, len(ids))
+	err := src.multiGet(podcastMetadataPrefix, KindEpisode, ids, func(i int, body []byte) error {
+		episodes[i] = &Spotify.Episode{}
+		return proto.Unmarshal(body, episodes[i])
+	})
+	return episodes, err
+}
+
+// multiGet requests ids of the given kind in one MercuryMultiGetRequest sent to
+// "<prefix><kind>s" and hands each successful reply to decode.  Items the
+// server could not return are left nil.
+func (src *multiGetSource) multiGet(prefix string, kind Kind, ids []ID, decode func(i int, body []byte) error) error {
+	req := &Spotify.MercuryMultiGetRequest{}
+	for _, id := range ids {
+		if err := expectKind(id, kind); err != nil {
+			return err
+		}
+		req.Request = append(req.Request, &Spotify.MercuryRequest{
+			Uri: proto.String(prefix + kind.String() + "/" + id.Hex()),
+		})
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	uri := prefix + kind.String() + "s"
+	body, err = src.sender.Send("GET", uri, MultiGetRequestType, body)
+	if err != nil {
+		return err
+	}
+	reply := &Spotify.MercuryMultiGetReply{}
+	if err = proto.Unmarshal(body, reply); err != nil {
+		return errors.Wrapf(err, "decoding %s reply", uri)
+	}
+	if len(reply.Reply) != len(ids) {
+		return errors.Errorf("%s: got %d replies for %d requests", uri, len(reply.Reply), len(ids))
+	}
+	for i, r := range reply.Reply {
+		if status := r.GetStatusCode(); status != 0 && (status < 200 || status >= 300) {
+			continue
+		}
+		if err = decode(i, r.GetBody()); err != nil {
+			return errors.Wrapf(err, "decoding %v", ids[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package catalog
+
+import (
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+)
+
+// BatchPodcastSource is implemented by a PodcastSource that can fetch many
+// shows or episodes per request (e.g. via a Mercury multi-get).  Results are
+// returned in the order of ids, nil where an item could not be returned.
+type BatchPodcastSource interface {
+	PodcastSource
+	GetShows(ids []ID) ([]*Spotify.Show, error)
+	GetEpisodes(ids []ID) ([]*Spotify.Episode, error)
+}
+
+// episodeBatchSize is the most episodes GetEpisodes asks for per request.
+const episodeBatchSize = 50
+
+// GetEpisodes fetches episodes with at most workers requests in flight (default 8).
+// If src is a BatchPodcastSource, each request fetches up to 50 episodes.
+// Results are in the order of ids; the first error encountered is returned,
+// and an episode the source could not return is an errors.Err404.
+func GetEpisodes(src PodcastSource, ids []ID, workers int) ([]*Spotify.Episode, error) {
+	if workers <= 0 {
+		workers = 8
+	}
+	episodes := make([]*Spotify.Episode, len(ids))
+	errs := make([]error, len(ids))
+
+	batch, _ := src.(BatchPodcastSource)
+	size := 1
+	if batch != nil {
+		size = episodeBatchSize
+	}
+	idx := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers && w*size < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				if batch == nil {
+					episodes[i], errs[i] = src.GetEpisode(ids[i])
+					continue
+				}
+				end := i + size
+				if end > len(ids) {
+					end = len(ids)
+				}
+				got, err := batch.GetEpisodes(ids[i:end])
+				for j := i; j < end; j++ {
+					switch {
+					case err != nil:
+						errs[j] = err
+					case j-i < len(got) && got[j-i] != nil:
+						episodes[j] = got[j-i]
+					default:
+						errs[j] = errors.Wrapf(errors.Err404, "episode %v", ids[j])
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < len(ids); i += size {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return episodes, nil
+}
+
+// GetShowEpisodes fetches a show and all of its episodes in full.
+func GetShowEpisodes(src PodcastSource, showID ID, workers int) (*Spotify.Show, []*Spotify.Episode, error) {
+	show, err := src.GetShow(showID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ids []ID
+	for _, stub := range show.GetEpisode() {
+		if id, err := FromGID(KindEpisode, stub.GetGid()); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	episodes, err := GetEpisodes(src, ids, workers)
+	if err != nil {
+		return show, nil, err
+	}
+	return show, episodes, nil
+}
+
+// EpisodeTrack presents an episode as a Track so that it can go through the
+// same audio file selection, restriction checks and pinning as music tracks.
+func EpisodeTrack(ep *Spotify.Episode) *Spotify.Track {
+	track := &Spotify.Track{
+		Gid:         ep.GetGid(),
+		Name:        ep.Name,
+		Duration:    ep.Duration,
+		Explicit:    ep.Explicit,
+		Restriction: ep.GetRestriction(),
+		File:        ep.GetAudio(),
+		Preview:     ep.GetAudioPreview(),
+	}
+	if show := ep.GetShow(); show != nil {
+		track.Album = &Spotify.Album{
+			Gid:  show.GetGid(),
+			Name: show.Name,
+		}
+		if publisher := show.GetPublisher(); publisher != "" {
+			track.Artist = []*Spotify.Artist{{Name: &publisher}}
+		}
+	}
+	return track
+}
@@ -0,0 +1,162 @@
+package catalog_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/golang/protobuf/proto"
+)
+
+// episodeID returns the ID of episode n, n < 256*256.
+func episodeID(n int) catalog.ID {
+	g := make([]byte, catalog.GIDLen)
+	g[14], g[15] = byte(n>>8), byte(n)
+	id, _ := catalog.FromGID(catalog.KindEpisode, g)
+	return id
+}
+
+// testShow serves a show of n episodes named by their index.
+func testShow(srv *mercurytest.Server, n int) catalog.ID {
+	showID, _ := catalog.FromGID(catalog.KindShow, gid(0xee))
+	show := &Spotify.Show{Gid: showID.GID(), Name: proto.String("Show")}
+	for i := 0; i < n; i++ {
+		id := episodeID(i)
+		show.Episode = append(show.Episode, &Spotify.Episode{Gid: id.GID()})
+		srv.HandleEpisode(id.Hex(), &Spotify.Episode{Gid: id.GID(), Name: proto.String(id.Hex())})
+	}
+	srv.HandleShow(showID.Hex(), show)
+	return showID
+}
+
+// unbatched hides the batch methods of a source.
+type unbatched struct {
+	catalog.PodcastSource
+
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (src *unbatched) GetEpisode(id catalog.ID) (*Spotify.Episode, error) {
+	src.mu.Lock()
+	if src.inFlight++; src.inFlight > src.max {
+		src.max = src.inFlight
+	}
+	src.mu.Unlock()
+	defer func() {
+		src.mu.Lock()
+		src.inFlight--
+		src.mu.Unlock()
+	}()
+	return src.PodcastSource.GetEpisode(id)
+}
+
+func checkEpisodes(t *testing.T, episodes []*Spotify.Episode, n int) {
+	t.Helper()
+	if len(episodes) != n {
+		t.Fatalf("got %d episodes, want %d", len(episodes), n)
+	}
+	for i, ep := range episodes {
+		if want := episodeID(i).Hex(); ep.GetName() != want {
+			t.Fatalf("episode %d is %q, want %q", i, ep.GetName(), want)
+		}
+	}
+}
+
+func TestGetShowEpisodesBatches(t *testing.T) {
+	srv := mercurytest.New()
+	showID := testShow(srv, 120)
+	src := catalog.NewSource(srv).(catalog.PodcastSource)
+	if _, ok := src.(catalog.BatchPodcastSource); !ok {
+		t.Fatal("multi-get source is not a BatchPodcastSource")
+	}
+
+	show, episodes, err := catalog.GetShowEpisodes(src, showID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if show.GetName() != "Show" {
+		t.Errorf("show %v", show)
+	}
+	checkEpisodes(t, episodes, 120)
+
+	// One show request and three multi-gets of at most 50 episodes
+	reqs := srv.Requests()
+	if len(reqs) != 4 {
+		t.Fatalf("requested %q", reqs)
+	}
+	for _, uri := range reqs[1:] {
+		if uri != "hm://metadata/4/episodes" {
+			t.Errorf("requested %s", uri)
+		}
+	}
+
+	shows, err := src.(catalog.BatchPodcastSource).GetShows([]catalog.ID{showID})
+	if err != nil || len(shows) != 1 || shows[0].GetName() != "Show" {
+		t.Errorf("GetShows gave %v, %v", shows, err)
+	}
+}
+
+func TestGetEpisodesMissing(t *testing.T) {
+	srv := mercurytest.New()
+	testShow(srv, 3)
+	ids := []catalog.ID{episodeID(0), episodeID(7), episodeID(2)}
+	src := catalog.NewSource(srv).(catalog.PodcastSource)
+
+	_, err := catalog.GetEpisodes(src, ids, 0)
+	if errors.Cause(err) != errors.Err404 || !strings.Contains(err.Error(), episodeID(7).String()) {
+		t.Errorf("batched: %v", err)
+	}
+	_, err = catalog.GetEpisodes(&unbatched{PodcastSource: src}, ids, 0)
+	if err == nil {
+		t.Error("unbatched: no error")
+	}
+
+	// A wrong kind fails the whole batch
+	if _, err = catalog.GetEpisodes(src, []catalog.ID{episodeID(0), idOf(catalog.KindTrack, 1)}, 0); errors.Cause(err) != catalog.ErrKindMismatch {
+		t.Errorf("mixed kinds: %v", err)
+	}
+}
+
+func TestGetEpisodesUnbatched(t *testing.T) {
+	srv := mercurytest.New()
+	testShow(srv, 30)
+	ids := make([]catalog.ID, 30)
+	for i := range ids {
+		ids[i] = episodeID(i)
+	}
+	src := &unbatched{PodcastSource: catalog.NewSource(srv).(catalog.PodcastSource)}
+	episodes, err := catalog.GetEpisodes(src, ids, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEpisodes(t, episodes, 30)
+	if n := len(srv.Requests()); n != 30 {
+		t.Errorf("made %d requests", n)
+	}
+	if src.max > 3 {
+		t.Errorf("%d requests in flight", src.max)
+	}
+}
+
+func TestEpisodeTrack(t *testing.T) {
+	ep := &Spotify.Episode{
+		Gid:      gid(1),
+		Name:     proto.String("Pilot"),
+		Duration: proto.Int32(60000),
+		Audio:    []*Spotify.AudioFile{{FileId: []byte{1}}},
+		Show:     &Spotify.Show{Gid: gid(2), Name: proto.String("Show"), Publisher: proto.String("Pub")},
+	}
+	track := catalog.EpisodeTrack(ep)
+	if track.GetName() != "Pilot" || track.GetDuration() != 60000 || len(track.GetFile()) != 1 {
+		t.Errorf("track %v", track)
+	}
+	if track.GetAlbum().GetName() != "Show" || len(track.GetArtist()) != 1 || track.GetArtist()[0].GetName() != "Pub" {
+		t.Errorf("album %v, artists %v", track.GetAlbum(), track.GetArtist())
+	}
+}
@@ -0,0 +1,180 @@
+package catalog
+
+import (
+	"strings"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+)
+
+// ErrNotPlayable means neither a track nor any of its alternatives can be streamed in a given market.
+var ErrNotPlayable = errors.New("track is not available in this country")
+
+// IsPlayable reports if track may be streamed in country (ISO 3166-1 alpha-2) by an
+// account on the given catalogue (e.g. "premium", "free").  An empty catalogue
+// matches any restriction's catalogue list, and an empty country is treated as
+// unknown and passes every country list.
+//
+// Both the track's own restrictions and those of its current sale period (if it
+// has sale periods at all) must permit the country.
+func IsPlayable(track *Spotify.Track, country, catalogue string) bool {
+	return IsPlayableAt(track, country, catalogue, time.Now())
+}
+
+// IsPlayableAt is IsPlayable evaluated at the given time.
+func IsPlayableAt(track *Spotify.Track, country, catalogue string, now time.Time) bool {
+	if track == nil {
+		return false
+	}
+	return availableAt(track.GetRestriction(), track.GetSalePeriod(), country, catalogue, now)
+}
+
+// IsAlbumPlayable is IsPlayable for an album's own restrictions and sale periods.
+func IsAlbumPlayable(album *Spotify.Album, country, catalogue string) bool {
+	if album == nil {
+		return false
+	}
+	return availableAt(album.GetRestriction(), album.GetSalePeriod(), country, catalogue, time.Now())
+}
+
+func availableAt(restrictions []*Spotify.Restriction, periods []*Spotify.SalePeriod, country, catalogue string, now time.Time) bool {
+	if !restrictionsAllow(restrictions, country, catalogue) {
+		return false
+	}
+	if len(periods) == 0 {
+		return true
+	}
+	for _, sp := range periods {
+		if salePeriodActive(sp, now) && restrictionsAllow(sp.GetRestriction(), country, catalogue) {
+			return true
+		}
+	}
+	return false
+}
+
+// Relink returns track if it is playable in country, otherwise the first
+// playable entry of track.Alternative.  ErrNotPlayable is returned if none qualify.
+func Relink(track *Spotify.Track, country string) (*Spotify.Track, error) {
+	now := time.Now()
+	if IsPlayableAt(track, country, "", now) {
+		return track, nil
+	}
+	for _, alt := range track.GetAlternative() {
+		if IsPlayableAt(alt, country, "", now) {
+			return alt, nil
+		}
+	}
+	return nil, ErrNotPlayable
+}
+
+// ResolvePlayable fetches the given track and relinks it for country.  Alternatives
+// that arrive as bare GID stubs are fetched in full before being evaluated.
+func ResolvePlayable(src Source, trackID ID, country, catalogue string) (*Spotify.Track, error) {
+	track, err := src.GetTrack(trackID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if IsPlayableAt(track, country, catalogue, now) {
+		return track, nil
+	}
+	for _, alt := range track.GetAlternative() {
+		if isStub(alt) {
+			altID, err := FromGID(KindTrack, alt.GetGid())
+			if err != nil {
+				continue
+			}
+			if alt, err = src.GetTrack(altID); err != nil {
+				continue
+			}
+		}
+		if IsPlayableAt(alt, country, catalogue, now) {
+			return alt, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrNotPlayable, "%v in %s", trackID, country)
+}
+
+// isStub reports if a track carries nothing but its GID, as nested tracks often do.
+func isStub(t *Spotify.Track) bool {
+	return t.GetName() == "" && len(t.GetFile()) == 0 && len(t.GetRestriction()) == 0
+}
+
+func restrictionsAllow(restrictions []*Spotify.Restriction, country, catalogue string) bool {
+	country = strings.ToUpper(country)
+	for _, r := range restrictions {
+		if r.GetTyp() != Spotify.Restriction_STREAMING {
+			continue
+		}
+		if catalogue != "" && len(r.GetCatalogueStr()) > 0 && !containsString(r.GetCatalogueStr(), catalogue) {
+			continue
+		}
+		if country == "" {
+			continue // unknown country; country lists can't be evaluated
+		}
+		if allowed := r.GetCountriesAllowed(); allowed != "" && !countryListContains(allowed, country) {
+			return false
+		}
+		if countryListContains(r.GetCountriesForbidden(), country) {
+			return false
+		}
+	}
+	return true
+}
+
+// countryListContains searches a list of concatenated two-letter country codes, e.g. "DEFRGB".
+func countryListContains(list, country string) bool {
+	if len(country) != 2 {
+		return false
+	}
+	for i := 0; i+2 <= len(list); i += 2 {
+		if strings.EqualFold(list[i:i+2], country) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func salePeriodActive(sp *Spotify.SalePeriod, now time.Time) bool {
+	if start := sp.GetStart(); start != nil && now.Before(dateStart(start)) {
+		return false
+	}
+	if end := sp.GetEnd(); end != nil && !now.Before(dateEnd(end)) {
+		return false
+	}
+	return true
+}
+
+// dateStart returns the first instant of a possibly partial date.
+func dateStart(d *Spotify.Date) time.Time {
+	month, day := int(d.GetMonth()), int(d.GetDay())
+	if month == 0 {
+		month = 1
+	}
+	if day == 0 {
+		day = 1
+	}
+	return time.Date(int(d.GetYear()), time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// dateEnd returns the instant just after a possibly partial date.
+func dateEnd(d *Spotify.Date) time.Time {
+	year, month, day := int(d.GetYear()), int(d.GetMonth()), int(d.GetDay())
+	switch {
+	case month == 0:
+		return time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	case day == 0:
+		return time.Date(year, time.Month(month+1), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(year, time.Month(month), day+1, 0, 0, 0, 0, time.UTC)
+}
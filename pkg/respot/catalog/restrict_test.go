@@ -0,0 +1,184 @@
+package catalog_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/golang/protobuf/proto"
+)
+
+func streaming(allowed, forbidden string, catalogues ...string) *Spotify.Restriction {
+	r := &Spotify.Restriction{Typ: Spotify.Restriction_STREAMING.Enum(), CatalogueStr: catalogues}
+	if allowed != "" {
+		r.CountriesAllowed = proto.String(allowed)
+	}
+	if forbidden != "" {
+		r.CountriesForbidden = proto.String(forbidden)
+	}
+	return r
+}
+
+func date(ymd ...int32) *Spotify.Date {
+	d := &Spotify.Date{Year: proto.Int32(ymd[0])}
+	if len(ymd) > 1 {
+		d.Month = proto.Int32(ymd[1])
+	}
+	if len(ymd) > 2 {
+		d.Day = proto.Int32(ymd[2])
+	}
+	return d
+}
+
+func at(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestIsPlayableAt(t *testing.T) {
+	now := at("2020-06-15T12:00:00Z")
+	for _, c := range []struct {
+		name      string
+		track     *Spotify.Track
+		country   string
+		catalogue string
+		want      bool
+	}{
+		{"no restrictions", &Spotify.Track{}, "DE", "premium", true},
+		{"nil track", nil, "DE", "", false},
+		{"allowed", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("GBDEFR", "")}}, "DE", "", true},
+		{"allowed, lower case", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("GBDEFR", "")}}, "de", "", true},
+		{"not allowed", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("GBFR", "")}}, "DE", "", false},
+		{"not allowed across a code boundary", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("XDEX", "")}}, "DE", "", false},
+		{"forbidden", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("", "USDE")}}, "DE", "", false},
+		{"not forbidden", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("", "USCA")}}, "DE", "", true},
+		{"unknown country", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("GB", "DE")}}, "", "", true},
+		{"not streaming", &Spotify.Track{Restriction: []*Spotify.Restriction{{Typ: Spotify.Restriction_Type(1).Enum() /* not STREAMING */, CountriesAllowed: proto.String("GB")}}}, "DE", "", true},
+		{"other catalogue", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("GB", "", "free")}}, "DE", "premium", true},
+		{"same catalogue", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("GB", "", "free")}}, "DE", "free", false},
+		{"any catalogue", &Spotify.Track{Restriction: []*Spotify.Restriction{streaming("GB", "", "free")}}, "DE", "", false},
+		{"per-catalogue lists", &Spotify.Track{Restriction: []*Spotify.Restriction{
+			streaming("GB", "", "free"),
+			streaming("GBDE", "", "premium"),
+		}}, "DE", "premium", true},
+
+		{"in a sale period", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{Start: date(2020, 1, 1), End: date(2020, 12, 31)},
+		}}, "DE", "", true},
+		{"before a sale period", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{Start: date(2020, 6, 16)},
+		}}, "DE", "", false},
+		{"after a sale period", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{End: date(2020, 6, 14)},
+		}}, "DE", "", false},
+		{"on the last day", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{End: date(2020, 6, 15)},
+		}}, "DE", "", true},
+		{"in the last month", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{End: date(2020, 6)},
+		}}, "DE", "", true},
+		{"after the last month", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{End: date(2020, 5)},
+		}}, "DE", "", false},
+		{"in the last year", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{End: date(2020)},
+		}}, "DE", "", true},
+		{"in the first month", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{Start: date(2020, 6)},
+		}}, "DE", "", true},
+		{"before the first year", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{Start: date(2021)},
+		}}, "DE", "", false},
+		{"sale period restricted", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{Start: date(2020), Restriction: []*Spotify.Restriction{streaming("GB", "")}},
+		}}, "DE", "", false},
+		{"another sale period allows", &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{
+			{Start: date(2020), Restriction: []*Spotify.Restriction{streaming("GB", "")}},
+			{Start: date(2019), Restriction: []*Spotify.Restriction{streaming("DE", "")}},
+		}}, "DE", "", true},
+		{"track restricted in a sale period", &Spotify.Track{
+			Restriction: []*Spotify.Restriction{streaming("GB", "")},
+			SalePeriod:  []*Spotify.SalePeriod{{Start: date(2020)}},
+		}, "DE", "", false},
+	} {
+		if got := catalog.IsPlayableAt(c.track, c.country, c.catalogue, now); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// A period ending in December runs to the end of the year
+	track := &Spotify.Track{SalePeriod: []*Spotify.SalePeriod{{End: date(2020, 12)}}}
+	if !catalog.IsPlayableAt(track, "DE", "", at("2020-12-31T23:59:59Z")) || catalog.IsPlayableAt(track, "DE", "", at("2021-01-01T00:00:00Z")) {
+		t.Error("a sale period ending in December ends at the wrong time")
+	}
+}
+
+func gid(b byte) []byte {
+	return bytes.Repeat([]byte{b}, catalog.GIDLen)
+}
+
+func TestRelink(t *testing.T) {
+	gbOnly := []*Spotify.Restriction{streaming("GB", "")}
+	track := &Spotify.Track{
+		Gid:         gid(1),
+		Restriction: gbOnly,
+		Alternative: []*Spotify.Track{
+			{Gid: gid(2), Restriction: gbOnly},
+			{Gid: gid(3), Restriction: []*Spotify.Restriction{streaming("DE", "")}},
+			{Gid: gid(4)},
+		},
+	}
+	if got, err := catalog.Relink(track, "GB"); err != nil || got != track {
+		t.Errorf("relinked a playable track to %v, err %v", got, err)
+	}
+	if got, err := catalog.Relink(track, "DE"); err != nil || got.GetGid()[0] != 3 {
+		t.Errorf("relinked to %v, err %v; want the first playable alternative", got, err)
+	}
+	track.Alternative = track.Alternative[:2]
+	if _, err := catalog.Relink(track, "FR"); err != catalog.ErrNotPlayable {
+		t.Errorf("got %v, want ErrNotPlayable", err)
+	}
+}
+
+func TestResolvePlayable(t *testing.T) {
+	srv := mercurytest.New()
+	id, _ := catalog.FromGID(catalog.KindTrack, gid(1))
+	alt, _ := catalog.FromGID(catalog.KindTrack, gid(3))
+	srv.HandleTrack(id.Hex(), &Spotify.Track{
+		Gid:         gid(1),
+		Name:        proto.String("original"),
+		Restriction: []*Spotify.Restriction{streaming("GB", "")},
+		Alternative: []*Spotify.Track{{Gid: gid(2)}, {Gid: gid(3)}}, // stubs
+	})
+	// The alternative 2 can't be fetched, and so is skipped
+	srv.HandleTrack(alt.Hex(), &Spotify.Track{
+		Gid:         gid(3),
+		Name:        proto.String("relinked"),
+		Restriction: []*Spotify.Restriction{streaming("", "DE", "free"), streaming("DE", "", "premium")},
+	})
+	src := catalog.NewSource(srv)
+
+	for _, c := range []struct {
+		country, catalogue, want string
+	}{
+		{"GB", "premium", "original"},
+		{"DE", "premium", "relinked"},
+		{"DE", "free", ""},
+		{"FR", "", ""},
+	} {
+		got, err := catalog.ResolvePlayable(src, id, c.country, c.catalogue)
+		switch {
+		case c.want == "" && errors.Cause(err) != catalog.ErrNotPlayable:
+			t.Errorf("%s/%s: got %v, err %v; want ErrNotPlayable", c.country, c.catalogue, got, err)
+		case c.want != "" && (err != nil || got.GetName() != c.want):
+			t.Errorf("%s/%s: got %v, err %v; want %s", c.country, c.catalogue, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package catalog offers typed access to Spotify catalog metadata on top of Session.Mercury().
+//
+// Mercury's getters take hex GIDs and playlist paths as plain strings; Source
+// takes an ID instead so a base62 string or an ID of the wrong kind can't be
+// passed by mistake.
+package catalog
+
+import (
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+)
+
+// Mercury is the subset of Session.Mercury() that catalog builds on.
+// Track, album and artist getters take hex GIDs; GetPlaylist takes a playlist path.
+type Mercury interface {
+	GetTrack(hexID string) (*Spotify.Track, error)
+	GetAlbum(hexID string) (*Spotify.Album, error)
+	GetArtist(hexID string) (*Spotify.Artist, error)
+	GetPlaylist(path string) (*Spotify.SelectedListContent, error)
+}
+
+// PodcastMercury is implemented by Mercury clients that can also fetch podcast metadata by hex GID.
+type PodcastMercury interface {
+	GetShow(hexID string) (*Spotify.Show, error)
+	GetEpisode(hexID string) (*Spotify.Episode, error)
+}
+
+// Source fetches catalog metadata by ID.
+type Source interface {
+	GetTrack(id ID) (*Spotify.Track, error)
+	GetAlbum(id ID) (*Spotify.Album, error)
+	GetArtist(id ID) (*Spotify.Artist, error)
+	GetPlaylist(id ID) (*Spotify.SelectedListContent, error)
+}
+
+// PodcastSource fetches podcast metadata by ID.
+type PodcastSource interface {
+	GetShow(id ID) (*Spotify.Show, error)
+	GetEpisode(id ID) (*Spotify.Episode, error)
+}
+
+// NewSource returns a Source backed by the given Mercury client, typically session.Mercury().
+// The returned Source is also a PodcastSource, which fails with errors.ErrUnsupported
+// unless m implements PodcastMercury.  If m is a Sender, the Source is also a
+// BatchSource and a BatchPodcastSource that use Mercury multi-get requests.
+func NewSource(m Mercury) Source {
+	if sender, ok := m.(Sender); ok {
+		return &multiGetSource{mercurySource{m}, sender}
+	}
+	return &mercurySource{m}
+}
+
+type mercurySource struct {
+	m Mercury
+}
+
+func expectKind(id ID, kind Kind) error {
+	if id.kind != kind {
+		return errors.Wrapf(ErrKindMismatch, "%v is not a %v", id, kind)
+	}
+	return nil
+}
+
+func (src *mercurySource) GetTrack(id ID) (*Spotify.Track, error) {
+	if err := expectKind(id, KindTrack); err != nil {
+		return nil, err
+	}
+	return src.m.GetTrack(id.Hex())
+}
+
+func (src *mercurySource) GetAlbum(id ID) (*Spotify.Album, error) {
+	if err := expectKind(id, KindAlbum); err != nil {
+		return nil, err
+	}
+	return src.m.GetAlbum(id.Hex())
+}
+
+func (src *mercurySource) GetArtist(id ID) (*Spotify.Artist, error) {
+	if err := expectKind(id, KindArtist); err != nil {
+		return nil, err
+	}
+	return src.m.GetArtist(id.Hex())
+}
+
+func (src *mercurySource) GetPlaylist(id ID) (*Spotify.SelectedListContent, error) {
+	if err := expectKind(id, KindPlaylist); err != nil {
+		return nil, err
+	}
+	return src.m.GetPlaylist(id.PlaylistPath())
+}
+
+func (src *mercurySource) GetShow(id ID) (*Spotify.Show, error) {
+	if err := expectKind(id, KindShow); err != nil {
+		return nil, err
+	}
+	pm, ok := src.m.(PodcastMercury)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return pm.GetShow(id.Hex())
+}
+
+func (src *mercurySource) GetEpisode(id ID) (*Spotify.Episode, error) {
+	if err := expectKind(id, KindEpisode); err != nil {
+		return nil, err
+	}
+	pm, ok := src.m.(PodcastMercury)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return pm.GetEpisode(id.Hex())
+}
@@ -0,0 +1,292 @@
+// Package channel implements the access point channel protocol, which streams
+// ranges of encrypted audio files over the session connection.  It serves as a
+// fallback for networks that block the audio CDN but allow the AP.
+package channel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// AP packet commands used by the protocol
+const (
+	CmdStreamChunk    = 0x08 // channel(2) | fixed parameters(16) | file_id(20) | start(4) | end(4)
+	CmdStreamChunkRes = 0x09 // channel(2) | header frames, then data, then nothing to end the channel
+	CmdChannelError   = 0x0a // channel(2) | code(2)
+)
+
+// Sizes of the fields of the protocol
+const (
+	FileIDLen  = 20
+	WordSize   = 4 // start and end of a chunk request count 4-byte words
+	RequestLen = 2 + 16 + FileIDLen + 4 + 4
+)
+
+// HeaderFileSize is the channel header holding the file size in words.
+const HeaderFileSize = 0x03
+
+var ErrTimeout = errors.New("channel: request timed out")
+
+// ChannelError is returned when the AP ends a channel with CmdChannelError.
+type ChannelError struct {
+	Code uint16
+}
+
+func (e *ChannelError) Error() string {
+	return fmt.Sprintf("channel: error code 0x%04x", e.Code)
+}
+
+// Sender sends a packet to the access point.
+type Sender interface {
+	SendPacket(cmd byte, payload []byte) error
+}
+
+// Header is a channel header frame.
+type Header struct {
+	ID   byte
+	Data []byte
+}
+
+// Response is what a channel delivered.
+type Response struct {
+	Headers []Header
+	Data    []byte
+}
+
+// Header returns the data of the first header with the given ID, or nil.
+func (r *Response) Header(id byte) []byte {
+	for _, h := range r.Headers {
+		if h.ID == id {
+			return h.Data
+		}
+	}
+	return nil
+}
+
+// Opts configures a Manager.
+type Opts struct {
+	Timeout     time.Duration // how long a channel may go without packets (default 10s)
+	MaxChannels int           // chunk requests in flight at once (default 4)
+	MaxRequest  int           // bytes per chunk request; larger ranges are split (default 128 KiB)
+}
+
+// Manager allocates channels and reassembles their packets.  Incoming
+// CmdStreamChunkRes and CmdChannelError packets must be handed to Dispatch by
+// the connection's packet loop.
+type Manager struct {
+	conn  Sender
+	opts  Opts
+	slots chan struct{} // bounds channels in flight
+
+	mu       sync.Mutex
+	nextID   uint16
+	channels map[uint16]*channel
+}
+
+type channel struct {
+	headerDone bool
+	resp       Response
+	activity   chan struct{} // signalled for every packet, to reset the timeout
+	done       chan struct{}
+	err        error
+}
+
+// NewManager returns a Manager sending chunk requests over conn.
+func NewManager(conn Sender, opts Opts) *Manager {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxChannels <= 0 {
+		opts.MaxChannels = 4
+	}
+	if opts.MaxRequest <= 0 {
+		opts.MaxRequest = 128 << 10
+	}
+	opts.MaxRequest = (opts.MaxRequest + WordSize - 1) &^ (WordSize - 1)
+	return &Manager{
+		conn:     conn,
+		opts:     opts,
+		slots:    make(chan struct{}, opts.MaxChannels),
+		channels: make(map[uint16]*channel),
+	}
+}
+
+// allocate reserves an unused channel ID.
+func (m *Manager) allocate(ch *channel) uint16 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		id := m.nextID
+		m.nextID++
+		if m.channels[id] == nil {
+			m.channels[id] = ch
+			return id
+		}
+	}
+}
+
+func (m *Manager) release(id uint16) {
+	m.mu.Lock()
+	delete(m.channels, id)
+	m.mu.Unlock()
+}
+
+// Fetch requests the words [start, end) of a file on a new channel and waits
+// for the channel to end, blocking while MaxChannels requests are in flight.
+func (m *Manager) Fetch(fileID []byte, start, end uint32) (*Response, error) {
+	if len(fileID) != FileIDLen {
+		return nil, errors.Errorf("channel: bad file id (%d bytes)", len(fileID))
+	}
+	m.slots <- struct{}{}
+	defer func() { <-m.slots }()
+
+	ch := &channel{
+		activity: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	id := m.allocate(ch)
+	defer m.release(id)
+
+	if err := m.conn.SendPacket(CmdStreamChunk, EncodeRequest(id, fileID, start, end)); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(m.opts.Timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ch.done:
+			if ch.err != nil {
+				return nil, ch.err
+			}
+			return &ch.resp, nil
+		case <-ch.activity:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(m.opts.Timeout)
+		case <-timer.C:
+			return nil, ErrTimeout
+		}
+	}
+}
+
+// Dispatch handles an incoming packet, returning false if it is not channel traffic.
+func (m *Manager) Dispatch(cmd byte, payload []byte) bool {
+	if cmd != CmdStreamChunkRes && cmd != CmdChannelError {
+		return false
+	}
+	if len(payload) < 2 {
+		return true
+	}
+	id := binary.BigEndian.Uint16(payload)
+	payload = payload[2:]
+
+	// Packets of a channel arrive in order on the connection and Dispatch is
+	// called from its packet loop, so the channel itself needs no locking.
+	m.mu.Lock()
+	ch := m.channels[id]
+	m.mu.Unlock()
+	if ch == nil {
+		return true // late packets of an abandoned channel
+	}
+	select {
+	case <-ch.done:
+		return true
+	default:
+	}
+
+	switch {
+	case cmd == CmdChannelError:
+		code := uint16(0xffff)
+		if len(payload) >= 2 {
+			code = binary.BigEndian.Uint16(payload)
+		}
+		ch.err = &ChannelError{Code: code}
+		close(ch.done)
+		return true
+
+	case !ch.headerDone:
+		// The first packet holds the header frames: len(2) | id(1) | data(len-1), ended by a zero length
+		ch.headerDone = true
+		for len(payload) >= 2 {
+			n := int(binary.BigEndian.Uint16(payload))
+			payload = payload[2:]
+			if n == 0 {
+				break
+			}
+			if n > len(payload) {
+				ch.err = errors.Errorf("channel: truncated header frame (%d of %d bytes)", len(payload), n)
+				close(ch.done)
+				return true
+			}
+			ch.resp.Headers = append(ch.resp.Headers, Header{
+				ID:   payload[0],
+				Data: append([]byte(nil), payload[1:n]...),
+			})
+			payload = payload[n:]
+		}
+
+	case len(payload) == 0:
+		close(ch.done)
+		return true
+
+	default:
+		ch.resp.Data = append(ch.resp.Data, payload...)
+	}
+
+	select {
+	case ch.activity <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// EncodeRequest builds the CmdStreamChunk payload, laid out as librespot does:
+// channel(2) | 0x00 0x01 | 0(2) | 0(4) | 0x00009c40(4) | 0x00020000(4) |
+// file_id(20) | start(4) | end(4).
+func EncodeRequest(channelID uint16, fileID []byte, start, end uint32) []byte {
+	buf := make([]byte, RequestLen)
+	binary.BigEndian.PutUint16(buf[0:], channelID)
+	buf[3] = 0x01
+	binary.BigEndian.PutUint32(buf[10:], 0x00009c40)
+	binary.BigEndian.PutUint32(buf[14:], 0x00020000)
+	copy(buf[18:], fileID)
+	binary.BigEndian.PutUint32(buf[38:], start)
+	binary.BigEndian.PutUint32(buf[42:], end)
+	return buf
+}
+
+// DecodeRequest parses a CmdStreamChunk payload.
+func DecodeRequest(payload []byte) (channelID uint16, fileID []byte, start, end uint32, err error) {
+	if len(payload) < RequestLen {
+		return 0, nil, 0, 0, errors.Errorf("channel: short request (%d bytes)", len(payload))
+	}
+	channelID = binary.BigEndian.Uint16(payload)
+	fileID = payload[18:38]
+	start = binary.BigEndian.Uint32(payload[38:])
+	end = binary.BigEndian.Uint32(payload[42:])
+	return channelID, fileID, start, end, nil
+}
+
+// EncodeHeaders builds the first CmdStreamChunkRes payload of a channel.
+func EncodeHeaders(channelID uint16, headers ...Header) []byte {
+	buf := []byte{byte(channelID >> 8), byte(channelID)}
+	for _, h := range headers {
+		n := 1 + len(h.Data)
+		buf = append(buf, byte(n>>8), byte(n), h.ID)
+		buf = append(buf, h.Data...)
+	}
+	return append(buf, 0, 0)
+}
+
+// EncodeData builds a CmdStreamChunkRes data payload; empty data ends the channel.
+func EncodeData(channelID uint16, data []byte) []byte {
+	buf := make([]byte, 2, 2+len(data))
+	binary.BigEndian.PutUint16(buf, channelID)
+	return append(buf, data...)
+}
@@ -0,0 +1,117 @@
+package channel_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/pkg/respot/audiokey/audiokeytest"
+	"github.com/arcspace/go-librespot/pkg/respot/channel"
+)
+
+var testFileID = bytes.Repeat([]byte{0xc4}, channel.FileIDLen)
+
+func newAP(t *testing.T, size int, opts channel.Opts) (*audiokeytest.AP, *channel.Manager, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	ap := audiokeytest.New()
+	ap.AddFile(testFileID, data)
+	m := channel.NewManager(ap, opts)
+	ap.Attach(m)
+	return ap, m, data
+}
+
+func TestFetcherReadsRanges(t *testing.T) {
+	// A size that isn't a whole number of words, fetched in several requests
+	ap, m, data := newAP(t, 50<<10+3, channel.Opts{MaxRequest: 16 << 10})
+	f := &channel.Fetcher{Manager: m, FileID: testFileID}
+
+	size, err := f.Size()
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("size %d, err %v; want %d", size, err, len(data))
+	}
+	for _, r := range []struct {
+		off int64
+		n   int
+	}{
+		{0, 100},
+		{5, 40 << 10}, // unaligned, spanning three requests
+		{int64(len(data)) - 10, 100},
+	} {
+		got, err := f.FetchRange(r.off, r.n)
+		if err != nil {
+			t.Fatalf("range %d+%d: %v", r.off, r.n, err)
+		}
+		end := r.off + int64(r.n)
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		if !bytes.Equal(got, data[r.off:end]) {
+			t.Errorf("range %d+%d: got %d bytes that differ", r.off, r.n, len(got))
+		}
+	}
+	if _, err = f.FetchRange(int64(len(data)), 1); err != io.EOF {
+		t.Errorf("got %v past the end, want io.EOF", err)
+	}
+	if n := ap.ChunkRequests(); n != 2+1+3+1 {
+		t.Errorf("sent %d chunk requests, want 7", n)
+	}
+}
+
+func TestFetcherUnknownFile(t *testing.T) {
+	_, m, _ := newAP(t, 1024, channel.Opts{})
+	f := &channel.Fetcher{Manager: m, FileID: bytes.Repeat([]byte{1}, channel.FileIDLen)}
+	_, err := f.Size()
+	if _, ok := errors.Cause(err).(*channel.ChannelError); !ok {
+		t.Errorf("got %v, want a ChannelError", err)
+	}
+}
+
+func TestFetchTimesOut(t *testing.T) {
+	ap, m, data := newAP(t, 1024, channel.Opts{Timeout: 50 * time.Millisecond})
+	ap.Drop(1)
+	if _, err := m.Fetch(testFileID, 0, 4); err != channel.ErrTimeout {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+
+	// The next request on a fresh channel goes through
+	resp, err := m.Fetch(testFileID, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp.Data, data[:16]) {
+		t.Error("fetched the wrong bytes")
+	}
+}
+
+func TestEncodeRequestLayout(t *testing.T) {
+	fileID := make([]byte, channel.FileIDLen)
+	for i := range fileID {
+		fileID[i] = byte(0xa0 + i)
+	}
+	want := []byte{
+		0x12, 0x34, // channel
+		0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x9c, 0x40,
+		0x00, 0x02, 0x00, 0x00,
+		0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9,
+		0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf, 0xb0, 0xb1, 0xb2, 0xb3, // file_id
+		0x00, 0x00, 0x10, 0x00, // start
+		0x00, 0x00, 0x20, 0x00, // end
+	}
+	got := channel.EncodeRequest(0x1234, fileID, 0x1000, 0x2000)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got  % x\nwant % x", got, want)
+	}
+
+	id, gotFileID, start, end, err := channel.DecodeRequest(want)
+	if err != nil || id != 0x1234 || !bytes.Equal(gotFileID, fileID) || start != 0x1000 || end != 0x2000 {
+		t.Errorf("decoded channel %x, file %x, %x-%x, err %v", id, gotFileID, start, end, err)
+	}
+}
@@ -0,0 +1,105 @@
+package channel
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// Fetcher retrieves ranges of an encrypted audio file over AP channels.  It
+// implements stream.Fetcher, so a stream.Reader can read through it.
+type Fetcher struct {
+	Manager *Manager
+	FileID  []byte
+
+	mu   sync.Mutex
+	size int64
+}
+
+// Size implements stream.Fetcher.  The AP reports sizes in whole words, so the
+// last word is fetched as well to learn the exact size.
+func (f *Fetcher) Size() (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 {
+		return f.size, nil
+	}
+	resp, err := f.Manager.Fetch(f.FileID, 0, 1)
+	if err != nil {
+		return 0, err
+	}
+	hdr := resp.Header(HeaderFileSize)
+	if len(hdr) < 4 {
+		return 0, errors.New("channel: no file size header")
+	}
+	words := binary.BigEndian.Uint32(hdr)
+	if words == 0 {
+		return 0, nil
+	}
+	last, err := f.Manager.Fetch(f.FileID, words-1, words)
+	if err != nil {
+		return 0, err
+	}
+	f.size = int64(words-1)*WordSize + int64(len(last.Data))
+	return f.size, nil
+}
+
+// FetchRange implements stream.Fetcher.  Ranges larger than MaxRequest are
+// split into several chunk requests that run concurrently, up to MaxChannels.
+func (f *Fetcher) FetchRange(off int64, n int) ([]byte, error) {
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+	if off >= size {
+		return nil, io.EOF
+	}
+	if end := off + int64(n); end > size {
+		n = int(size - off)
+	}
+
+	first := off / WordSize
+	last := (off + int64(n) + WordSize - 1) / WordSize
+	perRequest := int64(f.Manager.opts.MaxRequest / WordSize)
+
+	count := (last - first + perRequest - 1) / perRequest
+	pieces := make([][]byte, count)
+	errs := make([]error, count)
+	wg := sync.WaitGroup{}
+	for i := range pieces {
+		start := first + int64(i)*perRequest
+		end := start + perRequest
+		if end > last {
+			end = last
+		}
+		wg.Add(1)
+		go func(i int, start, end int64) {
+			defer wg.Done()
+			resp, err := f.Manager.Fetch(f.FileID, uint32(start), uint32(end))
+			if err == nil {
+				pieces[i] = resp.Data
+			}
+			errs[i] = err
+		}(i, start, end)
+	}
+	wg.Wait()
+
+	buf := make([]byte, 0, int(last-first)*WordSize)
+	for i, piece := range pieces {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		buf = append(buf, piece...)
+	}
+	skip := int(off - first*WordSize)
+	if skip >= len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf = buf[skip:]
+	if len(buf) > n {
+		buf = buf[:n]
+	}
+	return buf, nil
+}
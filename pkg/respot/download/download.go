@@ -0,0 +1,436 @@
+// Package download mirrors whole albums, playlists and artist discographies to
+// disk as tagged audio files, with bounded concurrency, resume after
+// interruption and progress reporting.
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/atomicfile"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/export"
+	"github.com/arcspace/go-librespot/pkg/respot/images"
+)
+
+// FetchFunc returns the decrypted audio of a track, e.g. from Downloader().PinTrack().
+type FetchFunc func(track *Spotify.Track) (io.ReadCloser, error)
+
+// ManifestName is the default manifest file name, kept in the output directory.
+const ManifestName = ".respot-download.json"
+
+// Opts configures a Manager.
+type Opts struct {
+	Dir       string // output directory (default ".")
+	Template  string // filename template, see export.Fields (default export.DefaultTemplate)
+	Workers   int    // max concurrent downloads (default 4)
+	Country   string // if set, tracks are relinked to versions playable here
+	Catalogue string // account catalogue used with Country
+	Manifest  string // manifest path (default Dir/ManifestName)
+	Covers    *images.Fetcher
+	OnEvent   func(Event) // called for progress events, serialized
+}
+
+// EventKind says what an Event reports.
+type EventKind int
+
+const (
+	EventStarted  EventKind = iota // a track started downloading
+	EventProgress                  // bytes were received
+	EventDone                      // a track was written
+	EventSkipped                   // a track was already on disk, with the recorded checksum or identical to what would be written
+	EventFailed                    // a track failed; Err says why
+	EventFinished                  // the whole job ended
+)
+
+// Event reports progress of a download job.
+type Event struct {
+	Kind  EventKind
+	Track catalog.ID
+	Name  string
+	Path  string
+	Err   error
+
+	// Job totals as of this event
+	Bytes     int64         // audio bytes received so far
+	Completed int           // tracks done or skipped
+	Failed    int           // tracks that failed
+	Total     int           // tracks in the job
+	ETA       time.Duration // estimated time remaining (0 until one track completes)
+}
+
+// Summary is the outcome of a job.
+type Summary struct {
+	Total     int
+	Completed int
+	Skipped   int
+	Failed    map[catalog.ID]error // failed tracks, plus the job's own ID if it was only partly resolved
+	Bytes     int64
+}
+
+// Manager downloads collections of tracks.
+type Manager struct {
+	src   catalog.Source
+	fetch FetchFunc
+	opts  Opts
+
+	albumsMu sync.Mutex
+	albums   map[catalog.ID]*Spotify.Album
+}
+
+// New returns a Manager that looks up metadata in src and audio via fetch.
+func New(src catalog.Source, fetch FetchFunc, opts Opts) *Manager {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Manifest == "" {
+		opts.Manifest = filepath.Join(opts.Dir, ManifestName)
+	}
+	if opts.Covers == nil {
+		opts.Covers = &images.Fetcher{}
+	}
+	return &Manager{
+		src:    src,
+		fetch:  fetch,
+		opts:   opts,
+		albums: make(map[catalog.ID]*Spotify.Album),
+	}
+}
+
+// Resolve lists the tracks of an album, playlist, artist (whole discography) or single track.
+// If some of an artist's releases can't be fetched, or some playlist items are
+// not tracks (episodes and local files can't be downloaded), the tracks that
+// can be are returned along with an error saying what was left out.
+func (m *Manager) Resolve(id catalog.ID) ([]catalog.ID, error) {
+	switch id.Kind() {
+	case catalog.KindTrack:
+		return []catalog.ID{id}, nil
+
+	case catalog.KindAlbum:
+		album, err := m.album(id)
+		if err != nil {
+			return nil, err
+		}
+		return albumTracks(album), nil
+
+	case catalog.KindPlaylist:
+		list, err := m.src.GetPlaylist(id)
+		if err != nil {
+			return nil, err
+		}
+		var ids []catalog.ID
+		var others []string
+		for _, item := range list.GetContents().GetItems() {
+			if trackID, err := catalog.ParseIDAs(catalog.KindTrack, item.GetUri()); err == nil {
+				ids = append(ids, trackID)
+			} else {
+				others = append(others, item.GetUri())
+			}
+		}
+		if len(others) > 0 {
+			return ids, errors.Wrapf(errors.ErrUnsupported, "%d items of %v are not tracks: %s", len(others), id, strings.Join(others, ", "))
+		}
+		return ids, nil
+
+	case catalog.KindArtist:
+		var ids []catalog.ID
+		it := catalog.Discography(m.src, id, catalog.DiscographyOpts{
+			Country:   m.opts.Country,
+			Catalogue: m.opts.Catalogue,
+		})
+		for it.Next() {
+			rel := it.Release()
+			m.albumsMu.Lock()
+			m.albums[rel.ID] = rel.Album
+			m.albumsMu.Unlock()
+			ids = append(ids, albumTracks(rel.Album)...)
+		}
+		if err := it.Err(); err != nil {
+			return ids, err
+		}
+		if n := len(it.Failed()); n > 0 {
+			return ids, errors.Errorf("%d albums of %v could not be fetched", n, id)
+		}
+		return ids, nil
+	}
+	return nil, errors.Wrapf(errors.ErrUnsupported, "cannot download %v", id)
+}
+
+func albumTracks(album *Spotify.Album) []catalog.ID {
+	var ids []catalog.ID
+	for _, disc := range album.GetDisc() {
+		for _, t := range disc.GetTrack() {
+			if id, err := catalog.FromGID(catalog.KindTrack, t.GetGid()); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// album fetches an album once per Manager.
+func (m *Manager) album(id catalog.ID) (*Spotify.Album, error) {
+	m.albumsMu.Lock()
+	album := m.albums[id]
+	m.albumsMu.Unlock()
+	if album != nil {
+		return album, nil
+	}
+	album, err := m.src.GetAlbum(id)
+	if err != nil {
+		return nil, err
+	}
+	m.albumsMu.Lock()
+	m.albums[id] = album
+	m.albumsMu.Unlock()
+	return album, nil
+}
+
+// Download resolves id and downloads every track not already on disk.  An
+// interrupted job resumes where it left off when run again with the same Dir.
+// Per-track failures are reported in the Summary rather than as an error.
+func (m *Manager) Download(id catalog.ID) (*Summary, error) {
+	ids, resolveErr := m.Resolve(id)
+	if resolveErr != nil && len(ids) == 0 {
+		return nil, resolveErr
+	}
+	manifest, err := loadManifest(m.opts.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &job{
+		m:        m,
+		manifest: manifest,
+		start:    time.Now(),
+		claimed:  make(map[string]string),
+		summary:  &Summary{Total: len(ids), Failed: make(map[catalog.ID]error)},
+	}
+	if resolveErr != nil {
+		job.summary.Failed[id] = resolveErr
+	}
+
+	work := make(chan catalog.ID)
+	wg := sync.WaitGroup{}
+	for i := 0; i < m.opts.Workers && i < len(ids); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for trackID := range work {
+				job.run(trackID)
+			}
+		}()
+	}
+	seen := make(map[catalog.ID]bool, len(ids))
+	for _, trackID := range ids {
+		if seen[trackID] {
+			job.mu.Lock()
+			job.summary.Total--
+			job.mu.Unlock()
+			continue
+		}
+		seen[trackID] = true
+		work <- trackID
+	}
+	close(work)
+	wg.Wait()
+
+	job.emit(Event{Kind: EventFinished})
+	return job.summary, nil
+}
+
+// job is the state shared by the workers of one Download call.
+type job struct {
+	m        *Manager
+	manifest *manifest
+	start    time.Time
+
+	mu      sync.Mutex
+	summary *Summary
+	claimed map[string]string // output path -> base62 ID of the track writing it
+}
+
+// emit fills in the job totals and delivers ev.
+func (j *job) emit(ev Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := j.summary
+	ev.Bytes = s.Bytes
+	ev.Completed = s.Completed + s.Skipped
+	ev.Failed = len(s.Failed)
+	ev.Total = s.Total
+	if finished := ev.Completed + ev.Failed; finished > 0 && finished < s.Total {
+		perTrack := time.Since(j.start) / time.Duration(finished)
+		ev.ETA = perTrack * time.Duration(s.Total-finished)
+	}
+	if j.m.opts.OnEvent != nil {
+		j.m.opts.OnEvent(ev)
+	}
+}
+
+func (j *job) run(id catalog.ID) {
+	path, name, skipped, err := j.download(id)
+	ev := Event{Track: id, Name: name, Path: path, Err: err}
+	j.mu.Lock()
+	switch {
+	case err != nil:
+		ev.Kind = EventFailed
+		j.summary.Failed[id] = err
+	case skipped:
+		ev.Kind = EventSkipped
+		j.summary.Skipped++
+	default:
+		ev.Kind = EventDone
+		j.summary.Completed++
+	}
+	j.mu.Unlock()
+	j.emit(ev)
+}
+
+func (j *job) download(id catalog.ID) (path, name string, skipped bool, err error) {
+	m := j.m
+	key := id.Base62()
+	if entry, ok := j.manifest.get(key); ok && entry.Done && fileMatches(entry.Path, entry.SHA256) {
+		return entry.Path, entry.Name, true, nil
+	}
+
+	var track *Spotify.Track
+	if m.opts.Country != "" {
+		track, err = catalog.ResolvePlayable(m.src, id, m.opts.Country, m.opts.Catalogue)
+	} else {
+		track, err = m.src.GetTrack(id)
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	name = track.GetName()
+
+	var album *Spotify.Album
+	if albumID, err := catalog.FromGID(catalog.KindAlbum, track.GetAlbum().GetGid()); err == nil {
+		album, _ = m.album(albumID)
+	}
+	if album == nil {
+		album = track.GetAlbum()
+	}
+
+	j.emit(Event{Kind: EventStarted, Track: id, Name: name})
+	data, err := j.fetch(id, track)
+	if err != nil {
+		return "", name, false, err
+	}
+
+	fileName, err := export.Filename(m.opts.Template, export.FieldsOf(track, album))
+	if err != nil {
+		return "", name, false, err
+	}
+	var cover *images.Image
+	if img, err := m.opts.Covers.FetchBest(images.AlbumImages(album), 640); err == nil {
+		cover = img
+	}
+	var file bytes.Buffer
+	if err = export.WriteTagged(&file, bytes.NewReader(data), int64(len(data)), track, album, cover); err != nil {
+		return "", name, false, err
+	}
+	sha := sha256.Sum256(file.Bytes())
+	sum := hex.EncodeToString(sha[:])
+
+	// A file identical to this one is kept as it is, e.g. after the manifest was lost
+	path = filepath.Join(m.opts.Dir, j.claim(key, fileName, sum))
+	if skipped = fileMatches(path, sum); !skipped {
+		if err = atomicfile.WriteBytes(path, 0, 0, file.Bytes()); err != nil {
+			return "", name, false, err
+		}
+	}
+	err = j.manifest.put(key, manifestEntry{Name: name, Path: path, SHA256: sum, Done: true})
+	return path, name, skipped, err
+}
+
+// claim reserves the file name for the track with the given key, whose file
+// has the given SHA-256.  Distinct tracks can render to the same name (e.g. a
+// song and its remaster), so if the name is claimed by another track in this
+// job, recorded for another track in the manifest, or held by a different file
+// the manifest doesn't know, the track ID is appended to it:
+// "Artist - Title [<base62>].ogg".
+func (j *job) claim(key, fileName, sum string) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.taken(key, filepath.Join(j.m.opts.Dir, fileName), sum) {
+		ext := filepath.Ext(fileName)
+		fileName = strings.TrimSuffix(fileName, ext) + " [" + key + "]" + ext
+	}
+	j.claimed[filepath.Join(j.m.opts.Dir, fileName)] = key
+	return fileName
+}
+
+func (j *job) taken(key, path, sum string) bool {
+	if owner, ok := j.claimed[path]; ok {
+		return owner != key
+	}
+	if owner, ok := j.manifest.owner(path); ok {
+		return owner != key
+	}
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	return !fileMatches(path, sum)
+}
+
+// fetch reads the audio of a track, reporting progress as it arrives.
+func (j *job) fetch(id catalog.ID, track *Spotify.Track) ([]byte, error) {
+	r, err := j.m.fetch(track)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	chunk := make([]byte, 64<<10)
+	lastReport := time.Now()
+	for {
+		n, err := r.Read(chunk)
+		buf.Write(chunk[:n])
+		j.mu.Lock()
+		j.summary.Bytes += int64(n)
+		j.mu.Unlock()
+		if time.Since(lastReport) > 250*time.Millisecond {
+			lastReport = time.Now()
+			j.emit(Event{Kind: EventProgress, Track: id, Name: track.GetName()})
+		}
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileMatches(path, sum string) bool {
+	got, err := fileSHA256(path)
+	return err == nil && got == sum
+}
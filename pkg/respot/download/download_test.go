@@ -0,0 +1,200 @@
+package download_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/download"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/arcspace/go-librespot/pkg/respot/ogg"
+	"github.com/golang/protobuf/proto"
+)
+
+// testStream is a minimal Ogg Vorbis stream: the three header packets and
+// one page of (fake) audio.
+func testStream() []byte {
+	ident := make([]byte, 30)
+	ident[0] = ogg.PacketIdentification
+	copy(ident[1:], "vorbis")
+	ident[11] = 2
+	binary.LittleEndian.PutUint32(ident[12:], 44100)
+	ident[29] = 1
+	comments := ogg.VorbisComments{Vendor: "test"}
+	setup := append([]byte{ogg.PacketSetup}, "vorbis"...)
+
+	var buf bytes.Buffer
+	for i, packet := range [][]byte{ident, comments.Packet(), setup, []byte("audio")} {
+		p := &ogg.Page{Seq: uint32(i), Lacing: []byte{byte(len(packet))}, Data: packet}
+		if i == 0 {
+			p.HeaderType = ogg.FlagBOS
+		}
+		buf.Write(p.Bytes())
+	}
+	return buf.Bytes()
+}
+
+func gid(b byte) []byte {
+	g := make([]byte, 16)
+	g[15] = b
+	return g
+}
+
+func newTrack(srv *mercurytest.Server, b byte, name string) catalog.ID {
+	id, _ := catalog.FromGID(catalog.KindTrack, gid(b))
+	srv.HandleTrack(id.Hex(), &Spotify.Track{
+		Gid:    gid(b),
+		Name:   proto.String(name),
+		Artist: []*Spotify.Artist{{Name: proto.String("Artist")}},
+	})
+	return id
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Name() != download.ManifestName {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDownloadNameCollisions(t *testing.T) {
+	srv := mercurytest.New()
+	dir := t.TempDir()
+	stream := testStream()
+	mgr := download.New(catalog.NewSource(srv), func(*Spotify.Track) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(stream)), nil
+	}, download.Opts{Dir: dir})
+
+	// Two tracks of one album share a name
+	a, b := newTrack(srv, 1, "Song"), newTrack(srv, 2, "Song")
+	albumID, _ := catalog.FromGID(catalog.KindAlbum, gid(10))
+	srv.HandleAlbum(albumID.Hex(), &Spotify.Album{
+		Gid:  gid(10),
+		Disc: []*Spotify.Disc{{Track: []*Spotify.Track{{Gid: a.GID()}, {Gid: b.GID()}}}},
+	})
+	summary, err := mgr.Download(albumID)
+	if err != nil || summary.Completed != 2 {
+		t.Fatalf("completed %+v, err %v", summary, err)
+	}
+	names := listDir(t, dir)
+	if len(names) != 2 || names[1] != "Artist - Song.ogg" {
+		t.Fatalf("wrote %q", names)
+	}
+
+	// Running again finds both where they were written
+	if summary, err = mgr.Download(albumID); err != nil || summary.Skipped != 2 {
+		t.Errorf("second run: %+v, err %v", summary, err)
+	}
+
+	// A later track with the same name doesn't replace either
+	c := newTrack(srv, 3, "Song")
+	if summary, err = mgr.Download(c); err != nil || summary.Completed != 1 {
+		t.Fatalf("completed %+v, err %v", summary, err)
+	}
+	if names = listDir(t, dir); len(names) != 3 {
+		t.Errorf("wrote %q", names)
+	}
+
+	// Nor does a track replace a file the manifest doesn't know
+	other := filepath.Join(dir, "Artist - Other.ogg")
+	if err = os.WriteFile(other, []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := newTrack(srv, 4, "Other")
+	if summary, err = mgr.Download(d); err != nil || summary.Completed != 1 {
+		t.Fatalf("completed %+v, err %v", summary, err)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "mine" {
+		t.Error("overwrote an unrelated file")
+	}
+	if _, err = os.Stat(filepath.Join(dir, "Artist - Other ["+d.Base62()+"].ogg")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDownloadKeepsIdenticalUnknownFile(t *testing.T) {
+	srv := mercurytest.New()
+	dir := t.TempDir()
+	stream := testStream()
+	opts := download.Opts{Dir: dir}
+	fetch := func(*Spotify.Track) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(stream)), nil
+	}
+	a := newTrack(srv, 1, "Song")
+	if summary, err := download.New(catalog.NewSource(srv), fetch, opts).Download(a); err != nil || summary.Completed != 1 {
+		t.Fatalf("completed %+v, err %v", summary, err)
+	}
+
+	// Losing the manifest doesn't make the same track a second copy
+	if err := os.Remove(filepath.Join(dir, download.ManifestName)); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := download.New(catalog.NewSource(srv), fetch, opts).Download(a)
+	if err != nil || summary.Skipped != 1 {
+		t.Fatalf("second run: %+v, err %v", summary, err)
+	}
+	if names := listDir(t, dir); len(names) != 1 || names[0] != "Artist - Song.ogg" {
+		t.Errorf("wrote %q", names)
+	}
+
+	// and the file is recorded again
+	if summary, err = download.New(catalog.NewSource(srv), fetch, opts).Download(a); err != nil || summary.Skipped != 1 {
+		t.Errorf("third run: %+v, err %v", summary, err)
+	}
+}
+
+func TestDownloadPlaylistReportsNonTracks(t *testing.T) {
+	srv := mercurytest.New()
+	stream := testStream()
+	mgr := download.New(catalog.NewSource(srv), func(*Spotify.Track) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(stream)), nil
+	}, download.Opts{Dir: t.TempDir()})
+
+	a := newTrack(srv, 1, "Song")
+	listID, err := catalog.ParseID("spotify:playlist:37i9dQZF1DXcBWIGoYBM5M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	episode := "spotify:episode:512ojhOuo1ktJprKbVcKyQ"
+	local := "spotify:local:Artist:Album:Title:180"
+	srv.HandlePlaylist(listID.PlaylistPath(), &Spotify.SelectedListContent{
+		Contents: &Spotify.ListItems{Items: []*Spotify.Item{
+			{Uri: proto.String(episode)},
+			{Uri: proto.String(a.URI())},
+			{Uri: proto.String(local)},
+		}},
+	})
+
+	summary, err := mgr.Download(listID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Completed != 1 || summary.Total != 1 {
+		t.Errorf("summary %+v", summary)
+	}
+	reason := summary.Failed[listID]
+	if reason == nil {
+		t.Fatal("non-track items were not reported")
+	}
+	for _, uri := range []string{episode, local} {
+		if !strings.Contains(reason.Error(), uri) {
+			t.Errorf("%q doesn't mention %s", reason, uri)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package download
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/pkg/respot/atomicfile"
+)
+
+// manifestEntry records a downloaded track, keyed by base62 track ID.
+type manifestEntry struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Done   bool   `json:"done"`
+}
+
+// manifest is the on-disk record of completed downloads that makes jobs resumable.
+type manifest struct {
+	path string
+
+	mu     sync.Mutex
+	Tracks map[string]manifestEntry `json:"tracks"`
+}
+
+func loadManifest(path string) (*manifest, error) {
+	m := &manifest{
+		path:   path,
+		Tracks: make(map[string]manifestEntry),
+	}
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(buf, m); err != nil {
+		return nil, errors.Wrapf(err, "reading download manifest %s", path)
+	}
+	if m.Tracks == nil {
+		m.Tracks = make(map[string]manifestEntry)
+	}
+	return m, nil
+}
+
+func (m *manifest) get(key string) (manifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.Tracks[key]
+	return entry, ok
+}
+
+// owner returns the key of the entry recorded at path.
+func (m *manifest) owner(path string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, entry := range m.Tracks {
+		if entry.Path == path {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// put records an entry and saves the manifest atomically.
+func (m *manifest) put(key string, entry manifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Tracks[key] = entry
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteBytes(m.path, 0o600, 0, buf)
+}
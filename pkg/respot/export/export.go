@@ -0,0 +1,228 @@
+// Package export writes audio assets out as clean, tagged Ogg Vorbis files:
+// Vorbis comments filled from track and album metadata, embedded cover art,
+// templated filenames and atomic writes with sane permissions.
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // cover art formats
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/atomicfile"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/images"
+	"github.com/arcspace/go-librespot/pkg/respot/metajson"
+	"github.com/arcspace/go-librespot/pkg/respot/ogg"
+)
+
+// DefaultTemplate names files "Artist - Title.ogg".
+const DefaultTemplate = "{{.Artist}} - {{.Title}}.ogg"
+
+// Default permissions of exported files and the directories created for them
+const (
+	DefaultFileMode = atomicfile.DefaultFileMode
+	DefaultDirMode  = atomicfile.DefaultDirMode
+)
+
+// Fields are the values available to filename templates, e.g.
+// "{{.AlbumArtist}}/{{.Album}}/{{printf \"%02d\" .Track}} {{.Title}}.ogg".
+// Every string is already made safe for use as a single path element.
+type Fields struct {
+	Title       string
+	Artist      string // first artist
+	Artists     string // all artists, comma separated
+	AlbumArtist string
+	Album       string
+	Track       int
+	Disc        int
+	Year        int
+	ID          string // base62 track ID
+}
+
+// Opts configures Export.
+type Opts struct {
+	Dir      string        // output directory (default ".")
+	Template string        // filename template relative to Dir (default DefaultTemplate)
+	Name     string        // file name relative to Dir; overrides Template if set
+	Cover    *images.Image // embedded as the front cover if set
+	FileMode os.FileMode   // default DefaultFileMode
+	DirMode  os.FileMode   // default DefaultDirMode
+}
+
+// FieldsOf returns the template fields for a track.  album may be nil, in which
+// case the album stub embedded in the track is used.
+func FieldsOf(track *Spotify.Track, album *Spotify.Album) Fields {
+	if album == nil {
+		album = track.GetAlbum()
+	}
+	f := Fields{
+		Title: sanitize(track.GetName()),
+		Album: sanitize(album.GetName()),
+		Track: int(track.GetNumber()),
+		Disc:  int(track.GetDiscNumber()),
+		Year:  int(album.GetDate().GetYear()),
+	}
+	names := artistNames(track.GetArtist())
+	if len(names) > 0 {
+		f.Artist = sanitize(names[0])
+		f.Artists = sanitize(strings.Join(names, ", "))
+	}
+	if albumArtists := artistNames(album.GetArtist()); len(albumArtists) > 0 {
+		f.AlbumArtist = sanitize(albumArtists[0])
+	} else {
+		f.AlbumArtist = f.Artist
+	}
+	if id, err := catalog.FromGID(catalog.KindTrack, track.GetGid()); err == nil {
+		f.ID = id.Base62()
+	}
+	return f
+}
+
+func artistNames(artists []*Spotify.Artist) []string {
+	var names []string
+	for _, a := range artists {
+		if a.GetName() != "" {
+			names = append(names, a.GetName())
+		}
+	}
+	return names
+}
+
+// sanitize makes s usable as a single path element on common filesystems.
+func sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(strings.TrimSpace(s), ".")
+	if s == "" {
+		s = "_"
+	}
+	return s
+}
+
+// Filename executes tmpl against fields and checks the result stays relative.
+func Filename(tmpl string, fields Fields) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	t, err := template.New("filename").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrap(err, "filename template")
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, fields); err != nil {
+		return "", errors.Wrap(err, "filename template")
+	}
+	name := filepath.Clean(filepath.FromSlash(buf.String()))
+	if filepath.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("filename template produced unsafe path %q", name)
+	}
+	return name, nil
+}
+
+// Comments returns the Vorbis comments describing a track.  album may be nil,
+// in which case the album stub embedded in the track is used.
+func Comments(track *Spotify.Track, album *Spotify.Album) ogg.VorbisComments {
+	if album == nil {
+		album = track.GetAlbum()
+	}
+	var vc ogg.VorbisComments
+	vc.Add("TITLE", track.GetName())
+	for _, name := range artistNames(track.GetArtist()) {
+		vc.Add("ARTIST", name)
+	}
+	vc.Add("ALBUM", album.GetName())
+	for _, name := range artistNames(album.GetArtist()) {
+		vc.Add("ALBUMARTIST", name)
+	}
+	if n := track.GetNumber(); n > 0 {
+		vc.Add("TRACKNUMBER", fmt.Sprint(n))
+	}
+	if n := track.GetDiscNumber(); n > 0 {
+		vc.Add("DISCNUMBER", fmt.Sprint(n))
+	}
+	vc.Add("DATE", metajson.FormatDate(album.GetDate()))
+	for _, ext := range track.GetExternalId() {
+		if strings.EqualFold(ext.GetTyp(), catalog.ExtISRC) {
+			vc.Add("ISRC", catalog.NormalizeISRC(ext.GetId()))
+		}
+	}
+	vc.Add("LABEL", album.GetLabel())
+	for _, c := range album.GetCopyright() {
+		prefix := "©"
+		if c.GetTyp() == Spotify.Copyright_P {
+			prefix = "℗"
+		}
+		text := c.GetText()
+		if !strings.HasPrefix(text, prefix) {
+			text = prefix + " " + text
+		}
+		vc.Add("COPYRIGHT", text)
+	}
+	if id, err := catalog.FromGID(catalog.KindTrack, track.GetGid()); err == nil {
+		vc.Add("SPOTIFY_URI", id.URI())
+	}
+	return vc
+}
+
+// CoverPicture wraps cover art as a front cover picture, reading its dimensions from the image data.
+func CoverPicture(img *images.Image) *ogg.Picture {
+	pic := &ogg.Picture{
+		Type: ogg.PictureFrontCover,
+		MIME: img.ContentType,
+		Data: img.Data,
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data)); err == nil {
+		pic.Width = uint32(cfg.Width)
+		pic.Height = uint32(cfg.Height)
+		pic.Depth = 24
+	}
+	return pic
+}
+
+// Export writes the Ogg Vorbis stream in r (size bytes, with or without
+// Spotify's header) as a tagged file under opts.Dir, named by opts.Template.
+// The file appears atomically; the path written is returned.
+func Export(r io.ReaderAt, size int64, track *Spotify.Track, album *Spotify.Album, opts Opts) (string, error) {
+	name := opts.Name
+	if name == "" {
+		var err error
+		if name, err = Filename(opts.Template, FieldsOf(track, album)); err != nil {
+			return "", err
+		}
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	path := filepath.Join(dir, name)
+
+	err := atomicfile.Write(path, opts.FileMode, opts.DirMode, func(w io.Writer) error {
+		return WriteTagged(w, r, size, track, album, opts.Cover)
+	})
+	return path, err
+}
+
+// WriteTagged writes the Ogg Vorbis stream in r (size bytes, with or without
+// Spotify's header) to w as Export would write it to a file, with cover
+// embedded if it is non-nil.
+func WriteTagged(w io.Writer, r io.ReaderAt, size int64, track *Spotify.Track, album *Spotify.Album, cover *images.Image) error {
+	comments := Comments(track, album)
+	if cover != nil {
+		comments.Comments = append(comments.Comments, CoverPicture(cover).Comment())
+	}
+	return ogg.WriteWithComments(w, r, size, comments)
+}
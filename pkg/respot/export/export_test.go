@@ -0,0 +1,103 @@
+package export_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/export"
+	"github.com/golang/protobuf/proto"
+)
+
+func testTrack() (*Spotify.Track, *Spotify.Album) {
+	album := &Spotify.Album{
+		Name:   proto.String("Album: Deluxe"),
+		Artist: []*Spotify.Artist{{Name: proto.String("Band")}},
+		Date:   &Spotify.Date{Year: proto.Int32(1999), Month: proto.Int32(3)},
+		Label:  proto.String("Label"),
+		Copyright: []*Spotify.Copyright{
+			{Typ: Spotify.Copyright_C.Enum(), Text: proto.String("1999 Label")},
+			{Typ: Spotify.Copyright_P.Enum(), Text: proto.String("℗ 1999 Label")},
+		},
+	}
+	track := &Spotify.Track{
+		Gid:        make([]byte, 16),
+		Name:       proto.String("Song/Part 1"),
+		Number:     proto.Int32(7),
+		DiscNumber: proto.Int32(2),
+		Artist:     []*Spotify.Artist{{Name: proto.String("Singer")}, {Name: proto.String("Guest")}},
+		ExternalId: []*Spotify.ExternalId{{Typ: proto.String("isrc"), Id: proto.String("us-abc-99-00001")}},
+	}
+	return track, album
+}
+
+func TestFilename(t *testing.T) {
+	fields := export.FieldsOf(testTrack())
+	for _, c := range []struct {
+		tmpl, want string
+	}{
+		{"", "Singer - Song_Part 1.ogg"},
+		{"{{.AlbumArtist}}/{{.Album}}/{{printf \"%02d\" .Track}} {{.Title}}.ogg", filepath.Join("Band", "Album_ Deluxe", "07 Song_Part 1.ogg")},
+		{"{{.Year}}/./{{.Disc}}-{{.Track}}.ogg", filepath.Join("1999", "2-7.ogg")},
+		{"{{.Artists}}/{{.ID}}.ogg", filepath.Join("Singer, Guest", "0000000000000000000000.ogg")},
+		{"a/../{{.Title}}.ogg", "Song_Part 1.ogg"},
+	} {
+		got, err := export.Filename(c.tmpl, fields)
+		if err != nil || got != c.want {
+			t.Errorf("%q: got %q, err %v; want %q", c.tmpl, got, err, c.want)
+		}
+	}
+
+	for _, tmpl := range []string{
+		"../{{.Title}}.ogg",
+		"{{.Album}}/../../{{.Title}}.ogg",
+		"/tmp/{{.Title}}.ogg",
+		"..",
+		".",
+		"{{.Missing}}.ogg",
+		"{{.Title",
+	} {
+		if got, err := export.Filename(tmpl, fields); err == nil {
+			t.Errorf("%q: got %q, want an error", tmpl, got)
+		}
+	}
+
+	// Fields can't climb out of the directory either
+	if got, err := export.Filename("{{.Title}}/x.ogg", export.Fields{Title: ".."}); err == nil {
+		t.Errorf("a raw \"..\" field gave %q", got)
+	}
+	track := &Spotify.Track{Name: proto.String("..")}
+	if got, err := export.Filename("{{.Title}}/x.ogg", export.FieldsOf(track, nil)); err != nil || got != filepath.Join("_", "x.ogg") {
+		t.Errorf("got %q, err %v", got, err)
+	}
+}
+
+func TestComments(t *testing.T) {
+	vc := export.Comments(testTrack())
+	want := []string{
+		"TITLE=Song/Part 1",
+		"ARTIST=Singer",
+		"ARTIST=Guest",
+		"ALBUM=Album: Deluxe",
+		"ALBUMARTIST=Band",
+		"TRACKNUMBER=7",
+		"DISCNUMBER=2",
+		"DATE=1999-03",
+		"ISRC=USABC9900001",
+		"LABEL=Label",
+		"COPYRIGHT=© 1999 Label",
+		"COPYRIGHT=℗ 1999 Label",
+		"SPOTIFY_URI=spotify:track:0000000000000000000000",
+	}
+	if !reflect.DeepEqual(vc.Comments, want) {
+		t.Errorf("comments\n%q, want\n%q", vc.Comments, want)
+	}
+
+	// Missing values are left out, and the track's album stub stands in
+	track := &Spotify.Track{Name: proto.String("T"), Album: &Spotify.Album{Name: proto.String("A")}}
+	vc = export.Comments(track, nil)
+	if want := []string{"TITLE=T", "ALBUM=A"}; !reflect.DeepEqual(vc.Comments, want) {
+		t.Errorf("comments %q, want %q", vc.Comments, want)
+	}
+}
@@ -0,0 +1,169 @@
+// Package images resolves and downloads cover art and artist portraits referenced by catalog metadata.
+package images
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/atomicfile"
+	"github.com/h2non/filetype"
+)
+
+// BaseURL is the CDN prefix that image file IDs are resolved against.
+const BaseURL = "https://i.scdn.co/image/"
+
+// MaxSize is the largest image Fetcher accepts; covers are well under 1 MB.
+const MaxSize = 16 << 20
+
+// Nominal pixel dimensions of images that arrive without explicit width and height.
+var sizeDims = map[Spotify.Image_Size]int{
+	Spotify.Image_SMALL:   64,
+	Spotify.Image_DEFAULT: 300,
+	Spotify.Image_LARGE:   640,
+	Spotify.Image_XLARGE:  1280,
+}
+
+// AlbumImages returns all images of an album (cover and cover_group).
+func AlbumImages(album *Spotify.Album) []*Spotify.Image {
+	imgs := append([]*Spotify.Image(nil), album.GetCover()...)
+	return append(imgs, album.GetCoverGroup().GetImage()...)
+}
+
+// ArtistImages returns all images of an artist (portrait and portrait_group).
+func ArtistImages(artist *Spotify.Artist) []*Spotify.Image {
+	imgs := append([]*Spotify.Image(nil), artist.GetPortrait()...)
+	return append(imgs, artist.GetPortraitGroup().GetImage()...)
+}
+
+// Dim returns the larger of an image's width and height, falling back to the nominal size of its size class.
+func Dim(img *Spotify.Image) int {
+	w, h := int(img.GetWidth()), int(img.GetHeight())
+	if w == 0 && h == 0 {
+		return sizeDims[img.GetSize()]
+	}
+	if h > w {
+		return h
+	}
+	return w
+}
+
+// Best picks the smallest image whose dimensions are at least px, or the largest
+// image if none are big enough.  A px <= 0 selects the largest image.
+// Returns nil if there are no usable images.
+func Best(imgs []*Spotify.Image, px int) *Spotify.Image {
+	var best, largest *Spotify.Image
+	for _, img := range imgs {
+		if len(img.GetFileId()) == 0 {
+			continue
+		}
+		dim := Dim(img)
+		if largest == nil || dim > Dim(largest) {
+			largest = img
+		}
+		if px > 0 && dim >= px && (best == nil || dim < Dim(best)) {
+			best = img
+		}
+	}
+	if best == nil {
+		best = largest
+	}
+	return best
+}
+
+// URL returns the CDN URL for the given image.
+func URL(img *Spotify.Image) string {
+	return BaseURL + hex.EncodeToString(img.GetFileId())
+}
+
+// Image is a downloaded image.
+type Image struct {
+	FileID      []byte
+	ContentType string // MIME type detected from the image data, e.g. "image/jpeg"
+	Data        []byte
+}
+
+// Fetcher downloads images, keeping a copy of each in CacheDir (if set).
+type Fetcher struct {
+	CacheDir string       // directory to cache images in; no caching if empty
+	Client   *http.Client // http.DefaultClient if nil
+}
+
+// Fetch returns the bytes of the given image from the cache or the CDN.
+func (f *Fetcher) Fetch(img *Spotify.Image) (*Image, error) {
+	fileID := img.GetFileId()
+	if len(fileID) == 0 {
+		return nil, errors.New("image has no file id")
+	}
+
+	var cachePath string
+	if f.CacheDir != "" {
+		cachePath = filepath.Join(f.CacheDir, hex.EncodeToString(fileID))
+		if data, err := os.ReadFile(cachePath); err == nil {
+			return newImage(fileID, data), nil
+		}
+	}
+
+	data, err := f.download(URL(img))
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		// A failed cache write only costs a download next time
+		atomicfile.WriteBytes(cachePath, 0, 0, data)
+	}
+	return newImage(fileID, data), nil
+}
+
+// FetchBest fetches Best(imgs, px).
+func (f *Fetcher) FetchBest(imgs []*Spotify.Image, px int) (*Image, error) {
+	img := Best(imgs, px)
+	if img == nil {
+		return nil, errors.New("no images available")
+	}
+	return f.Fetch(img)
+}
+
+func (f *Fetcher) download(url string) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching %s", url)
+	}
+	if len(data) > MaxSize {
+		return nil, errors.Errorf("fetching %s: image is larger than %d bytes", url, MaxSize)
+	}
+	return data, nil
+}
+
+func newImage(fileID, data []byte) *Image {
+	return &Image{
+		FileID:      fileID,
+		ContentType: ContentType(data),
+		Data:        data,
+	}
+}
+
+// ContentType detects the MIME type of image data.
+func ContentType(data []byte) string {
+	if kind, err := filetype.Match(data); err == nil && kind != filetype.Unknown {
+		return kind.MIME.Value
+	}
+	return http.DetectContentType(data)
+}
@@ -0,0 +1,182 @@
+package images_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/images"
+	"github.com/golang/protobuf/proto"
+)
+
+func img(id byte, size Spotify.Image_Size, w, h int32) *Spotify.Image {
+	i := &Spotify.Image{Size: size.Enum()}
+	if id != 0 {
+		i.FileId = []byte{id}
+	}
+	if w != 0 {
+		i.Width, i.Height = proto.Int32(w), proto.Int32(h)
+	}
+	return i
+}
+
+func TestBest(t *testing.T) {
+	imgs := []*Spotify.Image{
+		img(1, Spotify.Image_SMALL, 0, 0),       // 64
+		img(2, Spotify.Image_DEFAULT, 0, 0),     // 300
+		img(3, Spotify.Image_DEFAULT, 500, 640), // 640, from its dimensions
+		img(0, Spotify.Image_XLARGE, 0, 0),      // no file
+	}
+	for _, tt := range []struct {
+		px   int
+		want byte
+	}{
+		{0, 3},
+		{-1, 3},
+		{1, 1},
+		{64, 1},
+		{65, 2},
+		{300, 2},
+		{301, 3},
+		{2000, 3},
+	} {
+		if got := images.Best(imgs, tt.px); got.GetFileId()[0] != tt.want {
+			t.Errorf("Best(%d) = %x, want %x", tt.px, got.GetFileId(), tt.want)
+		}
+	}
+	if got := images.Best(imgs[3:], 0); got != nil {
+		t.Errorf("Best of images without files = %v", got)
+	}
+
+	album := &Spotify.Album{
+		Cover:      []*Spotify.Image{imgs[0]},
+		CoverGroup: &Spotify.ImageGroup{Image: []*Spotify.Image{imgs[2]}},
+	}
+	if got := images.Best(images.AlbumImages(album), 640); got != imgs[2] {
+		t.Errorf("cover group image not considered: %v", got)
+	}
+	artist := &Spotify.Artist{PortraitGroup: &Spotify.ImageGroup{Image: []*Spotify.Image{imgs[1]}}}
+	if got := images.ArtistImages(artist); len(got) != 1 || got[0] != imgs[1] {
+		t.Errorf("ArtistImages = %v", got)
+	}
+}
+
+func TestURL(t *testing.T) {
+	i := &Spotify.Image{FileId: []byte{0xab, 0x67, 0x61, 0x6d}}
+	if got, want := images.URL(i), "https://i.scdn.co/image/ab67616d"; got != want {
+		t.Errorf("URL = %s, want %s", got, want)
+	}
+}
+
+// cdn serves files by hex file ID in place of BaseURL and counts requests.
+type cdn struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	hits  int
+}
+
+func (c *cdn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.hits++
+	data, ok := c.files[strings.TrimPrefix(r.URL.Path, "/image/")]
+	c.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+// client sends every request to srv.
+func client(srv *httptest.Server) *http.Client {
+	target, _ := url.Parse(srv.URL)
+	return &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func pngData(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetcherCaches(t *testing.T) {
+	data := pngData(t)
+	c := &cdn{files: map[string][]byte{"0102": data}}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	dir := t.TempDir()
+	f := &images.Fetcher{CacheDir: dir, Client: client(srv)}
+
+	want := &Spotify.Image{FileId: []byte{1, 2}}
+	for i := 0; i < 2; i++ {
+		got, err := f.FetchBest([]*Spotify.Image{want}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data, data) || got.ContentType != "image/png" || !bytes.Equal(got.FileID, want.FileId) {
+			t.Errorf("fetch %d got %+v", i, got)
+		}
+	}
+	if c.hits != 1 {
+		t.Errorf("CDN was hit %d times", c.hits)
+	}
+	if cached, err := os.ReadFile(filepath.Join(dir, "0102")); err != nil || !bytes.Equal(cached, data) {
+		t.Errorf("cached %d bytes, err %v", len(cached), err)
+	}
+
+	// Without a cache directory every fetch goes to the CDN
+	f.CacheDir = ""
+	if _, err := f.Fetch(want); err != nil {
+		t.Fatal(err)
+	}
+	if c.hits != 2 {
+		t.Errorf("CDN was hit %d times", c.hits)
+	}
+
+	// Misses are errors and are not cached
+	f.CacheDir = dir
+	if _, err := f.Fetch(&Spotify.Image{FileId: []byte{9}}); err == nil {
+		t.Error("fetched a missing image")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "09")); !os.IsNotExist(err) {
+		t.Errorf("cached a missing image: %v", err)
+	}
+	if _, err := f.FetchBest(nil, 0); err == nil {
+		t.Error("fetched from no images")
+	}
+}
+
+func TestFetcherLimitsSize(t *testing.T) {
+	c := &cdn{files: map[string][]byte{
+		"01": make([]byte, images.MaxSize),
+		"02": make([]byte, images.MaxSize+1),
+	}}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	f := &images.Fetcher{Client: client(srv)}
+
+	if got, err := f.Fetch(&Spotify.Image{FileId: []byte{1}}); err != nil || len(got.Data) != images.MaxSize {
+		t.Errorf("image of MaxSize: %v", err)
+	}
+	if _, err := f.Fetch(&Spotify.Image{FileId: []byte{2}}); err == nil {
+		t.Error("fetched an image larger than MaxSize")
+	}
+}
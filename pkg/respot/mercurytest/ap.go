@@ -0,0 +1,216 @@
+package mercurytest
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/golang/protobuf/proto"
+)
+
+// AP packet commands of the Mercury protocol
+const (
+	CmdMercuryReq   = 0xb2
+	CmdMercurySub   = 0xb3
+	CmdMercuryUnsub = 0xb4
+	CmdMercuryEvent = 0xb5
+)
+
+// Flags of a Mercury frame
+const (
+	FlagFinal   = 0x01 // the last frame of a reply
+	FlagPartial = 0x02 // the last part continues in the next frame
+)
+
+// MaxPacketSize is the largest payload the AP puts in one packet.
+const MaxPacketSize = 0xffff
+
+// Frame is one Mercury packet: a sequence number correlating requests and
+// replies, flags, and parts.  The first part of a message is an encoded
+// Spotify.Header, the rest is its body.
+type Frame struct {
+	Seq   []byte
+	Flags byte
+	Parts [][]byte
+}
+
+// Encode returns the packet payload of f.
+func (f *Frame) Encode() []byte {
+	size := 2 + len(f.Seq) + 1 + 2
+	for _, part := range f.Parts {
+		size += 2 + len(part)
+	}
+	buf := make([]byte, 0, size)
+	buf = appendUint16(buf, uint16(len(f.Seq)))
+	buf = append(buf, f.Seq...)
+	buf = append(buf, f.Flags)
+	buf = appendUint16(buf, uint16(len(f.Parts)))
+	for _, part := range f.Parts {
+		buf = appendUint16(buf, uint16(len(part)))
+		buf = append(buf, part...)
+	}
+	return buf
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v>>8), byte(v))
+}
+
+var errShortFrame = errors.New("mercurytest: short mercury frame")
+
+// DecodeFrame parses a Mercury packet payload.
+func DecodeFrame(payload []byte) (*Frame, error) {
+	if len(payload) < 2 {
+		return nil, errShortFrame
+	}
+	n := int(binary.BigEndian.Uint16(payload))
+	payload = payload[2:]
+	if len(payload) < n+3 {
+		return nil, errShortFrame
+	}
+	f := &Frame{Seq: payload[:n], Flags: payload[n]}
+	count := int(binary.BigEndian.Uint16(payload[n+1:]))
+	payload = payload[n+3:]
+	for i := 0; i < count; i++ {
+		if len(payload) < 2 {
+			return nil, errShortFrame
+		}
+		size := int(binary.BigEndian.Uint16(payload))
+		if len(payload) < 2+size {
+			return nil, errShortFrame
+		}
+		f.Parts = append(f.Parts, payload[2:2+size])
+		payload = payload[2+size:]
+	}
+	return f, nil
+}
+
+// Dispatcher receives reply packets, as a Session's packet loop does.
+type Dispatcher interface {
+	Dispatch(cmd byte, payload []byte) bool
+}
+
+// AP answers Mercury request, subscribe and unsubscribe packets by resolving
+// them against a Server, so that a Mercury client can be tested down to its
+// wire protocol.  Use it as the client's packet sender and Attach the client
+// to receive the replies.  Every other packet is ignored.
+type AP struct {
+	srv *Server
+
+	mu      sync.Mutex
+	clients []Dispatcher
+}
+
+// NewAP returns an AP serving the handlers of srv.
+func NewAP(srv *Server) *AP {
+	return &AP{srv: srv}
+}
+
+// Attach adds a receiver of replies; each reply goes to the first that accepts it.
+func (ap *AP) Attach(client Dispatcher) {
+	ap.mu.Lock()
+	ap.clients = append(ap.clients, client)
+	ap.mu.Unlock()
+}
+
+// SendPacket accepts a packet from the client.  Replies are delivered
+// asynchronously, after the latency configured on the Server.
+func (ap *AP) SendPacket(cmd byte, payload []byte) error {
+	var method string
+	switch cmd {
+	case CmdMercuryReq:
+	case CmdMercurySub:
+		method = "SUB"
+	case CmdMercuryUnsub:
+		method = "UNSUB"
+	default:
+		return nil
+	}
+	f, err := DecodeFrame(payload)
+	if err != nil {
+		return err
+	}
+	if len(f.Parts) == 0 {
+		return errors.New("mercurytest: mercury frame without a header")
+	}
+	hdr := &Spotify.Header{}
+	if err = proto.Unmarshal(f.Parts[0], hdr); err != nil {
+		return errors.Wrap(err, "mercurytest: bad mercury header")
+	}
+	if method == "" {
+		method = hdr.GetMethod()
+	}
+	var body []byte
+	for _, part := range f.Parts[1:] {
+		body = append(body, part...)
+	}
+	seq := append([]byte(nil), f.Seq...)
+
+	go ap.answer(cmd, seq, method, hdr, body)
+	return nil
+}
+
+// answer resolves a request through the Server and delivers its reply frames.
+func (ap *AP) answer(cmd byte, seq []byte, method string, hdr *Spotify.Header, body []byte) {
+	status := 200
+	reply, err := ap.srv.Send(method, hdr.GetUri(), hdr.GetContentType(), body)
+	if err != nil {
+		reply = nil
+		if se, ok := errors.Cause(err).(*StatusError); ok {
+			status = se.Status
+		} else {
+			status = 500
+		}
+	}
+	replyHdr, err := proto.Marshal(&Spotify.Header{
+		Uri:        hdr.Uri,
+		StatusCode: proto.Int32(int32(status)),
+	})
+	if err != nil {
+		return
+	}
+	parts := [][]byte{replyHdr}
+	if len(reply) > 0 {
+		parts = append(parts, reply)
+	}
+
+	ap.mu.Lock()
+	clients := ap.clients
+	ap.mu.Unlock()
+	for _, payload := range replyFrames(seq, parts) {
+		for _, client := range clients {
+			if client.Dispatch(cmd, payload) {
+				break
+			}
+		}
+	}
+}
+
+// replyFrames encodes parts as frames of at most MaxPacketSize bytes, splitting
+// a part across frames where it doesn't fit.
+func replyFrames(seq []byte, parts [][]byte) [][]byte {
+	overhead := 2 + len(seq) + 1 + 2
+	var frames [][]byte
+	f := &Frame{Seq: seq}
+	room := MaxPacketSize - overhead
+	for len(parts) > 0 {
+		part := parts[0]
+		if room-2 >= len(part) {
+			f.Parts = append(f.Parts, part)
+			room -= 2 + len(part)
+			parts = parts[1:]
+			continue
+		}
+		if room > 2 {
+			f.Parts = append(f.Parts, part[:room-2])
+			f.Flags = FlagPartial
+			parts[0] = part[room-2:]
+		}
+		frames = append(frames, f.Encode())
+		f = &Frame{Seq: seq}
+		room = MaxPacketSize - overhead
+	}
+	f.Flags = FlagFinal
+	return append(frames, f.Encode())
+}
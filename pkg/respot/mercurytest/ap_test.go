@@ -0,0 +1,188 @@
+package mercurytest_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/golang/protobuf/proto"
+)
+
+type reply struct {
+	hdr  *Spotify.Header
+	body []byte
+}
+
+// mercuryClient reassembles Mercury replies by sequence number the way a
+// Session does, joining parts split across frames.
+type mercuryClient struct {
+	t       *testing.T
+	ap      *mercurytest.AP
+	seq     uint32
+	pending map[string][][]byte
+	partial map[string][]byte
+	frames  int
+	replies chan reply
+}
+
+func newMercuryClient(t *testing.T, srv *mercurytest.Server) *mercuryClient {
+	c := &mercuryClient{
+		t:       t,
+		ap:      mercurytest.NewAP(srv),
+		pending: make(map[string][][]byte),
+		partial: make(map[string][]byte),
+		replies: make(chan reply, 1),
+	}
+	c.ap.Attach(c)
+	return c
+}
+
+// Dispatch is only called from the one goroutine answering a request.
+func (c *mercuryClient) Dispatch(cmd byte, payload []byte) bool {
+	if cmd != mercurytest.CmdMercuryReq && cmd != mercurytest.CmdMercurySub {
+		return false
+	}
+	c.frames++
+	f, err := mercurytest.DecodeFrame(payload)
+	if err != nil {
+		c.t.Error(err)
+		return true
+	}
+	seq := string(f.Seq)
+	for i, part := range f.Parts {
+		if p := c.partial[seq]; p != nil {
+			part = append(p, part...)
+			delete(c.partial, seq)
+		}
+		if i == len(f.Parts)-1 && f.Flags == mercurytest.FlagPartial {
+			c.partial[seq] = append([]byte(nil), part...)
+		} else {
+			c.pending[seq] = append(c.pending[seq], part)
+		}
+	}
+	if f.Flags != mercurytest.FlagFinal {
+		return true
+	}
+	parts := c.pending[seq]
+	delete(c.pending, seq)
+	hdr := &Spotify.Header{}
+	if err = proto.Unmarshal(parts[0], hdr); err != nil {
+		c.t.Error(err)
+	}
+	c.replies <- reply{hdr, bytes.Join(parts[1:], nil)}
+	return true
+}
+
+func (c *mercuryClient) send(cmd byte, method, uri, contentType string, body []byte) reply {
+	c.t.Helper()
+	c.seq++
+	hdr, _ := proto.Marshal(&Spotify.Header{Uri: proto.String(uri), Method: proto.String(method), ContentType: proto.String(contentType)})
+	f := &mercurytest.Frame{
+		Seq:   []byte{0, 0, 0, 0, 0, 0, 0, byte(c.seq)},
+		Flags: mercurytest.FlagFinal,
+		Parts: [][]byte{hdr},
+	}
+	if body != nil {
+		f.Parts = append(f.Parts, body)
+	}
+	if err := c.ap.SendPacket(cmd, f.Encode()); err != nil {
+		c.t.Fatal(err)
+	}
+	select {
+	case r := <-c.replies:
+		return r
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("no reply")
+	}
+	return reply{}
+}
+
+func TestAPRequests(t *testing.T) {
+	srv := mercurytest.New()
+	id := trackID(1)
+	track := &Spotify.Track{Gid: id.GID(), Name: proto.String("Song")}
+	srv.HandleTrack(id.Hex(), track)
+	c := newMercuryClient(t, srv)
+
+	r := c.send(mercurytest.CmdMercuryReq, "GET", mercurytest.TrackPrefix+id.Hex(), "", nil)
+	got := &Spotify.Track{}
+	if err := proto.Unmarshal(r.body, got); err != nil {
+		t.Fatal(err)
+	}
+	if r.hdr.GetStatusCode() != 200 || r.hdr.GetUri() != mercurytest.TrackPrefix+id.Hex() || !proto.Equal(got, track) {
+		t.Errorf("got %v %v", r.hdr, got)
+	}
+
+	r = c.send(mercurytest.CmdMercuryReq, "GET", mercurytest.TrackPrefix+trackID(2).Hex(), "", nil)
+	if r.hdr.GetStatusCode() != 404 || len(r.body) != 0 {
+		t.Errorf("unknown track gave %v with %d bytes", r.hdr, len(r.body))
+	}
+
+	// Subscriptions are resolved as SUB requests
+	var method string
+	srv.HandleRequest("hm://pusher/*", func(req *mercurytest.Request) mercurytest.Response {
+		method = req.Method
+		return mercurytest.Response{Body: req.Body}
+	})
+	r = c.send(mercurytest.CmdMercurySub, "", "hm://pusher/x", "", []byte("hello"))
+	if method != "SUB" || string(r.body) != "hello" {
+		t.Errorf("subscribe was %s, replied %q", method, r.body)
+	}
+
+	// Other packets are not Mercury's business
+	if err := c.ap.SendPacket(0x04, []byte{1, 2, 3}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAPMultiGet(t *testing.T) {
+	srv := mercurytest.New()
+	srv.HandleTrack(trackID(1).Hex(), &Spotify.Track{Name: proto.String("One")})
+	c := newMercuryClient(t, srv)
+
+	mget, _ := proto.Marshal(&Spotify.MercuryMultiGetRequest{Request: []*Spotify.MercuryRequest{
+		{Uri: proto.String(mercurytest.TrackPrefix + trackID(1).Hex())},
+		{Uri: proto.String(mercurytest.TrackPrefix + trackID(2).Hex())},
+	}})
+	r := c.send(mercurytest.CmdMercuryReq, "GET", "hm://metadata/3/tracks", mercurytest.MultiGetRequestType, mget)
+	got := &Spotify.MercuryMultiGetReply{}
+	if err := proto.Unmarshal(r.body, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Reply) != 2 || got.Reply[0].GetStatusCode() != 200 || got.Reply[1].GetStatusCode() != 404 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestAPSplitsLargeReplies(t *testing.T) {
+	srv := mercurytest.New()
+	body := make([]byte, 3*mercurytest.MaxPacketSize)
+	for i := range body {
+		body[i] = byte(i * 7)
+	}
+	srv.HandleStatic("hm://big", mercurytest.Response{Body: body})
+	c := newMercuryClient(t, srv)
+
+	r := c.send(mercurytest.CmdMercuryReq, "GET", "hm://big", "", nil)
+	if !bytes.Equal(r.body, body) {
+		t.Errorf("reassembled %d bytes, want %d", len(r.body), len(body))
+	}
+	if c.frames != 4 {
+		t.Errorf("sent %d frames", c.frames)
+	}
+}
+
+func TestDecodeFrameRejectsShort(t *testing.T) {
+	full := (&mercurytest.Frame{Seq: []byte{1}, Flags: mercurytest.FlagFinal, Parts: [][]byte{[]byte("header"), []byte("body")}}).Encode()
+	f, err := mercurytest.DecodeFrame(full)
+	if err != nil || len(f.Parts) != 2 || string(f.Parts[1]) != "body" {
+		t.Fatalf("decoded %+v, %v", f, err)
+	}
+	for n := 0; n < len(full); n++ {
+		if _, err = mercurytest.DecodeFrame(full[:n]); err == nil {
+			t.Errorf("decoded %d of %d bytes", n, len(full))
+		}
+	}
+}
@@ -0,0 +1,350 @@
+// Package mercurytest provides a scriptable, in-memory stand-in for the Mercury
+// metadata service so that code built on Session.Mercury() can be exercised
+// without a Spotify account or network access.
+//
+// Handlers are registered against Mercury URI patterns (see path.Match) and
+// return canned protobuf payloads, status codes and artificial latency.
+//
+// A Server has the getters of Session.Mercury() and can stand in for it
+// wherever they are consumed through an interface, as catalog.NewSource and
+// the playlist Fetcher do.  Below that, an AP serves the same handlers over the
+// Mercury wire protocol (request, subscribe and unsubscribe packets), so that
+// a Mercury client can be run against it through its packet transport.
+package mercurytest
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/golang/protobuf/proto"
+)
+
+// Mercury URI prefixes, as requested by Session.Mercury()
+const (
+	TrackPrefix    = "hm://metadata/3/track/"
+	AlbumPrefix    = "hm://metadata/3/album/"
+	ArtistPrefix   = "hm://metadata/3/artist/"
+	ShowPrefix     = "hm://metadata/4/show/"
+	EpisodePrefix  = "hm://metadata/4/episode/"
+	PlaylistPrefix = "hm://playlist/"
+	SearchPrefix   = "hm://searchview/km/v4/search/"
+)
+
+// Response is what a Handler hands back for a single Mercury request.
+type Response struct {
+	Status  int           // Mercury status code; 0 is treated as 200
+	Payload proto.Message // encoded into Body if Body is nil
+	Body    []byte        // raw reply body (e.g. search JSON)
+	Latency time.Duration // added on top of the server-wide latency
+	Err     error         // transport-level failure; takes precedence over Status
+}
+
+// Handler produces the Response for a request URI.
+type Handler func(uri string) Response
+
+// Request is a Mercury request with its method and body, as sent via Send.
+type Request struct {
+	Method      string
+	URI         string
+	ContentType string
+	Body        []byte
+}
+
+// RequestHandler produces the Response for a request that may carry a body.
+type RequestHandler func(req *Request) Response
+
+// MultiGetRequestType is the content type of a MercuryMultiGetRequest.  Send
+// answers these itself by resolving each contained request against the
+// registered handlers and replying with a MercuryMultiGetReply.
+const MultiGetRequestType = "vnd.spotify/mercury-mget-request"
+
+// StatusError is returned when a handler replies with a non-2xx status.
+type StatusError struct {
+	URI    string
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("mercury: %s returned status %d", e.URI, e.Status)
+}
+
+type route struct {
+	pattern string
+	handler RequestHandler
+}
+
+// Server is a fake Mercury endpoint.  The zero value is not usable; use New().
+type Server struct {
+	mu       sync.Mutex
+	routes   []route
+	latency  time.Duration
+	requests []string
+}
+
+// New returns an empty Server that answers every request with a 404.
+func New() *Server {
+	return &Server{}
+}
+
+// Handle registers h for all URIs matching pattern (path.Match syntax).
+// Routes registered later take precedence, so tests can override a default.
+func (s *Server) Handle(pattern string, h Handler) {
+	s.HandleRequest(pattern, func(req *Request) Response { return h(req.URI) })
+}
+
+// HandleRequest is Handle for handlers that need the method or body of a request.
+func (s *Server) HandleRequest(pattern string, h RequestHandler) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("mercurytest: bad pattern %q: %v", pattern, err))
+	}
+	s.mu.Lock()
+	s.routes = append(s.routes, route{pattern, h})
+	s.mu.Unlock()
+}
+
+// HandleStatic registers a handler that always returns resp.
+func (s *Server) HandleStatic(pattern string, resp Response) {
+	s.Handle(pattern, func(string) Response { return resp })
+}
+
+// HandleTrack serves track for the given hex GID.
+func (s *Server) HandleTrack(hexID string, track *Spotify.Track) {
+	s.HandleStatic(TrackPrefix+hexID, Response{Payload: track})
+}
+
+// HandleAlbum serves album for the given hex GID.
+func (s *Server) HandleAlbum(hexID string, album *Spotify.Album) {
+	s.HandleStatic(AlbumPrefix+hexID, Response{Payload: album})
+}
+
+// HandleArtist serves artist for the given hex GID.
+func (s *Server) HandleArtist(hexID string, artist *Spotify.Artist) {
+	s.HandleStatic(ArtistPrefix+hexID, Response{Payload: artist})
+}
+
+// HandleShow serves show for the given hex GID.
+func (s *Server) HandleShow(hexID string, show *Spotify.Show) {
+	s.HandleStatic(ShowPrefix+hexID, Response{Payload: show})
+}
+
+// HandleEpisode serves episode for the given hex GID.
+func (s *Server) HandleEpisode(hexID string, episode *Spotify.Episode) {
+	s.HandleStatic(EpisodePrefix+hexID, Response{Payload: episode})
+}
+
+// HandlePlaylist serves list for the given playlist path (e.g. "user/bob/playlist/37i9dQ...").
+func (s *Server) HandlePlaylist(playlistPath string, list *Spotify.SelectedListContent) {
+	s.HandleStatic(PlaylistPrefix+playlistPath, Response{Payload: list})
+}
+
+// HandleRootPlaylist serves list as the root list of the given user.
+func (s *Server) HandleRootPlaylist(username string, list *Spotify.SelectedListContent) {
+	s.HandlePlaylist(RootPlaylistPath(username), list)
+}
+
+// HandleSearch serves a raw search reply body for the given keyword, regardless of query params.
+func (s *Server) HandleSearch(keyword string, body []byte) {
+	s.HandleStatic(SearchPrefix+url.QueryEscape(keyword), Response{Body: body})
+}
+
+// SetLatency sets the delay applied to every request before it is answered.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency = d
+	s.mu.Unlock()
+}
+
+// Requests returns the URIs requested so far, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Reset drops all registered handlers and recorded requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.routes = nil
+	s.requests = nil
+	s.mu.Unlock()
+}
+
+// Request resolves uri against the registered handlers and returns the reply body.
+func (s *Server) Request(uri string) ([]byte, error) {
+	return s.Send("GET", uri, "", nil)
+}
+
+// Send resolves a request with a body against the registered handlers and
+// returns the reply body.  It makes Server a catalog.Sender.
+func (s *Server) Send(method, uri, contentType string, body []byte) ([]byte, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, uri)
+	latency := s.latency
+	s.mu.Unlock()
+
+	req := &Request{Method: method, URI: uri, ContentType: contentType, Body: body}
+	var resp Response
+	if contentType == MultiGetRequestType {
+		resp = s.multiGet(req)
+	} else {
+		resp = s.respond(req)
+	}
+	if d := latency + resp.Latency; d > 0 {
+		time.Sleep(d)
+	}
+
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if resp.Status != 0 && (resp.Status < 200 || resp.Status >= 300) {
+		return nil, &StatusError{URI: uri, Status: resp.Status}
+	}
+	return resp.body()
+}
+
+// respond runs the handler registered for req.URI, or replies 404 if there is none.
+func (s *Server) respond(req *Request) Response {
+	matchURI := req.URI
+	if strings.HasPrefix(matchURI, SearchPrefix) {
+		if i := strings.IndexByte(matchURI, '?'); i > 0 {
+			matchURI = matchURI[:i]
+		}
+	}
+	var h RequestHandler
+	s.mu.Lock()
+	for i := len(s.routes) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(s.routes[i].pattern, matchURI); ok {
+			h = s.routes[i].handler
+			break
+		}
+	}
+	s.mu.Unlock()
+	if h == nil {
+		return Response{Status: 404}
+	}
+	return h(req)
+}
+
+// multiGet answers each request in a MercuryMultiGetRequest.  Only the
+// multi-get itself is recorded in Requests; it takes as long as its slowest part.
+func (s *Server) multiGet(req *Request) Response {
+	mget := &Spotify.MercuryMultiGetRequest{}
+	if err := proto.Unmarshal(req.Body, mget); err != nil {
+		return Response{Status: 400}
+	}
+	reply := &Spotify.MercuryMultiGetReply{}
+	var latency time.Duration
+	for _, sub := range mget.GetRequest() {
+		resp := s.respond(&Request{Method: req.Method, URI: sub.GetUri(), ContentType: sub.GetContentType(), Body: sub.GetBody()})
+		if resp.Latency > latency {
+			latency = resp.Latency
+		}
+		status := resp.Status
+		if status == 0 {
+			status = 200
+		}
+		body, err := resp.body()
+		if resp.Err != nil || err != nil {
+			status, body = 500, nil
+		}
+		reply.Reply = append(reply.Reply, &Spotify.MercuryReply{
+			StatusCode: proto.Int32(int32(status)),
+			Body:       body,
+		})
+	}
+	return Response{Payload: reply, Latency: latency}
+}
+
+func (resp Response) body() ([]byte, error) {
+	if resp.Body != nil || resp.Payload == nil {
+		return resp.Body, nil
+	}
+	return proto.Marshal(resp.Payload)
+}
+
+func (s *Server) get(uri string, msg proto.Message) error {
+	body, err := s.Request(uri)
+	if err != nil {
+		return err
+	}
+	if err = proto.Unmarshal(body, msg); err != nil {
+		return errors.Wrapf(err, "mercurytest: bad payload for %s", uri)
+	}
+	return nil
+}
+
+// GetTrack mirrors Mercury().GetTrack and expects a hex GID.
+func (s *Server) GetTrack(id string) (*Spotify.Track, error) {
+	track := &Spotify.Track{}
+	err := s.get(TrackPrefix+id, track)
+	return track, err
+}
+
+// GetAlbum mirrors Mercury().GetAlbum and expects a hex GID.
+func (s *Server) GetAlbum(id string) (*Spotify.Album, error) {
+	album := &Spotify.Album{}
+	err := s.get(AlbumPrefix+id, album)
+	return album, err
+}
+
+// GetArtist mirrors Mercury().GetArtist and expects a hex GID.
+func (s *Server) GetArtist(id string) (*Spotify.Artist, error) {
+	artist := &Spotify.Artist{}
+	err := s.get(ArtistPrefix+id, artist)
+	return artist, err
+}
+
+// GetShow fetches a show by hex GID.
+func (s *Server) GetShow(id string) (*Spotify.Show, error) {
+	show := &Spotify.Show{}
+	err := s.get(ShowPrefix+id, show)
+	return show, err
+}
+
+// GetEpisode fetches an episode by hex GID.
+func (s *Server) GetEpisode(id string) (*Spotify.Episode, error) {
+	episode := &Spotify.Episode{}
+	err := s.get(EpisodePrefix+id, episode)
+	return episode, err
+}
+
+// GetPlaylist mirrors Mercury().GetPlaylist and expects a playlist path such as "user/bob/playlist/<base62>".
+func (s *Server) GetPlaylist(id string) (*Spotify.SelectedListContent, error) {
+	list := &Spotify.SelectedListContent{}
+	err := s.get(PlaylistPrefix+id, list)
+	return list, err
+}
+
+// GetRootPlaylist mirrors Mercury().GetRootPlaylist.
+func (s *Server) GetRootPlaylist(username string) (*Spotify.SelectedListContent, error) {
+	return s.GetPlaylist(RootPlaylistPath(username))
+}
+
+// SearchRaw issues a search request and returns the undecoded JSON reply.
+func (s *Server) SearchRaw(keyword string, limit int, country, username string) ([]byte, error) {
+	return s.Request(SearchURI(keyword, limit, country, username))
+}
+
+// RootPlaylistPath returns the playlist path of a user's root list.
+func RootPlaylistPath(username string) string {
+	return "user/" + username + "/rootlist"
+}
+
+// SearchURI returns the Mercury URI Session.Mercury().Search() requests.
+func SearchURI(keyword string, limit int, country, username string) string {
+	v := url.Values{}
+	v.Set("entityVersion", "2")
+	v.Set("limit", fmt.Sprint(limit))
+	v.Set("imageSize", "large")
+	v.Set("catalogue", "")
+	v.Set("country", country)
+	v.Set("platform", "zelda")
+	v.Set("username", username)
+	return SearchPrefix + url.QueryEscape(keyword) + "?" + v.Encode()
+}
@@ -0,0 +1,115 @@
+package mercurytest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/golang/protobuf/proto"
+)
+
+func trackID(b byte) catalog.ID {
+	gid := make([]byte, 16)
+	gid[15] = b
+	id, _ := catalog.FromGID(catalog.KindTrack, gid)
+	return id
+}
+
+func TestSourceGetters(t *testing.T) {
+	srv := mercurytest.New()
+	id := trackID(1)
+	track := &Spotify.Track{Gid: id.GID(), Name: proto.String("Song")}
+	srv.HandleTrack(id.Hex(), track)
+
+	src := catalog.NewSource(srv)
+	got, err := src.GetTrack(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, track) {
+		t.Errorf("got %v, want %v", got, track)
+	}
+	if reqs := srv.Requests(); len(reqs) != 1 || reqs[0] != mercurytest.TrackPrefix+id.Hex() {
+		t.Errorf("requested %q", reqs)
+	}
+
+	// Unregistered URIs answer 404
+	_, err = src.GetTrack(trackID(2))
+	if se, ok := errors.Cause(err).(*mercurytest.StatusError); !ok || se.Status != 404 {
+		t.Errorf("got %v for an unknown track, want a 404", err)
+	}
+}
+
+func TestStatusAndLatency(t *testing.T) {
+	srv := mercurytest.New()
+	id := trackID(1)
+	srv.HandleTrack(id.Hex(), &Spotify.Track{Gid: id.GID()})
+	srv.HandleStatic(mercurytest.TrackPrefix+"*", mercurytest.Response{Status: 503, Latency: 20 * time.Millisecond})
+	srv.SetLatency(30 * time.Millisecond)
+
+	start := time.Now()
+	_, err := catalog.NewSource(srv).GetTrack(id)
+	if se, ok := errors.Cause(err).(*mercurytest.StatusError); !ok || se.Status != 503 {
+		t.Errorf("got %v, want a 503 from the later route", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("answered after %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestMultiGet(t *testing.T) {
+	srv := mercurytest.New()
+	a, b := trackID(1), trackID(2)
+	srv.HandleTrack(a.Hex(), &Spotify.Track{Gid: a.GID(), Name: proto.String("a")})
+
+	src, ok := catalog.NewSource(srv).(catalog.BatchSource)
+	if !ok {
+		t.Fatal("source over a Sender is not a BatchSource")
+	}
+	tracks, err := src.GetTracks([]catalog.ID{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0].GetName() != "a" || tracks[1] != nil {
+		t.Errorf("got %v", tracks)
+	}
+	if reqs := srv.Requests(); len(reqs) != 1 || reqs[0] != "hm://metadata/3/tracks" {
+		t.Errorf("requested %q, want one multi-get", reqs)
+	}
+}
+
+func TestPlaylist(t *testing.T) {
+	srv := mercurytest.New()
+	list := &Spotify.SelectedListContent{Revision: []byte{1}, Length: proto.Int32(0)}
+	srv.HandleRootPlaylist("bob", list)
+	got, err := srv.GetRootPlaylist("bob")
+	if err != nil || !proto.Equal(got, list) {
+		t.Errorf("got %v, err %v", got, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	srv := mercurytest.New()
+	srv.HandleSearchResults("abba", &mercurytest.SearchResponse{
+		Results: mercurytest.SearchResults{
+			Tracks: mercurytest.SearchHits{
+				Hits:  []mercurytest.SearchHit{{Name: "Waterloo", URI: trackID(1).URI()}},
+				Total: 1,
+			},
+		},
+	})
+	resp, err := srv.Search("abba", 12, "SE", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits := resp.Results.Tracks.Hits
+	if resp.Results.Tracks.Total != 1 || len(hits) != 1 || hits[0].Name != "Waterloo" {
+		t.Errorf("got %+v", resp.Results)
+	}
+	if reqs := srv.Requests(); reqs[0] != mercurytest.SearchURI("abba", 12, "SE", "bob") {
+		t.Errorf("requested %q", reqs[0])
+	}
+}
@@ -0,0 +1,58 @@
+package mercurytest
+
+import (
+	"encoding/json"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// SearchResponse is the JSON reply to a search request, as decoded by
+// Mercury().Search.  Only the fields callers read are modelled.
+type SearchResponse struct {
+	Results         SearchResults `json:"results"`
+	RequestID       string        `json:"requestId,omitempty"`
+	CategoriesOrder []string      `json:"categoriesOrder,omitempty"`
+}
+
+// SearchResults holds the hits of a search by entity kind.
+type SearchResults struct {
+	Tracks    SearchHits `json:"tracks"`
+	Albums    SearchHits `json:"albums"`
+	Artists   SearchHits `json:"artists"`
+	Playlists SearchHits `json:"playlists"`
+}
+
+// SearchHits is a page of hits for one entity kind.
+type SearchHits struct {
+	Hits  []SearchHit `json:"hits"`
+	Total int         `json:"total"`
+}
+
+// SearchHit is a single search hit.
+type SearchHit struct {
+	Name  string `json:"name"`
+	URI   string `json:"uri"`
+	Image string `json:"image,omitempty"`
+}
+
+// HandleSearchResults serves resp, encoded as JSON, for the given keyword.
+func (s *Server) HandleSearchResults(keyword string, resp *SearchResponse) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		panic(err)
+	}
+	s.HandleSearch(keyword, body)
+}
+
+// Search mirrors Mercury().Search, decoding the reply.
+func (s *Server) Search(keyword string, limit int, country, username string) (*SearchResponse, error) {
+	body, err := s.SearchRaw(keyword, limit, country, username)
+	if err != nil {
+		return nil, err
+	}
+	resp := &SearchResponse{}
+	if err = json.Unmarshal(body, resp); err != nil {
+		return nil, errors.Wrapf(err, "mercurytest: bad search reply for %q", keyword)
+	}
+	return resp, nil
+}
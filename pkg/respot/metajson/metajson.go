@@ -0,0 +1,364 @@
+// Package metajson defines a stable JSON schema for catalog metadata, independent of the generated protobuf types.
+//
+// IDs are base62 strings with an accompanying "spotify:" URI, durations are in
+// milliseconds, dates are ISO 8601 (possibly partial, e.g. "1997" or "1997-05"),
+// and images are resolved to CDN URLs.  Optional fields are omitted when empty.
+// Fields are only ever added to this schema, never renamed or removed.
+package metajson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/images"
+)
+
+// Ref is a reference to another catalog item.
+type Ref struct {
+	ID   string `json:"id"`
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// Image is an image resolved to its CDN URL.
+type Image struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// Track is the JSON form of a Spotify.Track.
+type Track struct {
+	ID          string            `json:"id"`
+	URI         string            `json:"uri"`
+	Name        string            `json:"name"`
+	DurationMs  int               `json:"duration_ms"`
+	TrackNumber int               `json:"track_number,omitempty"`
+	DiscNumber  int               `json:"disc_number,omitempty"`
+	Explicit    bool              `json:"explicit"`
+	Popularity  float32           `json:"popularity"`
+	Artists     []Ref             `json:"artists"`
+	ArtistNames string            `json:"artist_names"` // artist names joined by ", "
+	Album       *Ref              `json:"album,omitempty"`
+	ReleaseDate string            `json:"release_date,omitempty"` // from the album, if included
+	CoverURL    string            `json:"cover_url,omitempty"`    // from the album, if included
+	ExternalIDs map[string]string `json:"external_ids,omitempty"` // e.g. "isrc"
+}
+
+// Album is the JSON form of a Spotify.Album.
+type Album struct {
+	ID          string            `json:"id"`
+	URI         string            `json:"uri"`
+	Name        string            `json:"name"`
+	Type        string            `json:"type"` // "album", "single", "compilation" or "ep"
+	Label       string            `json:"label,omitempty"`
+	ReleaseDate string            `json:"release_date,omitempty"`
+	Popularity  float32           `json:"popularity"`
+	Genres      []string          `json:"genres,omitempty"`
+	Artists     []Ref             `json:"artists"`
+	ArtistNames string            `json:"artist_names"`
+	CoverURL    string            `json:"cover_url,omitempty"`
+	Images      []Image           `json:"images,omitempty"`
+	Discs       []Disc            `json:"discs,omitempty"`
+	Copyrights  []string          `json:"copyrights,omitempty"`   // prefixed with "(C) " or "(P) "
+	ExternalIDs map[string]string `json:"external_ids,omitempty"` // e.g. "upc"
+}
+
+// Disc is a disc of an Album.  Tracks carry names only if the album was hydrated.
+type Disc struct {
+	Number int    `json:"number"`
+	Name   string `json:"name,omitempty"`
+	Tracks []Ref  `json:"tracks"`
+}
+
+// Artist is the JSON form of a Spotify.Artist.
+type Artist struct {
+	ID          string   `json:"id"`
+	URI         string   `json:"uri"`
+	Name        string   `json:"name"`
+	Popularity  float32  `json:"popularity"`
+	Genres      []string `json:"genres,omitempty"`
+	PortraitURL string   `json:"portrait_url,omitempty"`
+	Images      []Image  `json:"images,omitempty"`
+	TopTracks   []Ref    `json:"top_tracks,omitempty"`
+	Albums      []Ref    `json:"albums,omitempty"`
+	Related     []Ref    `json:"related,omitempty"`
+}
+
+// Playlist is the JSON form of a Spotify.SelectedListContent.
+type Playlist struct {
+	ID            string         `json:"id,omitempty"`
+	URI           string         `json:"uri,omitempty"`
+	Name          string         `json:"name"`
+	Description   string         `json:"description,omitempty"`
+	Collaborative bool           `json:"collaborative"`
+	Revision      string         `json:"revision,omitempty"` // hex
+	Length        int            `json:"length"`
+	Items         []PlaylistItem `json:"items"`
+}
+
+// PlaylistItem is an entry of a Playlist.  Local files have no ID.
+type PlaylistItem struct {
+	ID        string `json:"id,omitempty"`
+	URI       string `json:"uri"`
+	AddedBy   string `json:"added_by,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"` // as reported by Spotify
+}
+
+// SearchResults is the JSON form of a catalog search.
+type SearchResults struct {
+	Query     string     `json:"query"`
+	Tracks    SearchHits `json:"tracks"`
+	Albums    SearchHits `json:"albums"`
+	Artists   SearchHits `json:"artists"`
+	Playlists SearchHits `json:"playlists"`
+}
+
+// SearchHits is a page of search hits for one entity kind.
+type SearchHits struct {
+	Total int   `json:"total"`
+	Hits  []Ref `json:"hits"`
+}
+
+// NewRef returns a Ref for the given GID.
+func NewRef(kind catalog.Kind, gid []byte, name string) Ref {
+	id, _ := catalog.FromGID(kind, gid)
+	return Ref{ID: id.Base62(), URI: id.URI(), Name: name}
+}
+
+// RefFromURI returns a Ref for a "spotify:" URI, e.g. from a search hit.
+func RefFromURI(uri, name string) Ref {
+	ref := Ref{URI: uri, Name: name}
+	if id, err := catalog.ParseID(uri); err == nil && id.GID() != nil {
+		ref.ID = id.Base62()
+	}
+	return ref
+}
+
+// FormatDate renders a possibly partial Spotify.Date as ISO 8601, or "" if unset.
+func FormatDate(d *Spotify.Date) string {
+	switch {
+	case d.GetYear() == 0:
+		return ""
+	case d.GetMonth() == 0:
+		return fmt.Sprintf("%04d", d.GetYear())
+	case d.GetDay() == 0:
+		return fmt.Sprintf("%04d-%02d", d.GetYear(), d.GetMonth())
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.GetYear(), d.GetMonth(), d.GetDay())
+}
+
+func externalIDs(ids []*Spotify.ExternalId) map[string]string {
+	if len(ids) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(ids))
+	for _, ext := range ids {
+		m[strings.ToLower(ext.GetTyp())] = ext.GetId()
+	}
+	return m
+}
+
+func artistRefs(artists []*Spotify.Artist) ([]Ref, string) {
+	refs := make([]Ref, 0, len(artists))
+	names := make([]string, 0, len(artists))
+	for _, a := range artists {
+		refs = append(refs, NewRef(catalog.KindArtist, a.GetGid(), a.GetName()))
+		if a.GetName() != "" {
+			names = append(names, a.GetName())
+		}
+	}
+	return refs, strings.Join(names, ", ")
+}
+
+func imageList(imgs []*Spotify.Image) []Image {
+	var out []Image
+	for _, img := range imgs {
+		if len(img.GetFileId()) == 0 {
+			continue
+		}
+		out = append(out, Image{
+			URL:    images.URL(img),
+			Width:  int(img.GetWidth()),
+			Height: int(img.GetHeight()),
+		})
+	}
+	return out
+}
+
+func bestURL(imgs []*Spotify.Image) string {
+	if img := images.Best(imgs, 640); img != nil {
+		return images.URL(img)
+	}
+	return ""
+}
+
+// FromTrack converts a track.
+func FromTrack(t *Spotify.Track) Track {
+	id, _ := catalog.FromGID(catalog.KindTrack, t.GetGid())
+	out := Track{
+		ID:          id.Base62(),
+		URI:         id.URI(),
+		Name:        t.GetName(),
+		DurationMs:  int(t.GetDuration()),
+		TrackNumber: int(t.GetNumber()),
+		DiscNumber:  int(t.GetDiscNumber()),
+		Explicit:    t.GetExplicit(),
+		Popularity:  t.GetPopularity(),
+		ExternalIDs: externalIDs(t.GetExternalId()),
+	}
+	out.Artists, out.ArtistNames = artistRefs(t.GetArtist())
+	if album := t.GetAlbum(); album != nil {
+		ref := NewRef(catalog.KindAlbum, album.GetGid(), album.GetName())
+		out.Album = &ref
+		out.ReleaseDate = FormatDate(album.GetDate())
+		out.CoverURL = bestURL(images.AlbumImages(album))
+	}
+	return out
+}
+
+// FromAlbum converts an album.  If graph is non-nil, disc tracks and artists
+// are named from it (see catalog.HydrateAlbum).
+func FromAlbum(a *Spotify.Album, graph *catalog.Graph) Album {
+	id, _ := catalog.FromGID(catalog.KindAlbum, a.GetGid())
+	imgs := images.AlbumImages(a)
+	out := Album{
+		ID:          id.Base62(),
+		URI:         id.URI(),
+		Name:        a.GetName(),
+		Type:        strings.ToLower(a.GetTyp().String()),
+		Label:       a.GetLabel(),
+		ReleaseDate: FormatDate(a.GetDate()),
+		Popularity:  a.GetPopularity(),
+		Genres:      a.GetGenre(),
+		CoverURL:    bestURL(imgs),
+		Images:      imageList(imgs),
+		ExternalIDs: externalIDs(a.GetExternalId()),
+	}
+
+	artists := a.GetArtist()
+	if graph != nil {
+		artists = make([]*Spotify.Artist, 0, len(a.GetArtist()))
+		for _, stub := range a.GetArtist() {
+			if full := graph.Artist(stub); full != nil {
+				stub = full
+			}
+			artists = append(artists, stub)
+		}
+	}
+	out.Artists, out.ArtistNames = artistRefs(artists)
+
+	for _, disc := range a.GetDisc() {
+		d := Disc{Number: int(disc.GetNumber()), Name: disc.GetName()}
+		for _, stub := range disc.GetTrack() {
+			name := stub.GetName()
+			if graph != nil {
+				if full := graph.Track(stub); full != nil {
+					name = full.GetName()
+				}
+			}
+			d.Tracks = append(d.Tracks, NewRef(catalog.KindTrack, stub.GetGid(), name))
+		}
+		out.Discs = append(out.Discs, d)
+	}
+	for _, c := range a.GetCopyright() {
+		out.Copyrights = append(out.Copyrights, "("+c.GetTyp().String()+") "+c.GetText())
+	}
+	return out
+}
+
+// FromArtist converts an artist.  If graph is non-nil, top tracks and albums
+// are named from it (see catalog.HydrateArtist); country selects the top track list.
+func FromArtist(a *Spotify.Artist, graph *catalog.Graph, country string) Artist {
+	id, _ := catalog.FromGID(catalog.KindArtist, a.GetGid())
+	imgs := images.ArtistImages(a)
+	out := Artist{
+		ID:          id.Base62(),
+		URI:         id.URI(),
+		Name:        a.GetName(),
+		Popularity:  a.GetPopularity(),
+		Genres:      a.GetGenre(),
+		PortraitURL: bestURL(imgs),
+		Images:      imageList(imgs),
+	}
+
+	var top *Spotify.TopTracks
+	for _, tt := range a.GetTopTrack() {
+		if top == nil || tt.GetCountry() == country {
+			top = tt
+		}
+	}
+	for _, stub := range top.GetTrack() {
+		name := stub.GetName()
+		if graph != nil {
+			if full := graph.Track(stub); full != nil {
+				name = full.GetName()
+			}
+		}
+		out.TopTracks = append(out.TopTracks, NewRef(catalog.KindTrack, stub.GetGid(), name))
+	}
+	for _, ag := range a.GetAlbumGroup() {
+		for _, stub := range ag.GetAlbum() {
+			name := stub.GetName()
+			if graph != nil {
+				if full := graph.Album(stub); full != nil {
+					name = full.GetName()
+				}
+			}
+			out.Albums = append(out.Albums, NewRef(catalog.KindAlbum, stub.GetGid(), name))
+		}
+	}
+	for _, rel := range a.GetRelated() {
+		out.Related = append(out.Related, NewRef(catalog.KindArtist, rel.GetGid(), rel.GetName()))
+	}
+	return out
+}
+
+// FromPlaylist converts a playlist; id may be the zero ID if unknown.
+func FromPlaylist(id catalog.ID, list *Spotify.SelectedListContent) Playlist {
+	out := Playlist{
+		Name:          list.GetAttributes().GetName(),
+		Description:   list.GetAttributes().GetDescription(),
+		Collaborative: list.GetAttributes().GetCollaborative(),
+		Revision:      fmt.Sprintf("%x", list.GetRevision()),
+		Length:        int(list.GetLength()),
+		Items:         []PlaylistItem{},
+	}
+	if id.IsValid() {
+		out.ID = id.Base62()
+		out.URI = id.URI()
+	}
+	for _, item := range list.GetContents().GetItems() {
+		out.Items = append(out.Items, PlaylistItem{
+			ID:        RefFromURI(item.GetUri(), "").ID,
+			URI:       item.GetUri(),
+			AddedBy:   item.GetAttributes().GetAddedBy(),
+			Timestamp: item.GetAttributes().GetTimestamp(),
+		})
+	}
+	if out.Length == 0 {
+		out.Length = len(out.Items)
+	}
+	return out
+}
+
+// Encoder writes one JSON value per line (NDJSON).
+type Encoder struct {
+	enc *json.Encoder
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &Encoder{enc}
+}
+
+// Encode writes v followed by a newline.
+func (e *Encoder) Encode(v interface{}) error {
+	return e.enc.Encode(v)
+}
@@ -0,0 +1,167 @@
+package metajson_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/metajson"
+	"github.com/golang/protobuf/proto"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func gid(b byte) []byte {
+	g := make([]byte, catalog.GIDLen)
+	g[15] = b
+	return g
+}
+
+// checkGolden encodes each value as NDJSON and compares the output with testdata/name.
+func checkGolden(t *testing.T, name string, values ...interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := metajson.NewEncoder(&buf)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("%s differs:\ngot\n%s\nwant\n%s", name, got, want)
+	}
+}
+
+func cover(b byte, size Spotify.Image_Size, px int32) *Spotify.Image {
+	return &Spotify.Image{FileId: []byte{0xab, b}, Size: size.Enum(), Width: proto.Int32(px), Height: proto.Int32(px)}
+}
+
+func testAlbum() *Spotify.Album {
+	return &Spotify.Album{
+		Gid:        gid(2),
+		Name:       proto.String("Ágætis byrjun"),
+		Artist:     []*Spotify.Artist{{Gid: gid(3), Name: proto.String("Sigur Rós")}},
+		Typ:        Spotify.Album_ALBUM.Enum(),
+		Label:      proto.String("Smekkleysa & <Fat Cat>"),
+		Date:       &Spotify.Date{Year: proto.Int32(1999), Month: proto.Int32(6)},
+		Popularity: proto.Float32(61),
+		Genre:      []string{"post-rock"},
+		Cover:      []*Spotify.Image{cover(1, Spotify.Image_DEFAULT, 300), cover(2, Spotify.Image_LARGE, 640)},
+		ExternalId: []*Spotify.ExternalId{{Typ: proto.String("UPC"), Id: proto.String("5033197154623")}},
+		Disc: []*Spotify.Disc{{
+			Number: proto.Int32(1),
+			Track:  []*Spotify.Track{{Gid: gid(1)}, {Gid: gid(4), Name: proto.String("Svefn-g-englar")}},
+		}},
+		Copyright: []*Spotify.Copyright{
+			{Typ: Spotify.Copyright_C.Enum(), Text: proto.String("1999 Smekkleysa")},
+			{Typ: Spotify.Copyright_P.Enum(), Text: proto.String("1999 Smekkleysa")},
+		},
+	}
+}
+
+func TestTrackGolden(t *testing.T) {
+	track := &Spotify.Track{
+		Gid:        gid(1),
+		Name:       proto.String("Intro"),
+		Album:      testAlbum(),
+		Artist:     []*Spotify.Artist{{Gid: gid(3), Name: proto.String("Sigur Rós")}, {Gid: gid(5)}},
+		Number:     proto.Int32(1),
+		DiscNumber: proto.Int32(1),
+		Duration:   proto.Int32(96000),
+		Popularity: proto.Float32(48.5),
+		Explicit:   proto.Bool(false),
+		ExternalId: []*Spotify.ExternalId{{Typ: proto.String("isrc"), Id: proto.String("GBAYE9900001")}},
+	}
+	bare := &Spotify.Track{Gid: gid(6), Name: proto.String("Untitled")}
+	checkGolden(t, "track.ndjson", metajson.FromTrack(track), metajson.FromTrack(bare))
+}
+
+func TestAlbumGolden(t *testing.T) {
+	album := testAlbum()
+	checkGolden(t, "album.ndjson", metajson.FromAlbum(album, nil), metajson.FromAlbum(&Spotify.Album{Gid: gid(7)}, nil))
+}
+
+func TestArtistGolden(t *testing.T) {
+	artist := &Spotify.Artist{
+		Gid:        gid(3),
+		Name:       proto.String("Sigur Rós"),
+		Popularity: proto.Float32(70),
+		Genre:      []string{"icelandic rock", "post-rock"},
+		Portrait:   []*Spotify.Image{cover(3, Spotify.Image_XLARGE, 1000)},
+		TopTrack: []*Spotify.TopTracks{
+			{Country: proto.String("IS"), Track: []*Spotify.Track{{Gid: gid(4)}}},
+			{Country: proto.String("SE"), Track: []*Spotify.Track{{Gid: gid(1), Name: proto.String("Intro")}}},
+		},
+		AlbumGroup: []*Spotify.AlbumGroup{{Album: []*Spotify.Album{{Gid: gid(2), Name: proto.String("Ágætis byrjun")}}}},
+		Related:    []*Spotify.Artist{{Gid: gid(8), Name: proto.String("Jónsi")}},
+	}
+	checkGolden(t, "artist.ndjson", metajson.FromArtist(artist, nil, "SE"), metajson.FromArtist(artist, nil, "US"))
+}
+
+func TestPlaylistGolden(t *testing.T) {
+	id, err := catalog.ParseID("spotify:playlist:37i9dQZF1DXcBWIGoYBM5M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := func(uri, by string, ts int64) *Spotify.Item {
+		return &Spotify.Item{Uri: proto.String(uri), Attributes: &Spotify.ItemAttributes{AddedBy: proto.String(by), Timestamp: proto.Int64(ts)}}
+	}
+	list := &Spotify.SelectedListContent{
+		Revision: []byte{0, 0, 0, 7, 0xca, 0xfe},
+		Attributes: &Spotify.ListAttributes{
+			Name:          proto.String("Today's \"Top\" Hits"),
+			Description:   proto.String("<b>new</b> music"),
+			Collaborative: proto.Bool(true),
+		},
+		Contents: &Spotify.ListItems{Items: []*Spotify.Item{
+			item("spotify:track:4uLU6hMCjMI75M1A2tKUQC", "bob", 1500000000000),
+			item("spotify:episode:512ojhOuo1ktJprKbVcKyQ", "", 0),
+			item("spotify:local:Artist:Album:Title:180", "alice", 0),
+		}},
+	}
+	checkGolden(t, "playlist.ndjson",
+		metajson.FromPlaylist(id, list),
+		metajson.FromPlaylist(catalog.ID{}, &Spotify.SelectedListContent{}))
+}
+
+func TestSearchGolden(t *testing.T) {
+	res := metajson.SearchResults{
+		Query:     "sigur rós",
+		Tracks:    metajson.SearchHits{Total: 1, Hits: []metajson.Ref{metajson.RefFromURI("spotify:track:4uLU6hMCjMI75M1A2tKUQC", "Intro")}},
+		Albums:    metajson.SearchHits{Hits: []metajson.Ref{}},
+		Artists:   metajson.SearchHits{Total: 250, Hits: []metajson.Ref{metajson.NewRef(catalog.KindArtist, gid(3), "Sigur Rós")}},
+		Playlists: metajson.SearchHits{Total: 1, Hits: []metajson.Ref{metajson.RefFromURI("spotify:user:bob:playlist:37i9dQZF1DXcBWIGoYBM5M", "This Is Sigur Rós")}},
+	}
+	checkGolden(t, "search.ndjson", res)
+}
+
+func TestFormatDate(t *testing.T) {
+	for _, tt := range []struct {
+		date *Spotify.Date
+		want string
+	}{
+		{nil, ""},
+		{&Spotify.Date{}, ""},
+		{&Spotify.Date{Year: proto.Int32(1997)}, "1997"},
+		{&Spotify.Date{Year: proto.Int32(1997), Month: proto.Int32(5)}, "1997-05"},
+		{&Spotify.Date{Year: proto.Int32(1997), Month: proto.Int32(5), Day: proto.Int32(21)}, "1997-05-21"},
+	} {
+		if got := metajson.FormatDate(tt.date); got != tt.want {
+			t.Errorf("FormatDate(%v) = %q, want %q", tt.date, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+// Package metastore is an embedded, append-only, file-backed store for catalog
+// metadata (tracks, albums, artists and playlist snapshots) keyed by GID.
+//
+// Each record is tagged with the time it was fetched and an optional etag.
+// The file is a log of checksummed records; an in-memory index maps each ID to
+// its latest record.  A torn record at the tail (e.g. after a crash) is
+// discarded on Open.  Compact rewrites the log with only live records.
+package metastore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/golang/protobuf/proto"
+)
+
+// Opts configures a Store.
+type Opts struct {
+	// MaxBytes caps the size of live records; the least recently fetched records are evicted beyond it.  0 means no cap.
+	MaxBytes int64
+
+	// CompactRatio triggers an automatic Compact when the file grows beyond this multiple of the live bytes (default 2).
+	CompactRatio float64
+}
+
+// Meta describes a stored record.
+type Meta struct {
+	FetchedAt time.Time
+	ETag      []byte
+}
+
+type key struct {
+	kind catalog.Kind
+	gid  [catalog.GIDLen]byte
+}
+
+type entry struct {
+	Meta
+	offset int64 // offset of the record's payload
+	size   int64 // total record size, including the header
+	body   int   // length of the encoded message
+}
+
+// Record layout:
+//
+//	u32 payload length | u32 crc32(payload) | payload
+//
+// where payload is:
+//
+//	u8 kind | u8 flags | [16]gid | i64 fetched (unix nanos) | u16 etag length | etag | message
+const (
+	recHeaderLen = 8
+	recFixedLen  = 1 + 1 + catalog.GIDLen + 8 + 2
+
+	flagTombstone = 1
+)
+
+// Store is a file-backed metadata store.  It is safe for concurrent use.
+type Store struct {
+	opts      Opts
+	path      string
+	mu        sync.Mutex
+	file      *os.File
+	fileBytes int64
+	liveBytes int64
+	index     map[key]*entry
+}
+
+// Open opens (or creates) the store at path.
+func Open(path string, opts Opts) (*Store, error) {
+	if opts.CompactRatio <= 1 {
+		opts.CompactRatio = 2
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening metastore %s", path)
+	}
+	s := &Store{
+		opts:  opts,
+		path:  path,
+		file:  file,
+		index: make(map[key]*entry),
+	}
+	if err = s.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load scans the log, building the index and truncating any torn tail record.
+func (s *Store) load() error {
+	r := bufio.NewReader(s.file)
+	var offset int64
+	hdr := make([]byte, recHeaderLen)
+	for {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			break
+		}
+		n := int64(binary.BigEndian.Uint32(hdr[0:4]))
+		sum := binary.BigEndian.Uint32(hdr[4:8])
+		if n < recFixedLen || n > 1<<30 {
+			break
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil || crc32.ChecksumIEEE(payload) != sum {
+			break
+		}
+		s.apply(payload, offset+recHeaderLen, recHeaderLen+n)
+		offset += recHeaderLen + n
+	}
+	s.fileBytes = offset
+	if err := s.file.Truncate(offset); err != nil {
+		return errors.Wrap(err, "truncating metastore")
+	}
+	_, err := s.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// apply updates the index for the record whose payload starts at offset.
+func (s *Store) apply(payload []byte, offset, size int64) {
+	var k key
+	k.kind = catalog.Kind(payload[0])
+	flags := payload[1]
+	copy(k.gid[:], payload[2:2+catalog.GIDLen])
+	fetched := int64(binary.BigEndian.Uint64(payload[2+catalog.GIDLen:]))
+	etagLen := int(binary.BigEndian.Uint16(payload[recFixedLen-2:]))
+	if recFixedLen+etagLen > len(payload) {
+		return
+	}
+
+	if old := s.index[k]; old != nil {
+		s.liveBytes -= old.size
+		delete(s.index, k)
+	}
+	if flags&flagTombstone != 0 {
+		return
+	}
+	s.index[k] = &entry{
+		Meta: Meta{
+			FetchedAt: time.Unix(0, fetched),
+			ETag:      append([]byte(nil), payload[recFixedLen:recFixedLen+etagLen]...),
+		},
+		offset: offset,
+		size:   size,
+		body:   len(payload) - recFixedLen - etagLen,
+	}
+	s.liveBytes += size
+}
+
+func keyOf(id catalog.ID) (key, error) {
+	gid := id.GID()
+	if gid == nil {
+		return key{}, errors.Wrapf(catalog.ErrBadID, "%v has no gid", id)
+	}
+	k := key{kind: id.Kind()}
+	copy(k.gid[:], gid)
+	return k, nil
+}
+
+func encodeRecord(k key, flags byte, meta Meta, body []byte) []byte {
+	n := recFixedLen + len(meta.ETag) + len(body)
+	buf := make([]byte, recHeaderLen+n)
+	p := buf[recHeaderLen:]
+	p[0] = byte(k.kind)
+	p[1] = flags
+	copy(p[2:], k.gid[:])
+	binary.BigEndian.PutUint64(p[2+catalog.GIDLen:], uint64(meta.FetchedAt.UnixNano()))
+	binary.BigEndian.PutUint16(p[recFixedLen-2:], uint16(len(meta.ETag)))
+	copy(p[recFixedLen:], meta.ETag)
+	copy(p[recFixedLen+len(meta.ETag):], body)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(n))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(p))
+	return buf
+}
+
+// appendRecord writes a record at the end of the log and indexes it.  s.mu must be held.
+func (s *Store) appendRecord(rec []byte) error {
+	if _, err := s.file.WriteAt(rec, s.fileBytes); err != nil {
+		return errors.Wrap(err, "writing metastore")
+	}
+	s.apply(rec[recHeaderLen:], s.fileBytes+recHeaderLen, int64(len(rec)))
+	s.fileBytes += int64(len(rec))
+	return nil
+}
+
+// Put stores msg under id, tagged with the current time and the given etag (may be nil).
+func (s *Store) Put(id catalog.ID, msg proto.Message, etag []byte) error {
+	k, err := keyOf(id)
+	if err != nil {
+		return err
+	}
+	if len(etag) > 0xFFFF {
+		return errors.New("etag too long")
+	}
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	rec := encodeRecord(k, 0, Meta{FetchedAt: time.Now(), ETag: etag}, body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.ErrClosed
+	}
+	if err = s.appendRecord(rec); err != nil {
+		return err
+	}
+	if err = s.enforceCap(); err != nil {
+		return err
+	}
+	return s.maybeCompact()
+}
+
+// Get decodes the record stored under id into msg.  ok is false if there is none.
+func (s *Store) Get(id catalog.ID, msg proto.Message) (meta Meta, ok bool, err error) {
+	k, err := keyOf(id)
+	if err != nil {
+		return Meta{}, false, err
+	}
+
+	s.mu.Lock()
+	e := s.index[k]
+	if e == nil || s.file == nil {
+		s.mu.Unlock()
+		return Meta{}, false, nil
+	}
+	meta = e.Meta
+	body := make([]byte, e.body)
+	_, err = s.file.ReadAt(body, e.offset+recFixedLen+int64(len(e.ETag)))
+	s.mu.Unlock()
+
+	if err != nil {
+		return Meta{}, false, errors.Wrap(err, "reading metastore")
+	}
+	if err = proto.Unmarshal(body, msg); err != nil {
+		return Meta{}, false, errors.Wrapf(err, "decoding %v", id)
+	}
+	return meta, true, nil
+}
+
+// Invalidate drops the record stored under id, if any.
+func (s *Store) Invalidate(id catalog.ID) error {
+	k, err := keyOf(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.ErrClosed
+	}
+	if s.index[k] == nil {
+		return nil
+	}
+	return s.appendRecord(encodeRecord(k, flagTombstone, Meta{FetchedAt: time.Now()}, nil))
+}
+
+// Len returns the number of live records.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.index)
+}
+
+// Size returns the number of bytes occupied by live records and by the whole file.
+func (s *Store) Size() (live, file int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.liveBytes, s.fileBytes
+}
+
+// enforceCap evicts the least recently fetched records until under Opts.MaxBytes.  s.mu must be held.
+func (s *Store) enforceCap() error {
+	if s.opts.MaxBytes <= 0 || s.liveBytes <= s.opts.MaxBytes {
+		return nil
+	}
+	keys := make([]key, 0, len(s.index))
+	for k := range s.index {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := s.index[keys[i]], s.index[keys[j]]
+		if !a.FetchedAt.Equal(b.FetchedAt) {
+			return a.FetchedAt.Before(b.FetchedAt)
+		}
+		return a.offset < b.offset
+	})
+	for _, k := range keys {
+		if s.liveBytes <= s.opts.MaxBytes {
+			break
+		}
+		if err := s.appendRecord(encodeRecord(k, flagTombstone, Meta{FetchedAt: time.Now()}, nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) maybeCompact() error {
+	const minCompactBytes = 1 << 20
+	if s.fileBytes < minCompactBytes || float64(s.fileBytes) < s.opts.CompactRatio*float64(s.liveBytes) {
+		return nil
+	}
+	return s.compact()
+}
+
+// Compact rewrites the file so that it only holds live records.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.ErrClosed
+	}
+	return s.compact()
+}
+
+func (s *Store) compact() error {
+	tmpPath := s.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "compacting metastore")
+	}
+
+	// Write records in file order so that reads stay mostly sequential
+	keys := make([]key, 0, len(s.index))
+	for k := range s.index {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return s.index[keys[i]].offset < s.index[keys[j]].offset
+	})
+
+	index := make(map[key]*entry, len(keys))
+	var offset int64
+	w := bufio.NewWriter(tmp)
+	for _, k := range keys {
+		e := s.index[k]
+		rec := make([]byte, e.size)
+		if _, err = s.file.ReadAt(rec, e.offset-recHeaderLen); err != nil {
+			break
+		}
+		if _, err = w.Write(rec); err != nil {
+			break
+		}
+		moved := *e
+		moved.offset = offset + recHeaderLen
+		index[k] = &moved
+		offset += e.size
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, s.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "compacting metastore")
+	}
+
+	s.file.Close()
+	s.file = tmp
+	s.index = index
+	s.fileBytes = offset
+	s.liveBytes = offset
+	return nil
+}
+
+// Close flushes and closes the store.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Sync()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
@@ -0,0 +1,234 @@
+package metastore_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/metastore"
+	"github.com/golang/protobuf/proto"
+)
+
+func trackID(t *testing.T, b byte) catalog.ID {
+	t.Helper()
+	id, err := catalog.FromGID(catalog.KindTrack, bytes.Repeat([]byte{b}, catalog.GIDLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func open(t *testing.T, path string, opts metastore.Opts) *metastore.Store {
+	t.Helper()
+	s, err := metastore.Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func put(t *testing.T, s *metastore.Store, id catalog.ID, name string) {
+	t.Helper()
+	if err := s.Put(id, &Spotify.Track{Name: proto.String(name)}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// name returns the name of the track stored under id, or "" if there is none.
+func name(t *testing.T, s *metastore.Store, id catalog.ID) string {
+	t.Helper()
+	track := &Spotify.Track{}
+	_, ok, err := s.Get(id, track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		return ""
+	}
+	return track.GetName()
+}
+
+func TestRecordLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta")
+	s := open(t, path, metastore.Opts{})
+	id := trackID(t, 7)
+	track := &Spotify.Track{Name: proto.String("Song")}
+	before := time.Now()
+	if err := s.Put(id, track, []byte("rev")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := proto.Marshal(track)
+	payload := raw[8:]
+	if n := binary.BigEndian.Uint32(raw[0:4]); int(n) != len(payload) || n != uint32(1+1+16+8+2+3+len(body)) {
+		t.Fatalf("payload length %d, file holds %d", n, len(payload))
+	}
+	if sum := binary.BigEndian.Uint32(raw[4:8]); sum != crc32.ChecksumIEEE(payload) {
+		t.Errorf("crc %08x, want %08x", sum, crc32.ChecksumIEEE(payload))
+	}
+	if payload[0] != byte(catalog.KindTrack) || payload[1] != 0 || !bytes.Equal(payload[2:18], id.GID()) {
+		t.Errorf("kind %d, flags %d, gid %x", payload[0], payload[1], payload[2:18])
+	}
+	if fetched := time.Unix(0, int64(binary.BigEndian.Uint64(payload[18:26]))); fetched.Before(before) || fetched.After(time.Now()) {
+		t.Errorf("fetched at %v", fetched)
+	}
+	if n := binary.BigEndian.Uint16(payload[26:28]); n != 3 || string(payload[28:31]) != "rev" {
+		t.Errorf("etag %q", payload[28:28+n])
+	}
+	if !bytes.Equal(payload[31:], body) {
+		t.Errorf("message %x, want %x", payload[31:], body)
+	}
+}
+
+func TestOpenDropsTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta")
+	s := open(t, path, metastore.Opts{})
+	put(t, s, trackID(t, 1), "one")
+	_, good := s.Size()
+	put(t, s, trackID(t, 2), "two")
+	s.Close()
+
+	// Cut the second record short, as a crash mid-write would
+	if err := os.Truncate(path, good+10); err != nil {
+		t.Fatal(err)
+	}
+	s = open(t, path, metastore.Opts{})
+	if s.Len() != 1 || name(t, s, trackID(t, 1)) != "one" || name(t, s, trackID(t, 2)) != "" {
+		t.Fatalf("after a torn tail: %d records", s.Len())
+	}
+	if fi, _ := os.Stat(path); fi.Size() != good {
+		t.Errorf("file is %d bytes, want the torn record truncated to %d", fi.Size(), good)
+	}
+
+	// New records go where the torn one was
+	put(t, s, trackID(t, 3), "three")
+	s.Close()
+	s = open(t, path, metastore.Opts{})
+	defer s.Close()
+	if s.Len() != 2 || name(t, s, trackID(t, 3)) != "three" {
+		t.Errorf("lost a record written after recovery: %d records", s.Len())
+	}
+}
+
+func TestOpenDropsCorruptTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta")
+	s := open(t, path, metastore.Opts{})
+	put(t, s, trackID(t, 1), "one")
+	put(t, s, trackID(t, 2), "two")
+	_, size := s.Size()
+	s.Close()
+
+	raw, _ := os.ReadFile(path)
+	raw[size-1] ^= 0xff
+	os.WriteFile(path, raw, 0o644)
+	s = open(t, path, metastore.Opts{})
+	defer s.Close()
+	if s.Len() != 1 || name(t, s, trackID(t, 2)) != "" {
+		t.Errorf("a record failing its crc was loaded")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta")
+	s := open(t, path, metastore.Opts{})
+	for i := 0; i < 10; i++ {
+		put(t, s, trackID(t, 1), "one")
+	}
+	put(t, s, trackID(t, 2), "two")
+	put(t, s, trackID(t, 3), "three")
+	if err := s.Invalidate(trackID(t, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	live, file := s.Size()
+	if live != file {
+		t.Errorf("after Compact, %d live bytes in a %d byte file", live, file)
+	}
+	if fi, _ := os.Stat(path); fi.Size() != file {
+		t.Errorf("file is %d bytes, store says %d", fi.Size(), file)
+	}
+	if name(t, s, trackID(t, 1)) != "one" || name(t, s, trackID(t, 3)) != "three" {
+		t.Error("lost a live record")
+	}
+
+	// The compacted store is written to and reopened as usual
+	put(t, s, trackID(t, 4), "four")
+	s.Close()
+	s = open(t, path, metastore.Opts{})
+	defer s.Close()
+	if s.Len() != 3 || name(t, s, trackID(t, 2)) != "" || name(t, s, trackID(t, 4)) != "four" {
+		t.Errorf("reopened with %d records", s.Len())
+	}
+}
+
+func TestMaxBytesEvictsOldest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta")
+	s := open(t, path, metastore.Opts{})
+	put(t, s, trackID(t, 1), "one")
+	one, _ := s.Size()
+	s.Close()
+
+	// Room for two records
+	s = open(t, path, metastore.Opts{MaxBytes: 2*one + one/2})
+	put(t, s, trackID(t, 2), "two")
+	put(t, s, trackID(t, 3), "three")
+	if s.Len() != 2 || name(t, s, trackID(t, 1)) != "" {
+		t.Errorf("kept %d records, the oldest %q", s.Len(), name(t, s, trackID(t, 1)))
+	}
+
+	// Refetching a record makes it the newest
+	put(t, s, trackID(t, 2), "two")
+	put(t, s, trackID(t, 4), "four")
+	if name(t, s, trackID(t, 2)) != "two" || name(t, s, trackID(t, 3)) != "" {
+		t.Error("evicted a recently fetched record before an older one")
+	}
+	s.Close()
+
+	s = open(t, path, metastore.Opts{})
+	defer s.Close()
+	if s.Len() != 2 {
+		t.Errorf("evictions were not persisted: %d records", s.Len())
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta")
+	s := open(t, path, metastore.Opts{})
+	put(t, s, trackID(t, 1), "one")
+	put(t, s, trackID(t, 2), "two")
+	if err := s.Invalidate(trackID(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Invalidate(trackID(t, 9)); err != nil {
+		t.Fatal(err)
+	}
+	if name(t, s, trackID(t, 1)) != "" || s.Len() != 1 {
+		t.Error("invalidated record still served")
+	}
+	if live, _ := s.Size(); live == 0 {
+		t.Error("no live bytes left")
+	}
+	s.Close()
+
+	s = open(t, path, metastore.Opts{})
+	defer s.Close()
+	if name(t, s, trackID(t, 1)) != "" || name(t, s, trackID(t, 2)) != "two" {
+		t.Error("invalidation was not persisted")
+	}
+	if err := s.Invalidate(catalog.UserID("bob")); err == nil {
+		t.Error("invalidated an ID without a GID")
+	}
+}
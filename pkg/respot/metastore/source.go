@@ -0,0 +1,257 @@
+package metastore
+
+import (
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/golang/protobuf/proto"
+)
+
+// CachedSource returns a read-through catalog.Source: items are served from
+// store when present and younger than maxAge (0 means they never go stale),
+// otherwise fetched from src and written back.
+//
+// Shows and episodes are cached too if src is a catalog.PodcastSource.
+//
+// If src fails and a stale copy exists, the stale copy is returned so that
+// tools keep working offline.
+//
+// The returned Source is a catalog.BatchSource if src is; batches only fetch
+// the items that are missing or stale.
+func CachedSource(src catalog.Source, store *Store, maxAge time.Duration) catalog.Source {
+	cs := &cachedSource{
+		src:    src,
+		store:  store,
+		maxAge: maxAge,
+	}
+	if batch, ok := src.(catalog.BatchSource); ok {
+		return &cachedBatchSource{cs, batch}
+	}
+	return cs
+}
+
+type cachedSource struct {
+	src    catalog.Source
+	store  *Store
+	maxAge time.Duration
+}
+
+func (cs *cachedSource) fresh(meta Meta) bool {
+	return cs.maxAge <= 0 || time.Since(meta.FetchedAt) < cs.maxAge
+}
+
+// get serves msg from the store or via fetch, which returns the fetched message and its etag.
+func (cs *cachedSource) get(id catalog.ID, msg proto.Message, fetch func() (proto.Message, []byte, error)) (proto.Message, error) {
+	meta, ok, _ := cs.store.Get(id, msg)
+	if ok && cs.fresh(meta) {
+		return msg, nil
+	}
+	fetched, etag, err := fetch()
+	if err != nil {
+		if ok {
+			return msg, nil
+		}
+		return nil, err
+	}
+	cs.store.Put(id, fetched, etag)
+	return fetched, nil
+}
+
+func (cs *cachedSource) GetTrack(id catalog.ID) (*Spotify.Track, error) {
+	msg, err := cs.get(id, &Spotify.Track{}, func() (proto.Message, []byte, error) {
+		t, err := cs.src.GetTrack(id)
+		return t, nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*Spotify.Track), nil
+}
+
+func (cs *cachedSource) GetAlbum(id catalog.ID) (*Spotify.Album, error) {
+	msg, err := cs.get(id, &Spotify.Album{}, func() (proto.Message, []byte, error) {
+		a, err := cs.src.GetAlbum(id)
+		return a, nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*Spotify.Album), nil
+}
+
+func (cs *cachedSource) GetArtist(id catalog.ID) (*Spotify.Artist, error) {
+	msg, err := cs.get(id, &Spotify.Artist{}, func() (proto.Message, []byte, error) {
+		a, err := cs.src.GetArtist(id)
+		return a, nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*Spotify.Artist), nil
+}
+
+// GetPlaylist stores playlist snapshots tagged with their revision as the etag.
+func (cs *cachedSource) GetPlaylist(id catalog.ID) (*Spotify.SelectedListContent, error) {
+	msg, err := cs.get(id, &Spotify.SelectedListContent{}, func() (proto.Message, []byte, error) {
+		list, err := cs.src.GetPlaylist(id)
+		return list, list.GetRevision(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*Spotify.SelectedListContent), nil
+}
+
+func (cs *cachedSource) GetShow(id catalog.ID) (*Spotify.Show, error) {
+	ps, ok := cs.src.(catalog.PodcastSource)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	msg, err := cs.get(id, &Spotify.Show{}, func() (proto.Message, []byte, error) {
+		show, err := ps.GetShow(id)
+		return show, nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*Spotify.Show), nil
+}
+
+func (cs *cachedSource) GetEpisode(id catalog.ID) (*Spotify.Episode, error) {
+	ps, ok := cs.src.(catalog.PodcastSource)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	msg, err := cs.get(id, &Spotify.Episode{}, func() (proto.Message, []byte, error) {
+		ep, err := ps.GetEpisode(id)
+		return ep, nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*Spotify.Episode), nil
+}
+
+type cachedBatchSource struct {
+	*cachedSource
+	batch catalog.BatchSource
+}
+
+// getBatch fills out from the store and fetches the rest in one batch, which
+// returns messages in the order of the ids it is given.  Items that fail to
+// fetch are served stale if possible and otherwise left nil.
+func (cs *cachedBatchSource) getBatch(ids []catalog.ID, out []proto.Message, fetch func([]catalog.ID) ([]proto.Message, error)) error {
+	var missing []catalog.ID
+	var at []int
+	stale := make(map[int]bool)
+	for i, id := range ids {
+		meta, ok, _ := cs.store.Get(id, out[i])
+		if ok && cs.fresh(meta) {
+			continue
+		}
+		stale[i] = ok
+		missing = append(missing, id)
+		at = append(at, i)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	fetched, err := fetch(missing)
+	for j, i := range at {
+		switch {
+		case err == nil && j < len(fetched) && fetched[j] != nil:
+			cs.store.Put(ids[i], fetched[j], nil)
+			out[i] = fetched[j]
+		case stale[i]:
+			// keep the stale copy
+		case err != nil:
+			return err
+		default:
+			out[i] = nil
+		}
+	}
+	return nil
+}
+
+func (cs *cachedBatchSource) GetTracks(ids []catalog.ID) ([]*Spotify.Track, error) {
+	out := make([]proto.Message, len(ids))
+	for i := range out {
+		out[i] = &Spotify.Track{}
+	}
+	err := cs.getBatch(ids, out, func(ids []catalog.ID) ([]proto.Message, error) {
+		tracks, err := cs.batch.GetTracks(ids)
+		msgs := make([]proto.Message, len(tracks))
+		for i, t := range tracks {
+			if t != nil {
+				msgs[i] = t
+			}
+		}
+		return msgs, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	tracks := make([]*Spotify.Track, len(ids))
+	for i, msg := range out {
+		if msg != nil {
+			tracks[i] = msg.(*Spotify.Track)
+		}
+	}
+	return tracks, nil
+}
+
+func (cs *cachedBatchSource) GetAlbums(ids []catalog.ID) ([]*Spotify.Album, error) {
+	out := make([]proto.Message, len(ids))
+	for i := range out {
+		out[i] = &Spotify.Album{}
+	}
+	err := cs.getBatch(ids, out, func(ids []catalog.ID) ([]proto.Message, error) {
+		albums, err := cs.batch.GetAlbums(ids)
+		msgs := make([]proto.Message, len(albums))
+		for i, a := range albums {
+			if a != nil {
+				msgs[i] = a
+			}
+		}
+		return msgs, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	albums := make([]*Spotify.Album, len(ids))
+	for i, msg := range out {
+		if msg != nil {
+			albums[i] = msg.(*Spotify.Album)
+		}
+	}
+	return albums, nil
+}
+
+func (cs *cachedBatchSource) GetArtists(ids []catalog.ID) ([]*Spotify.Artist, error) {
+	out := make([]proto.Message, len(ids))
+	for i := range out {
+		out[i] = &Spotify.Artist{}
+	}
+	err := cs.getBatch(ids, out, func(ids []catalog.ID) ([]proto.Message, error) {
+		artists, err := cs.batch.GetArtists(ids)
+		msgs := make([]proto.Message, len(artists))
+		for i, a := range artists {
+			if a != nil {
+				msgs[i] = a
+			}
+		}
+		return msgs, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	artists := make([]*Spotify.Artist, len(ids))
+	for i, msg := range out {
+		if msg != nil {
+			artists[i] = msg.(*Spotify.Artist)
+		}
+	}
+	return artists, nil
+}
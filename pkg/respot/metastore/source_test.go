@@ -0,0 +1,102 @@
+package metastore_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/arcspace/go-librespot/pkg/respot/metastore"
+	"github.com/golang/protobuf/proto"
+)
+
+func track(name string) *Spotify.Track {
+	return &Spotify.Track{Name: proto.String(name)}
+}
+
+func TestCachedSourceServesFresh(t *testing.T) {
+	srv := mercurytest.New()
+	id := trackID(t, 1)
+	srv.HandleTrack(id.Hex(), track("one"))
+	store := open(t, filepath.Join(t.TempDir(), "meta"), metastore.Opts{})
+	defer store.Close()
+	src := metastore.CachedSource(catalog.NewSource(srv), store, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		got, err := src.GetTrack(id)
+		if err != nil || got.GetName() != "one" {
+			t.Fatalf("got %v, err %v", got, err)
+		}
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("made %d requests, want the second served from the store", n)
+	}
+}
+
+func TestCachedSourceFallsBackToStale(t *testing.T) {
+	srv := mercurytest.New()
+	id := trackID(t, 1)
+	srv.HandleTrack(id.Hex(), track("one"))
+	store := open(t, filepath.Join(t.TempDir(), "meta"), metastore.Opts{})
+	defer store.Close()
+	src := metastore.CachedSource(catalog.NewSource(srv), store, time.Nanosecond)
+	if _, err := src.GetTrack(id); err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy is stale at once, so it is refetched and, when that fails, served anyway
+	srv.Reset()
+	got, err := src.GetTrack(id)
+	if err != nil || got.GetName() != "one" {
+		t.Errorf("got %v, err %v; want the stale copy", got, err)
+	}
+	if len(srv.Requests()) != 1 {
+		t.Error("stale copy served without trying to refetch it")
+	}
+	if _, err = src.GetTrack(trackID(t, 2)); err == nil {
+		t.Error("no error for an uncached item that failed to fetch")
+	}
+}
+
+func TestCachedBatchSourceKeepsStale(t *testing.T) {
+	srv := mercurytest.New()
+	a, b, c := trackID(t, 1), trackID(t, 2), trackID(t, 3)
+	srv.HandleTrack(a.Hex(), track("a"))
+	srv.HandleTrack(b.Hex(), track("b"))
+	store := open(t, filepath.Join(t.TempDir(), "meta"), metastore.Opts{})
+	defer store.Close()
+	src := metastore.CachedSource(catalog.NewSource(srv), store, time.Nanosecond).(catalog.BatchSource)
+	if _, err := src.GetTracks([]catalog.ID{a, b}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a can no longer be fetched, b has changed and c was never cached
+	srv.Reset()
+	srv.HandleTrack(b.Hex(), track("b2"))
+	tracks, err := src.GetTracks([]catalog.ID{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracks[0].GetName() != "a" {
+		t.Errorf("got %v for a, want its stale copy", tracks[0])
+	}
+	if tracks[1].GetName() != "b2" {
+		t.Errorf("got %v for b, want it refetched", tracks[1])
+	}
+	if tracks[2] != nil {
+		t.Errorf("got %v for c, want nil", tracks[2])
+	}
+
+	// Only items that are missing or stale are fetched
+	srv.Reset()
+	srv.HandleTrack(a.Hex(), track("a"))
+	src = metastore.CachedSource(catalog.NewSource(srv), store, time.Hour).(catalog.BatchSource)
+	if tracks, err = src.GetTracks([]catalog.ID{a, b}); err != nil || tracks[1].GetName() != "b2" {
+		t.Fatalf("got %v, err %v", tracks, err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("made %d requests for fresh items", n)
+	}
+}
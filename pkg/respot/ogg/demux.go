@@ -0,0 +1,324 @@
+package ogg
+
+import (
+	"io"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// Packet is a single Vorbis packet read from the stream.
+type Packet struct {
+	Data []byte
+
+	// Granule is the granule position of the page this packet completes on if
+	// it is the last packet completing there, otherwise -1.
+	Granule int64
+}
+
+// Demuxer reads Vorbis packets from an Ogg stream and seeks by time.  It reads
+// through an io.ReaderAt, such as a stream.Reader, so only the pages visited
+// while seeking are fetched.  A leading Spotify header is skipped automatically.
+type Demuxer struct {
+	Headers VorbisHeaders
+
+	r      io.ReaderAt
+	size   int64
+	serial uint32
+
+	// Position of the first audio packet
+	dataPage int64
+	dataSeg  int
+
+	next     int64 // offset of the page after the current one
+	page     *Page
+	seg      int
+	dataPos  int
+	partial  []byte
+	skipCont bool // discard the continued packet fragment that starts the next page, after losing its start
+
+	totalSamples int64 // -1 until computed
+}
+
+// NewDemuxer reads the Vorbis headers of the stream in r, which is size bytes long.
+func NewDemuxer(r io.ReaderAt, size int64) (*Demuxer, error) {
+	first, err := FindPage(r, 0, SpotifyHeaderLen+1)
+	if err != nil {
+		return nil, errors.Wrap(err, "ogg: no page at start of stream")
+	}
+	d := &Demuxer{
+		r:            r,
+		size:         size,
+		serial:       first.Serial,
+		next:         first.Offset,
+		totalSamples: -1,
+	}
+
+	var packets [3][]byte
+	for i := range packets {
+		pkt, err := d.ReadPacket()
+		if err != nil {
+			return nil, errors.Wrap(err, "ogg: reading vorbis headers")
+		}
+		packets[i] = pkt.Data
+	}
+	h := &d.Headers
+	h.Identification, h.Comment, h.Setup = packets[0], packets[1], packets[2]
+	if h.Info, err = ParseIdentification(h.Identification); err != nil {
+		return nil, err
+	}
+	if h.Comments, err = ParseComments(h.Comment); err != nil {
+		return nil, err
+	}
+	if err = checkHeader(h.Setup, PacketSetup); err != nil {
+		return nil, err
+	}
+
+	if d.page != nil && d.seg < len(d.page.Lacing) {
+		d.dataPage, d.dataSeg = d.page.Offset, d.seg
+	} else {
+		d.dataPage, d.dataSeg = d.next, 0
+	}
+	return d, nil
+}
+
+// SampleRate returns the sample rate of the stream.
+func (d *Demuxer) SampleRate() int {
+	return d.Headers.Info.SampleRate
+}
+
+// ReadPacket returns the next packet, or io.EOF at the end of the stream.
+func (d *Demuxer) ReadPacket() (Packet, error) {
+	discard := false
+	for {
+		if d.page == nil || d.seg >= len(d.page.Lacing) {
+			if err := d.nextPage(&discard); err != nil {
+				return Packet{}, err
+			}
+			continue
+		}
+
+		l := int(d.page.Lacing[d.seg])
+		if !discard {
+			d.partial = append(d.partial, d.page.Data[d.dataPos:d.dataPos+l]...)
+		}
+		d.seg++
+		d.dataPos += l
+		if l == 255 {
+			continue
+		}
+		if discard {
+			discard = false
+			continue
+		}
+
+		pkt := Packet{Data: d.partial, Granule: -1}
+		d.partial = nil
+		if d.lastOnPage() {
+			pkt.Granule = d.page.Granule
+		}
+		return pkt, nil
+	}
+}
+
+// lastOnPage reports whether no further packet completes on the current page.
+func (d *Demuxer) lastOnPage() bool {
+	for _, l := range d.page.Lacing[d.seg:] {
+		if l < 255 {
+			return false
+		}
+	}
+	return true
+}
+
+// nextPage loads the next page of our stream, resynchronizing past corrupt data.
+func (d *Demuxer) nextPage(discard *bool) error {
+	for {
+		if d.next >= d.size {
+			return io.EOF
+		}
+		p, err := ReadPageAt(d.r, d.next)
+		if err == ErrBadPage {
+			if p, err = FindPage(d.r, d.next+1, d.size); err == nil {
+				// Lost the rest of whatever packet was in progress, and so
+				// the start of one continuing on the page found
+				d.partial = nil
+				d.skipCont = true
+			}
+		}
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return err
+		}
+		d.next = p.Offset + int64(p.Len())
+		if p.Serial != d.serial {
+			continue
+		}
+
+		d.page, d.seg, d.dataPos = p, 0, 0
+		if p.Continued() && d.skipCont {
+			*discard = true
+		} else if !p.Continued() {
+			d.partial = nil
+		}
+		d.skipCont = false
+		return nil
+	}
+}
+
+// Rewind positions the demuxer at the first audio packet.
+func (d *Demuxer) Rewind() error {
+	d.partial = nil
+	d.skipCont = false
+	d.page = nil
+	d.next = d.dataPage
+	if d.dataSeg == 0 {
+		return nil
+	}
+	p, err := ReadPageAt(d.r, d.dataPage)
+	if err != nil {
+		return err
+	}
+	d.page, d.next = p, p.Offset+int64(p.Len())
+	d.seg, d.dataPos = 0, 0
+	for ; d.seg < d.dataSeg; d.seg++ {
+		d.dataPos += int(p.Lacing[d.seg])
+	}
+	return nil
+}
+
+// bisectWindow is the span below which seeking switches from bisection to a linear scan.
+const bisectWindow = 64 << 10
+
+// SeekSample positions the demuxer so that the next packet read starts at or
+// before sample, and returns the granule position that packet starts at.  A
+// decoder must still discard output before sample (and Vorbis decoders produce
+// no output for the first packet after a seek).
+func (d *Demuxer) SeekSample(sample int64) (int64, error) {
+	if sample <= 0 {
+		return 0, d.Rewind()
+	}
+
+	var best *Page
+	lo, hi := d.dataPage, d.size
+	for hi-lo > bisectWindow {
+		mid := lo + (hi-lo)/2
+		p, err := d.findGranulePage(mid, hi)
+		if err == io.EOF {
+			hi = mid
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if p.Granule < sample {
+			best = p
+			lo = p.Offset + int64(p.Len())
+		} else {
+			hi = mid
+		}
+	}
+
+	// Linear scan for the last page ending before sample
+	for off := lo; off < d.size; {
+		p, err := ReadPageAt(d.r, off)
+		if err == ErrBadPage {
+			p, err = FindPage(d.r, off+1, d.size)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		off = p.Offset + int64(p.Len())
+		if p.Serial != d.serial || p.Granule == -1 {
+			continue
+		}
+		if p.Granule >= sample {
+			break
+		}
+		best = p
+	}
+
+	if best == nil || best.Offset < d.dataPage {
+		return 0, d.Rewind()
+	}
+
+	// The packet starting at best.Granule is the one after the last packet
+	// completing on best: either a fragment at the end of best, continued on
+	// the next page, or the first packet of the next page.
+	d.page, d.next = best, best.Offset+int64(best.Len())
+	d.seg, d.dataPos = len(best.Lacing), len(best.Data)
+	for i := len(best.Lacing) - 1; i >= 0 && best.Lacing[i] == 255; i-- {
+		d.seg--
+		d.dataPos -= 255
+	}
+	d.partial = nil
+	d.skipCont = false
+	return best.Granule, nil
+}
+
+// findGranulePage returns the first page of our stream with a granule position starting in [off, limit).
+func (d *Demuxer) findGranulePage(off, limit int64) (*Page, error) {
+	for off < limit {
+		p, err := FindPage(d.r, off, limit)
+		if err != nil {
+			return nil, err
+		}
+		if p.Serial == d.serial && p.Granule != -1 {
+			return p, nil
+		}
+		off = p.Offset + int64(p.Len())
+	}
+	return nil, io.EOF
+}
+
+// SeekTime seeks to the given offset from the start and returns the position actually reached.
+func (d *Demuxer) SeekTime(t time.Duration) (time.Duration, error) {
+	rate := int64(d.SampleRate())
+	sample := int64(t) * rate / int64(time.Second)
+	at, err := d.SeekSample(sample)
+	return time.Duration(at * int64(time.Second) / rate), err
+}
+
+// TotalSamples returns the exact length of the stream in samples per channel,
+// taken from the granule position of its last page.
+func (d *Demuxer) TotalSamples() (int64, error) {
+	if d.totalSamples >= 0 {
+		return d.totalSamples, nil
+	}
+	for end := d.size; end > d.dataPage; end -= bisectWindow {
+		start := end - bisectWindow
+		if start < d.dataPage {
+			start = d.dataPage
+		}
+		last := int64(-1)
+		for off := start; off < d.size; {
+			p, err := FindPage(d.r, off, d.size)
+			if err != nil {
+				break
+			}
+			if p.Serial == d.serial && p.Granule != -1 {
+				last = p.Granule
+			}
+			off = p.Offset + int64(p.Len())
+		}
+		if last >= 0 {
+			d.totalSamples = last
+			return last, nil
+		}
+	}
+	return 0, errors.New("ogg: no granule position found")
+}
+
+// Duration returns the exact duration of the stream.
+func (d *Demuxer) Duration() (time.Duration, error) {
+	samples, err := d.TotalSamples()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(samples * int64(time.Second) / int64(d.SampleRate())), nil
+}
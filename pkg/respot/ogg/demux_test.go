@@ -0,0 +1,149 @@
+package ogg_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/arcspace/go-librespot/pkg/respot/ogg"
+)
+
+const (
+	packetLen     = 300 // spans two lacing segments
+	packetSamples = 100
+	segsPerPage   = 5 // so that packets continue across pages
+)
+
+// testStream lays out the Vorbis headers and n audio packets of packetSamples
+// each.  Packet i holds its index in its first four bytes.
+func testStream(n int) []byte {
+	ident := make([]byte, 30)
+	ident[0] = ogg.PacketIdentification
+	copy(ident[1:], "vorbis")
+	ident[11] = 2
+	binary.LittleEndian.PutUint32(ident[12:], 44100)
+	ident[29] = 1
+	comments := ogg.VorbisComments{Vendor: "test"}
+	setup := append([]byte{ogg.PacketSetup}, "vorbis"...)
+
+	var buf bytes.Buffer
+	seq := uint32(0)
+	emit := func(p *ogg.Page) {
+		p.Seq = seq
+		seq++
+		buf.Write(p.Bytes())
+	}
+	emit(&ogg.Page{HeaderType: ogg.FlagBOS, Lacing: []byte{30}, Data: ident})
+	c := comments.Packet()
+	emit(&ogg.Page{Lacing: []byte{byte(len(c)), byte(len(setup))}, Data: append(c, setup...)})
+
+	page := &ogg.Page{Granule: -1}
+	for i := 0; i < n; i++ {
+		packet := make([]byte, packetLen)
+		binary.BigEndian.PutUint32(packet, uint32(i))
+		for rest := packet; ; {
+			if len(page.Lacing) == segsPerPage {
+				emit(page)
+				page = &ogg.Page{Granule: -1}
+				if len(rest) < len(packet) {
+					page.HeaderType = ogg.FlagContinued
+				}
+			}
+			l := len(rest)
+			if l >= 255 {
+				l = 255
+			}
+			page.Lacing = append(page.Lacing, byte(l))
+			page.Data = append(page.Data, rest[:l]...)
+			rest = rest[l:]
+			if l < 255 {
+				page.Granule = int64(i+1) * packetSamples
+				break
+			}
+		}
+	}
+	emit(page)
+	return buf.Bytes()
+}
+
+func TestSeekSampleDeliversPacketAtGranule(t *testing.T) {
+	const packets = 400 // over 64 KiB, so seeking bisects before scanning
+	data := testStream(packets)
+	d, err := ogg.NewDemuxer(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sample := range []int64{0, 1, 150, 250, 12345, 20050, 39999} {
+		at, err := d.SeekSample(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if at > sample {
+			t.Errorf("seek to %d landed after it, at %d", sample, at)
+		}
+		pkt, err := d.ReadPacket()
+		if err != nil {
+			t.Fatalf("seek to %d: %v", sample, err)
+		}
+		if len(pkt.Data) != packetLen {
+			t.Fatalf("seek to %d: first packet has %d bytes, want a whole one", sample, len(pkt.Data))
+		}
+		i := int64(binary.BigEndian.Uint32(pkt.Data))
+		if i*packetSamples != at {
+			t.Errorf("seek to %d returned granule %d, but the first packet delivered starts at %d", sample, at, i*packetSamples)
+		}
+	}
+
+	// Reading on from a seek delivers every following packet
+	if _, err = d.SeekSample(20050); err != nil {
+		t.Fatal(err)
+	}
+	want := uint32(200)
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := binary.BigEndian.Uint32(pkt.Data); got != want {
+			t.Fatalf("read packet %d, want %d", got, want)
+		}
+		want++
+	}
+	if want != packets {
+		t.Errorf("stopped at packet %d of %d", want, packets)
+	}
+}
+
+func TestResyncSkipsContinuedFragment(t *testing.T) {
+	data := testStream(50)
+
+	// Corrupt an audio page in the middle of the stream
+	corrupt := append([]byte(nil), data...)
+	off := int64(len(data) / 2)
+	p, err := ogg.FindPage(bytes.NewReader(data), off, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt[p.Offset+30]++
+	d, err := ogg.NewDemuxer(bytes.NewReader(corrupt), int64(len(corrupt)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pkt.Data) != packetLen {
+			t.Fatalf("delivered a %d byte fragment after resyncing", len(pkt.Data))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Package ogg handles Spotify's Ogg Vorbis audio files: the proprietary header
+// carrying loudness normalization data, and Ogg page/packet demuxing.
+package ogg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+const (
+	// SpotifyHeaderLen is the size of the proprietary header preceding the Ogg stream.
+	SpotifyHeaderLen = 0xa7
+
+	// NormalizationOffset is where the normalization values start within the header.
+	NormalizationOffset = 144
+)
+
+// CapturePattern starts every Ogg page.
+var CapturePattern = []byte("OggS")
+
+// Normalization holds the ReplayGain-style loudness data of a track.
+type Normalization struct {
+	TrackGainDB float32
+	TrackPeak   float32
+	AlbumGainDB float32
+	AlbumPeak   float32
+}
+
+// ParseNormalization reads the normalization values from a Spotify header.
+func ParseNormalization(header []byte) (Normalization, error) {
+	if len(header) < NormalizationOffset+16 {
+		return Normalization{}, errors.Errorf("ogg: spotify header too short (%d bytes)", len(header))
+	}
+	v := func(i int) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(header[NormalizationOffset+4*i:]))
+	}
+	return Normalization{
+		TrackGainDB: v(0),
+		TrackPeak:   v(1),
+		AlbumGainDB: v(2),
+		AlbumPeak:   v(3),
+	}, nil
+}
+
+// Factor returns the linear gain to apply to samples, using the album values if
+// album is set.  pregainDB is added to the gain, and the result is limited so the
+// peak does not clip.
+func (n Normalization) Factor(album bool, pregainDB float32) float32 {
+	gain, peak := n.TrackGainDB, n.TrackPeak
+	if album {
+		gain, peak = n.AlbumGainDB, n.AlbumPeak
+	}
+	factor := float32(math.Pow(10, float64(gain+pregainDB)/20))
+	if peak > 0 && factor*peak > 1 {
+		factor = 1 / peak
+	}
+	return factor
+}
+
+// ReaderOpts configures a Reader.
+type ReaderOpts struct {
+	// StripHeader removes the Spotify header so the output is a standard Ogg stream.
+	StripHeader bool
+}
+
+// Reader reads a decrypted Spotify audio file, parsing its header if present.
+type Reader struct {
+	// Normalization is the loudness data from the header (zero if HasHeader is false).
+	Normalization Normalization
+
+	// HasHeader is set if the file began with a Spotify header.
+	HasHeader bool
+
+	r io.Reader
+}
+
+// NewReader reads the start of r to detect and parse the Spotify header.  Files
+// without one (e.g. MP3 or already-stripped Ogg files) pass through unchanged.
+func NewReader(r io.Reader, opts ReaderOpts) (*Reader, error) {
+	br := bufio.NewReaderSize(r, SpotifyHeaderLen+len(CapturePattern))
+	peek, err := br.Peek(SpotifyHeaderLen + len(CapturePattern))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	rd := &Reader{r: br}
+	if len(peek) >= SpotifyHeaderLen+len(CapturePattern) &&
+		!bytes.HasPrefix(peek, CapturePattern) &&
+		bytes.HasPrefix(peek[SpotifyHeaderLen:], CapturePattern) {
+		rd.HasHeader = true
+		if rd.Normalization, err = ParseNormalization(peek[:SpotifyHeaderLen]); err != nil {
+			return nil, err
+		}
+		if opts.StripHeader {
+			br.Discard(SpotifyHeaderLen)
+		}
+	}
+	return rd, nil
+}
+
+// Read implements io.Reader.
+func (rd *Reader) Read(p []byte) (int, error) {
+	return rd.r.Read(p)
+}
+
+// StripSection returns a view of a seekable file without its Spotify header,
+// along with the parsed normalization data.  If the file has no header the
+// whole file is returned.
+func StripSection(r io.ReaderAt, size int64) (*io.SectionReader, Normalization, error) {
+	header := make([]byte, SpotifyHeaderLen+len(CapturePattern))
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, Normalization{}, err
+	}
+	header = header[:n]
+	if len(header) < SpotifyHeaderLen+len(CapturePattern) || !bytes.HasPrefix(header[SpotifyHeaderLen:], CapturePattern) {
+		return io.NewSectionReader(r, 0, size), Normalization{}, nil
+	}
+	norm, err := ParseNormalization(header)
+	if err != nil {
+		return nil, Normalization{}, err
+	}
+	return io.NewSectionReader(r, SpotifyHeaderLen, size-SpotifyHeaderLen), norm, nil
+}
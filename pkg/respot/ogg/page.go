@@ -0,0 +1,156 @@
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// Page header_type flags
+const (
+	FlagContinued = 0x01
+	FlagBOS       = 0x02
+	FlagEOS       = 0x04
+)
+
+const (
+	pageHeaderLen = 27
+	maxPageLen    = pageHeaderLen + 255 + 255*255
+)
+
+// ErrBadPage is returned for pages with a corrupt header or checksum.
+var ErrBadPage = errors.New("ogg: bad page")
+
+// Page is a single Ogg page.
+type Page struct {
+	Offset     int64 // position of the page in the stream
+	HeaderType byte
+	Granule    int64 // -1 if no packet ends on this page
+	Serial     uint32
+	Seq        uint32
+	Lacing     []byte // segment table
+	Data       []byte
+}
+
+// Len returns the size of the page including its header.
+func (p *Page) Len() int {
+	return pageHeaderLen + len(p.Lacing) + len(p.Data)
+}
+
+// Continued reports whether the page begins with the rest of a packet from the previous page.
+func (p *Page) Continued() bool {
+	return p.HeaderType&FlagContinued != 0
+}
+
+// ReadPageAt reads and verifies the page starting exactly at off.
+func ReadPageAt(r io.ReaderAt, off int64) (*Page, error) {
+	var hdr [pageHeaderLen]byte
+	if _, err := r.ReadAt(hdr[:], off); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if !bytes.Equal(hdr[:4], CapturePattern) || hdr[4] != 0 {
+		return nil, ErrBadPage
+	}
+	p := &Page{
+		Offset:     off,
+		HeaderType: hdr[5],
+		Granule:    int64(binary.LittleEndian.Uint64(hdr[6:])),
+		Serial:     binary.LittleEndian.Uint32(hdr[14:]),
+		Seq:        binary.LittleEndian.Uint32(hdr[18:]),
+		Lacing:     make([]byte, hdr[26]),
+	}
+	if _, err := r.ReadAt(p.Lacing, off+pageHeaderLen); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	dataLen := 0
+	for _, l := range p.Lacing {
+		dataLen += int(l)
+	}
+	p.Data = make([]byte, dataLen)
+	if _, err := r.ReadAt(p.Data, off+pageHeaderLen+int64(len(p.Lacing))); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	want := binary.LittleEndian.Uint32(hdr[22:])
+	binary.LittleEndian.PutUint32(hdr[22:], 0)
+	crc := crcUpdate(0, hdr[:])
+	crc = crcUpdate(crc, p.Lacing)
+	crc = crcUpdate(crc, p.Data)
+	if crc != want {
+		return nil, ErrBadPage
+	}
+	return p, nil
+}
+
+// Bytes serializes the page, computing its checksum.
+func (p *Page) Bytes() []byte {
+	buf := make([]byte, pageHeaderLen, p.Len())
+	copy(buf, CapturePattern)
+	buf[5] = p.HeaderType
+	binary.LittleEndian.PutUint64(buf[6:], uint64(p.Granule))
+	binary.LittleEndian.PutUint32(buf[14:], p.Serial)
+	binary.LittleEndian.PutUint32(buf[18:], p.Seq)
+	buf[26] = byte(len(p.Lacing))
+	buf = append(buf, p.Lacing...)
+	buf = append(buf, p.Data...)
+	binary.LittleEndian.PutUint32(buf[22:], crcUpdate(0, buf))
+	return buf
+}
+
+// FindPage returns the first valid page starting at or after off and before limit.
+func FindPage(r io.ReaderAt, off, limit int64) (*Page, error) {
+	buf := make([]byte, 64<<10)
+	for off < limit {
+		n, err := r.ReadAt(buf, off)
+		if n < len(CapturePattern) {
+			if err == nil || err == io.EOF {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		chunk := buf[:n]
+		for i := 0; i+len(CapturePattern) <= len(chunk) && off+int64(i) < limit; i++ {
+			j := bytes.Index(chunk[i:], CapturePattern)
+			if j < 0 {
+				break
+			}
+			i += j
+			if off+int64(i) >= limit {
+				break
+			}
+			if p, err := ReadPageAt(r, off+int64(i)); err == nil {
+				return p, nil
+			}
+		}
+		// Overlap so a pattern split across reads is not missed
+		off += int64(n - len(CapturePattern) + 1)
+	}
+	return nil, io.EOF
+}
+
+var crcTable = func() (table [256]uint32) {
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return
+}()
+
+func crcUpdate(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
@@ -0,0 +1,51 @@
+package ogg
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+)
+
+// Picture types from the FLAC / ID3v2 APIC specification
+const (
+	PictureOther      = 0
+	PictureFrontCover = 3
+)
+
+// Picture is an embedded image, stored in Vorbis comments as a base64 FLAC
+// METADATA_BLOCK_PICTURE.
+type Picture struct {
+	Type        uint32
+	MIME        string
+	Description string
+	Width       uint32
+	Height      uint32
+	Depth       uint32 // bits per pixel
+	Colors      uint32 // for indexed images, else 0
+	Data        []byte
+}
+
+// Block encodes the picture as a FLAC METADATA_BLOCK_PICTURE (without the metadata block header).
+func (p *Picture) Block() []byte {
+	buf := make([]byte, 0, 32+len(p.MIME)+len(p.Description)+len(p.Data))
+	var tmp [4]byte
+	u32 := func(v uint32) {
+		binary.BigEndian.PutUint32(tmp[:], v)
+		buf = append(buf, tmp[:]...)
+	}
+	u32(p.Type)
+	u32(uint32(len(p.MIME)))
+	buf = append(buf, p.MIME...)
+	u32(uint32(len(p.Description)))
+	buf = append(buf, p.Description...)
+	u32(p.Width)
+	u32(p.Height)
+	u32(p.Depth)
+	u32(p.Colors)
+	u32(uint32(len(p.Data)))
+	return append(buf, p.Data...)
+}
+
+// Comment returns the picture as a METADATA_BLOCK_PICTURE Vorbis comment.
+func (p *Picture) Comment() string {
+	return "METADATA_BLOCK_PICTURE=" + base64.StdEncoding.EncodeToString(p.Block())
+}
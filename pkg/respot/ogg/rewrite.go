@@ -0,0 +1,147 @@
+package ogg
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// WriteWithComments writes the Vorbis stream in r (size bytes long) to w as a
+// standard Ogg file with its comment header replaced by comments.  Any leading
+// Spotify header is dropped and pages are renumbered; audio data is copied as is.
+func WriteWithComments(w io.Writer, r io.ReaderAt, size int64, comments VorbisComments) error {
+	d, err := NewDemuxer(r, size)
+	if err != nil {
+		return err
+	}
+	if d.dataSeg != 0 {
+		return errors.New("ogg: audio data does not start on a fresh page")
+	}
+	if comments.Vendor == "" {
+		comments.Vendor = d.Headers.Comments.Vendor
+	}
+
+	bw := bufio.NewWriter(w)
+	seq := uint32(0)
+	emit := func(p *Page) error {
+		p.Serial = d.serial
+		p.Seq = seq
+		seq++
+		_, err := bw.Write(p.Bytes())
+		return err
+	}
+
+	// The identification header must be alone on the first page
+	pages := []*Page{{HeaderType: FlagBOS, Granule: 0, Lacing: lacing(len(d.Headers.Identification)), Data: d.Headers.Identification}}
+	pages = append(pages, paginate([][]byte{comments.Packet(), d.Headers.Setup})...)
+	for _, p := range pages {
+		if err = emit(p); err != nil {
+			return err
+		}
+	}
+
+	// After resynchronizing past a corrupt page, the first page found may
+	// continue a packet whose start was lost; that fragment is dropped.
+	orphaned := false
+	for off := d.dataPage; off < size; {
+		p, err := ReadPageAt(r, off)
+		if err == ErrBadPage {
+			if p, err = FindPage(r, off+1, size); err == nil {
+				orphaned = true
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		off = p.Offset + int64(p.Len())
+		if p.Serial != d.serial {
+			continue
+		}
+		if orphaned && p.Continued() {
+			orphaned = dropContinued(p)
+			if len(p.Lacing) == 0 {
+				continue
+			}
+		}
+		orphaned = false
+		if err = emit(p); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// dropContinued removes the fragment that p continues from the start of p.
+// It returns true if the fragment runs on past p, leaving nothing of it.
+func dropContinued(p *Page) bool {
+	n := 0
+	for seg, l := range p.Lacing {
+		n += int(l)
+		if l < 255 {
+			p.Lacing, p.Data = p.Lacing[seg+1:], p.Data[n:]
+			p.HeaderType &^= FlagContinued
+			if !endsPacket(p.Lacing) {
+				p.Granule = -1 // at most the start of a packet is left
+			}
+			return false
+		}
+	}
+	p.Lacing, p.Data = nil, nil
+	return true
+}
+
+// endsPacket reports whether any packet completes within lacing.
+func endsPacket(lacing []byte) bool {
+	for _, l := range lacing {
+		if l < 255 {
+			return true
+		}
+	}
+	return false
+}
+
+// lacing returns the segment table entries for a packet of n bytes.
+func lacing(n int) []byte {
+	l := make([]byte, 0, n/255+1)
+	for ; n >= 255; n -= 255 {
+		l = append(l, 255)
+	}
+	return append(l, byte(n))
+}
+
+// paginate lays header packets out over as few pages as possible.
+func paginate(packets [][]byte) []*Page {
+	var pages []*Page
+	page := &Page{Granule: -1}
+	continued := false
+	for _, packet := range packets {
+		segs := lacing(len(packet))
+		data := packet
+		for len(segs) > 0 {
+			if len(page.Lacing) == 255 {
+				pages = append(pages, page)
+				page = &Page{Granule: -1}
+				if continued {
+					page.HeaderType = FlagContinued
+				}
+			}
+			l := segs[0]
+			segs = segs[1:]
+			page.Lacing = append(page.Lacing, l)
+			page.Data = append(page.Data, data[:l]...)
+			data = data[l:]
+			continued = len(segs) > 0
+			if !continued {
+				page.Granule = 0 // header packets have granule position 0
+			}
+		}
+	}
+	if len(page.Lacing) > 0 {
+		pages = append(pages, page)
+	}
+	return pages
+}
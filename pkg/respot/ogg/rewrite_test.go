@@ -0,0 +1,134 @@
+package ogg_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/arcspace/go-librespot/pkg/respot/ogg"
+)
+
+// packets reads every audio packet of an Ogg Vorbis stream.
+func packets(t *testing.T, data []byte) (ogg.VorbisHeaders, [][]byte) {
+	t.Helper()
+	d, err := ogg.NewDemuxer(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out [][]byte
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			return d.Headers, out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, pkt.Data)
+	}
+}
+
+func rewrite(t *testing.T, data []byte, comments ogg.VorbisComments) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := ogg.WriteWithComments(&out, bytes.NewReader(data), int64(len(data)), comments); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestWriteWithComments(t *testing.T) {
+	data := testStream(50)
+	comments := ogg.VorbisComments{Comments: []string{"TITLE=Song", "ARTIST=A", "ARTIST=B"}}
+	out := rewrite(t, data, comments)
+
+	headers, got := packets(t, out)
+	if headers.Comments.Vendor != "test" {
+		t.Errorf("vendor %q, want the original kept", headers.Comments.Vendor)
+	}
+	if !reflect.DeepEqual(headers.Comments.Comments, comments.Comments) {
+		t.Errorf("comments %q, want %q", headers.Comments.Comments, comments.Comments)
+	}
+	_, want := packets(t, data)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rewrote %d audio packets, want the %d original ones", len(got), len(want))
+	}
+
+	// Pages are renumbered from 0, the first alone starting the stream
+	var serial uint32
+	seq := uint32(0)
+	for off := int64(0); off < int64(len(out)); seq++ {
+		p, err := ogg.ReadPageAt(bytes.NewReader(out), off)
+		if err != nil {
+			t.Fatalf("page %d: %v", seq, err)
+		}
+		if seq == 0 {
+			serial = p.Serial
+			if p.HeaderType&ogg.FlagBOS == 0 || len(p.Lacing) != 1 {
+				t.Error("identification header not alone on a BOS page")
+			}
+		}
+		if p.Seq != seq || p.Serial != serial {
+			t.Errorf("page %d numbered %d of stream %x", seq, p.Seq, p.Serial)
+		}
+		off = p.Offset + int64(p.Len())
+	}
+}
+
+func TestWriteWithCommentsDropsOrphanedFragment(t *testing.T) {
+	data := testStream(50)
+	p, err := ogg.FindPage(bytes.NewReader(data), int64(len(data)/2), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[p.Offset+30]++
+
+	// The output is clean: no packet is glued together from the fragments
+	// either side of the corrupt page
+	_, got := packets(t, rewrite(t, data, ogg.VorbisComments{}))
+	last := -1
+	for _, pkt := range got {
+		if len(pkt) != packetLen {
+			t.Fatalf("wrote a %d byte packet after resyncing", len(pkt))
+		}
+		i := int(binary.BigEndian.Uint32(pkt))
+		if i <= last {
+			t.Fatalf("packet %d follows %d", i, last)
+		}
+		last = i
+	}
+	if last != 49 || len(got) >= 50 {
+		t.Errorf("wrote %d packets ending with %d, want all but those lost", len(got), last)
+	}
+}
+
+func TestPictureBlock(t *testing.T) {
+	pic := &ogg.Picture{
+		Type:        ogg.PictureFrontCover,
+		MIME:        "image/png",
+		Description: "cover",
+		Width:       2,
+		Height:      3,
+		Depth:       24,
+		Data:        []byte{0xde, 0xad},
+	}
+	want := []byte{
+		0, 0, 0, 3,
+		0, 0, 0, 9, 'i', 'm', 'a', 'g', 'e', '/', 'p', 'n', 'g',
+		0, 0, 0, 5, 'c', 'o', 'v', 'e', 'r',
+		0, 0, 0, 2,
+		0, 0, 0, 3,
+		0, 0, 0, 24,
+		0, 0, 0, 0,
+		0, 0, 0, 2, 0xde, 0xad,
+	}
+	if got := pic.Block(); !bytes.Equal(got, want) {
+		t.Errorf("block\n%x, want\n%x", got, want)
+	}
+	if got := pic.Comment(); got != "METADATA_BLOCK_PICTURE="+base64.StdEncoding.EncodeToString(want) {
+		t.Errorf("comment %q", got)
+	}
+}
@@ -0,0 +1,141 @@
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// Vorbis header packet types
+const (
+	PacketIdentification = 1
+	PacketComment        = 3
+	PacketSetup          = 5
+)
+
+var vorbisMagic = []byte("vorbis")
+
+// VorbisHeaders holds the three header packets that start a Vorbis stream.
+type VorbisHeaders struct {
+	Identification []byte
+	Comment        []byte
+	Setup          []byte
+
+	Info     VorbisInfo
+	Comments VorbisComments
+}
+
+// VorbisInfo is the parsed identification header.
+type VorbisInfo struct {
+	Channels       int
+	SampleRate     int
+	BitrateMax     int32
+	BitrateNominal int32
+	BitrateMin     int32
+}
+
+// VorbisComments is the parsed comment header.
+type VorbisComments struct {
+	Vendor   string
+	Comments []string // "FIELD=value"
+}
+
+// Get returns the values of a field, matched case-insensitively.
+func (vc *VorbisComments) Get(field string) []string {
+	var values []string
+	for _, c := range vc.Comments {
+		if eq := strings.IndexByte(c, '='); eq > 0 && strings.EqualFold(c[:eq], field) {
+			values = append(values, c[eq+1:])
+		}
+	}
+	return values
+}
+
+func checkHeader(packet []byte, typ byte) error {
+	if len(packet) < 7 || packet[0] != typ || !bytes.Equal(packet[1:7], vorbisMagic) {
+		return errors.Errorf("ogg: expected vorbis header packet type %d", typ)
+	}
+	return nil
+}
+
+// ParseIdentification parses a Vorbis identification header packet.
+func ParseIdentification(packet []byte) (VorbisInfo, error) {
+	if err := checkHeader(packet, PacketIdentification); err != nil {
+		return VorbisInfo{}, err
+	}
+	if len(packet) < 30 {
+		return VorbisInfo{}, errors.New("ogg: short vorbis identification header")
+	}
+	info := VorbisInfo{
+		Channels:       int(packet[11]),
+		SampleRate:     int(binary.LittleEndian.Uint32(packet[12:])),
+		BitrateMax:     int32(binary.LittleEndian.Uint32(packet[16:])),
+		BitrateNominal: int32(binary.LittleEndian.Uint32(packet[20:])),
+		BitrateMin:     int32(binary.LittleEndian.Uint32(packet[24:])),
+	}
+	if info.Channels == 0 || info.SampleRate == 0 {
+		return VorbisInfo{}, errors.New("ogg: invalid vorbis identification header")
+	}
+	return info, nil
+}
+
+// ParseComments parses a Vorbis comment header packet.
+func ParseComments(packet []byte) (VorbisComments, error) {
+	if err := checkHeader(packet, PacketComment); err != nil {
+		return VorbisComments{}, err
+	}
+	buf := packet[7:]
+	next := func() (string, bool) {
+		if len(buf) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(buf)
+		if uint64(n) > uint64(len(buf)-4) {
+			return "", false
+		}
+		s := string(buf[4 : 4+n])
+		buf = buf[4+n:]
+		return s, true
+	}
+
+	var vc VorbisComments
+	var ok bool
+	if vc.Vendor, ok = next(); !ok || len(buf) < 4 {
+		return vc, errors.New("ogg: short vorbis comment header")
+	}
+	count := binary.LittleEndian.Uint32(buf)
+	buf = buf[4:]
+	for i := uint32(0); i < count; i++ {
+		c, ok := next()
+		if !ok {
+			return vc, errors.New("ogg: truncated vorbis comment")
+		}
+		vc.Comments = append(vc.Comments, c)
+	}
+	return vc, nil
+}
+
+// Add appends a FIELD=value comment; empty values are skipped.
+func (vc *VorbisComments) Add(field, value string) {
+	if value != "" {
+		vc.Comments = append(vc.Comments, field+"="+value)
+	}
+}
+
+// Packet encodes the comments as a Vorbis comment header packet.
+func (vc *VorbisComments) Packet() []byte {
+	le32 := func(buf []byte, v int) []byte {
+		return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+	buf := append([]byte{PacketComment}, vorbisMagic...)
+	buf = le32(buf, len(vc.Vendor))
+	buf = append(buf, vc.Vendor...)
+	buf = le32(buf, len(vc.Comments))
+	for _, c := range vc.Comments {
+		buf = le32(buf, len(c))
+		buf = append(buf, c...)
+	}
+	return append(buf, 1) // framing bit
+}
@@ -0,0 +1,38 @@
+package playlist
+
+import (
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/golang/protobuf/proto"
+)
+
+// URIPrefix starts the Mercury URIs of playlists, followed by the playlist path.
+const URIPrefix = "hm://playlist/"
+
+// NewFetcher returns a Fetcher over a Mercury client that can send requests
+// with a body, typically session.Mercury().  Each selection is sent, encoded,
+// as the body of a GET for "hm://playlist/<path>".
+func NewFetcher(sender catalog.Sender) Fetcher {
+	return &mercuryFetcher{sender}
+}
+
+type mercuryFetcher struct {
+	sender catalog.Sender
+}
+
+func (f *mercuryFetcher) GetPlaylistSelection(path string, sel *Spotify.ListContentSelection) (*Spotify.SelectedListContent, error) {
+	body, err := proto.Marshal(sel)
+	if err != nil {
+		return nil, err
+	}
+	uri := URIPrefix + path
+	if body, err = f.sender.Send("GET", uri, "", body); err != nil {
+		return nil, err
+	}
+	content := &Spotify.SelectedListContent{}
+	if err = proto.Unmarshal(body, content); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s reply", uri)
+	}
+	return content, nil
+}
@@ -0,0 +1,310 @@
+package playlist
+
+import (
+	"bytes"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Apply applies ops in order.  Either all of them apply or, on error, l is left unchanged.
+//
+// Checksums in an op describe the list before that op: list_checksum covers
+// every item's URI, uris_checksum and items_checksum the items removed or moved
+// (the latter including their attributes) and old_attributes_checksum the
+// attributes being replaced.  Like the checksum of fetched content they are
+// advisory (see ChecksumVersion): a mismatch sets ChecksumMismatch but doesn't
+// fail the op.  Ops still fail when the items or old attributes they name
+// don't match the list.
+func (l *List) Apply(ops ...*Spotify.Op) error {
+	next := l.Clone()
+	for i, op := range ops {
+		if err := next.apply(op); err != nil {
+			return errors.Wrapf(err, "op %d (%v)", i, op.GetKind())
+		}
+	}
+	*l = *next
+	return nil
+}
+
+func (l *List) apply(op *Spotify.Op) error {
+	switch op.GetKind() {
+	case Spotify.Op_ADD:
+		return l.add(op.GetAdd())
+	case Spotify.Op_REM:
+		return l.rem(op.GetRem())
+	case Spotify.Op_MOV:
+		return l.mov(op.GetMov())
+	case Spotify.Op_UPDATE_ITEM_ATTRIBUTES:
+		return l.updateItemAttributes(op.GetUpdateItemAttributes())
+	case Spotify.Op_UPDATE_LIST_ATTRIBUTES:
+		return l.updateListAttributes(op.GetUpdateListAttributes())
+	}
+	return errors.Wrapf(errors.ErrUnsupported, "playlist: op kind %v", op.GetKind())
+}
+
+func (l *List) add(add *Spotify.Add) error {
+	if add == nil {
+		return ErrConflict
+	}
+	l.note(add.GetListChecksum(), l.Items, itemsChecksum)
+	var at int
+	switch {
+	case add.GetAddFirst():
+		at = 0
+	case add.GetAddLast():
+		at = len(l.Items)
+	case add.FromIndex != nil:
+		at = int(add.GetFromIndex())
+	default:
+		return errors.Wrap(ErrConflict, "add without a position")
+	}
+	if at < 0 || at > len(l.Items) {
+		return errors.Wrapf(ErrConflict, "add at %d of %d items", at, len(l.Items))
+	}
+	added := cloneItems(add.GetItems())
+	items := make([]*Spotify.Item, 0, len(l.Items)+len(added))
+	items = append(items, l.Items[:at]...)
+	items = append(items, added...)
+	l.Items = append(items, l.Items[at:]...)
+	return nil
+}
+
+func (l *List) rem(rem *Spotify.Rem) error {
+	if rem == nil {
+		return ErrConflict
+	}
+	l.note(rem.GetListChecksum(), l.Items, itemsChecksum)
+	if rem.GetItemsAsKey() {
+		return l.remByKey(rem)
+	}
+
+	from := int(rem.GetFromIndex())
+	n := int(rem.GetLength())
+	if rem.Length == nil {
+		n = len(rem.GetItems())
+	}
+	if from < 0 || n < 0 || from+n > len(l.Items) {
+		return errors.Wrapf(ErrConflict, "remove %d at %d of %d items", n, from, len(l.Items))
+	}
+	removed := l.Items[from : from+n]
+	if err := l.verifyRange(removed, rem.GetItems(), rem.GetUrisChecksum(), rem.GetItemsChecksum()); err != nil {
+		return err
+	}
+	l.Items = append(l.Items[:from:from], l.Items[from+n:]...)
+	return nil
+}
+
+// remByKey removes the given items wherever they are, matching by URI.  An
+// item is looked for first where fromIndex says it should be.
+func (l *List) remByKey(rem *Spotify.Rem) error {
+	drop := make(map[int]bool, len(rem.GetItems()))
+	for i, want := range rem.GetItems() {
+		at := -1
+		if hint := int(rem.GetFromIndex()) + i; rem.FromIndex != nil && hint >= 0 && hint < len(l.Items) && !drop[hint] && l.Items[hint].GetUri() == want.GetUri() {
+			at = hint
+		} else {
+			for j, item := range l.Items {
+				if !drop[j] && item.GetUri() == want.GetUri() {
+					at = j
+					break
+				}
+			}
+		}
+		if at < 0 {
+			return errors.Wrapf(ErrConflict, "remove %s: not in the list", want.GetUri())
+		}
+		drop[at] = true
+	}
+	items := make([]*Spotify.Item, 0, len(l.Items)-len(drop))
+	var removed []*Spotify.Item
+	for i, item := range l.Items {
+		if drop[i] {
+			removed = append(removed, item)
+		} else {
+			items = append(items, item)
+		}
+	}
+	if err := l.verifyRange(removed, nil, rem.GetUrisChecksum(), rem.GetItemsChecksum()); err != nil {
+		return err
+	}
+	l.Items = items
+	return nil
+}
+
+// verifyRange checks the items an op acts on against the items it names, and
+// notes whether they match the checksums it carries.
+func (l *List) verifyRange(items, want []*Spotify.Item, uris, full *Spotify.ListChecksum) error {
+	if len(want) > 0 {
+		if len(want) != len(items) {
+			return errors.Wrapf(ErrConflict, "op names %d items but covers %d", len(want), len(items))
+		}
+		for i := range want {
+			if want[i].GetUri() != items[i].GetUri() {
+				return errors.Wrapf(ErrConflict, "expected %s, found %s", want[i].GetUri(), items[i].GetUri())
+			}
+		}
+	}
+	l.note(uris, items, itemsChecksum)
+	l.note(full, items, itemsAttributesChecksum)
+	return nil
+}
+
+// note sets ChecksumMismatch if sum doesn't match the items.
+func (l *List) note(sum *Spotify.ListChecksum, items []*Spotify.Item, hash func([]*Spotify.Item) []byte) {
+	if verify(sum, items, hash) != nil {
+		l.ChecksumMismatch = true
+	}
+}
+
+// noteAttributes sets ChecksumMismatch if sum doesn't match attrs.
+func (l *List) noteAttributes(sum *Spotify.ListChecksum, attrs proto.Message) {
+	if sum.GetVersion() == ChecksumVersion && len(sum.GetSha1()) > 0 && !bytes.Equal(sum.GetSha1(), messageChecksum(attrs)) {
+		l.ChecksumMismatch = true
+	}
+}
+
+// mov moves length items starting at fromIndex to before the item at toIndex,
+// where toIndex counts positions in the list before the move.
+func (l *List) mov(mov *Spotify.Mov) error {
+	if mov == nil {
+		return ErrConflict
+	}
+	l.note(mov.GetListChecksum(), l.Items, itemsChecksum)
+	from, n, to := int(mov.GetFromIndex()), int(mov.GetLength()), int(mov.GetToIndex())
+	if mov.Length == nil {
+		n = 1
+	}
+	if from < 0 || n < 0 || from+n > len(l.Items) || to < 0 || to > len(l.Items) {
+		return errors.Wrapf(ErrConflict, "move %d from %d to %d of %d items", n, from, to, len(l.Items))
+	}
+	if to > from && to < from+n {
+		return errors.Wrapf(ErrConflict, "move %d from %d into itself at %d", n, from, to)
+	}
+	moved := l.Items[from : from+n]
+	if err := l.verifyRange(moved, nil, mov.GetUrisChecksum(), mov.GetItemsChecksum()); err != nil {
+		return err
+	}
+	moved = append([]*Spotify.Item(nil), moved...)
+	rest := append(append([]*Spotify.Item(nil), l.Items[:from]...), l.Items[from+n:]...)
+	if to > from {
+		to -= n
+	}
+	items := make([]*Spotify.Item, 0, len(l.Items))
+	items = append(items, rest[:to]...)
+	items = append(items, moved...)
+	l.Items = append(items, rest[to:]...)
+	return nil
+}
+
+func (l *List) updateItemAttributes(up *Spotify.UpdateItemAttributes) error {
+	if up == nil {
+		return ErrConflict
+	}
+	l.note(up.GetListChecksum(), l.Items, itemsChecksum)
+	at := int(up.GetIndex())
+	if at < 0 || at >= len(l.Items) {
+		return errors.Wrapf(ErrConflict, "update item %d of %d", at, len(l.Items))
+	}
+	item := l.Items[at]
+	if item.Attributes == nil {
+		item.Attributes = &Spotify.ItemAttributes{}
+	}
+	l.noteAttributes(up.GetOldAttributesChecksum(), item.Attributes)
+	var noValue []protoreflect.FieldNumber
+	if old := up.GetOldAttributes(); old != nil {
+		for _, kind := range old.GetNoValue() {
+			noValue = append(noValue, protoreflect.FieldNumber(kind))
+		}
+		if err := check(item.Attributes, old.GetValues(), noValue); err != nil {
+			return err
+		}
+	}
+	noValue = noValue[:0]
+	for _, kind := range up.GetNewAttributes().GetNoValue() {
+		noValue = append(noValue, protoreflect.FieldNumber(kind))
+	}
+	update(item.Attributes, up.GetNewAttributes().GetValues(), noValue)
+	return nil
+}
+
+func (l *List) updateListAttributes(up *Spotify.UpdateListAttributes) error {
+	if up == nil {
+		return ErrConflict
+	}
+	l.note(up.GetListChecksum(), l.Items, itemsChecksum)
+	l.noteAttributes(up.GetOldAttributesChecksum(), l.Attributes)
+	var noValue []protoreflect.FieldNumber
+	if old := up.GetOldAttributes(); old != nil {
+		for _, kind := range old.GetNoValue() {
+			noValue = append(noValue, protoreflect.FieldNumber(kind))
+		}
+		if err := check(l.Attributes, old.GetValues(), noValue); err != nil {
+			return err
+		}
+	}
+	noValue = noValue[:0]
+	for _, kind := range up.GetNewAttributes().GetNoValue() {
+		noValue = append(noValue, protoreflect.FieldNumber(kind))
+	}
+	update(l.Attributes, up.GetNewAttributes().GetValues(), noValue)
+	return nil
+}
+
+// The attribute kinds of partial states (ItemAttributeKind, ListAttributeKind)
+// are the field numbers of the attributes they name, so both kinds of partial
+// state are handled by the reflection helpers below.
+
+// check verifies that cur holds the values set in old and lacks the fields in noValue.
+func check(cur, old proto.Message, noValue []protoreflect.FieldNumber) error {
+	c := proto.MessageReflect(cur)
+	var err error
+	if o := proto.MessageReflect(old); o.IsValid() {
+		o.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			if !c.Has(fd) || !valueEqual(fd, c.Get(fd), v) {
+				err = errors.Wrapf(ErrConflict, "attribute %s changed", fd.Name())
+				return false
+			}
+			return true
+		})
+	}
+	if err != nil {
+		return err
+	}
+	fields := c.Descriptor().Fields()
+	for _, num := range noValue {
+		if fd := fields.ByNumber(num); fd != nil && c.Has(fd) {
+			return errors.Wrapf(ErrConflict, "attribute %s is set", fd.Name())
+		}
+	}
+	return nil
+}
+
+// update sets the fields set in values and clears the fields in noValue.
+func update(cur, values proto.Message, noValue []protoreflect.FieldNumber) {
+	c := proto.MessageReflect(cur)
+	if v := proto.MessageReflect(values); v.IsValid() {
+		proto.MessageReflect(proto.Clone(values)).Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			c.Set(fd, v)
+			return true
+		})
+	}
+	fields := c.Descriptor().Fields()
+	for _, num := range noValue {
+		if fd := fields.ByNumber(num); fd != nil {
+			c.Clear(fd)
+		}
+	}
+}
+
+func valueEqual(fd protoreflect.FieldDescriptor, a, b protoreflect.Value) bool {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return proto.Equal(proto.MessageV1(a.Message().Interface()), proto.MessageV1(b.Message().Interface()))
+	case protoreflect.BytesKind:
+		return bytes.Equal(a.Bytes(), b.Bytes())
+	}
+	return a.Interface() == b.Interface()
+}
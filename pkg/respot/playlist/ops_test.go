@@ -0,0 +1,494 @@
+package playlist_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/playlist"
+	"github.com/golang/protobuf/proto"
+)
+
+// The model tracks two item attributes (added_by, seen) and two list
+// attributes (name, collaborative); a nil pointer is an unset attribute.
+type modelItem struct {
+	uri     string
+	addedBy *string
+	seen    *bool
+}
+
+// model is a naive playlist that ops are checked against.
+type model struct {
+	items         []modelItem
+	name          *string
+	collaborative *bool
+	mismatch      bool // a checksum didn't match
+}
+
+func items(uris []string) []*Spotify.Item {
+	out := make([]*Spotify.Item, len(uris))
+	for i, uri := range uris {
+		out[i] = &Spotify.Item{Uri: proto.String(uri)}
+	}
+	return out
+}
+
+func (m *model) uris() []string {
+	uris := make([]string, len(m.items))
+	for i, item := range m.items {
+		uris[i] = item.uri
+	}
+	return uris
+}
+
+// modelOf reads back the attributes the model tracks.
+func modelOf(l *playlist.List) *model {
+	m := &model{
+		name:          l.Attributes.Name,
+		collaborative: l.Attributes.Collaborative,
+		mismatch:      l.ChecksumMismatch,
+	}
+	for _, item := range l.Items {
+		mi := modelItem{uri: item.GetUri()}
+		if attrs := item.GetAttributes(); attrs != nil {
+			mi.addedBy, mi.seen = attrs.AddedBy, attrs.Seen
+		}
+		m.items = append(m.items, mi)
+	}
+	return m
+}
+
+func (m *model) clone() *model {
+	c := *m
+	c.items = append([]modelItem(nil), m.items...)
+	return &c
+}
+
+// apply returns the model after op, or false if op doesn't fit the model.
+func (m *model) apply(op *Spotify.Op) (*model, bool) {
+	next := m.clone()
+	if sum := listChecksum(op); sum != nil && !proto.Equal(sum, playlist.Checksum(items(m.uris()))) {
+		next.mismatch = true
+	}
+	var uris []string
+	for _, item := range m.items {
+		uris = append(uris, item.uri)
+	}
+
+	switch op.GetKind() {
+	case Spotify.Op_ADD:
+		add := op.GetAdd()
+		var at int
+		switch {
+		case add.GetAddFirst():
+			at = 0
+		case add.GetAddLast():
+			at = len(m.items)
+		default:
+			at = int(add.GetFromIndex())
+		}
+		if at < 0 || at > len(m.items) {
+			return nil, false
+		}
+		var added []modelItem
+		for _, item := range add.GetItems() {
+			added = append(added, modelItem{uri: item.GetUri()})
+		}
+		next.items = append(append(append([]modelItem(nil), m.items[:at]...), added...), m.items[at:]...)
+
+	case Spotify.Op_REM:
+		rem := op.GetRem()
+		if rem.GetItemsAsKey() {
+			gone := map[int]bool{}
+			for i, item := range rem.GetItems() {
+				at := -1
+				hint := int(rem.GetFromIndex()) + i
+				if hint >= 0 && hint < len(uris) && !gone[hint] && uris[hint] == item.GetUri() {
+					at = hint
+				}
+				for j := 0; at < 0 && j < len(uris); j++ {
+					if !gone[j] && uris[j] == item.GetUri() {
+						at = j
+					}
+				}
+				if at < 0 {
+					return nil, false
+				}
+				gone[at] = true
+			}
+			next.items = nil
+			for i, item := range m.items {
+				if !gone[i] {
+					next.items = append(next.items, item)
+				}
+			}
+			break
+		}
+		from, n := int(rem.GetFromIndex()), len(rem.GetItems())
+		if from < 0 || from+n > len(uris) {
+			return nil, false
+		}
+		for i, item := range rem.GetItems() {
+			if uris[from+i] != item.GetUri() {
+				return nil, false
+			}
+		}
+		next.items = append(append([]modelItem(nil), m.items[:from]...), m.items[from+n:]...)
+
+	case Spotify.Op_MOV:
+		mov := op.GetMov()
+		from, n, to := int(mov.GetFromIndex()), int(mov.GetLength()), int(mov.GetToIndex())
+		if from < 0 || n < 0 || from+n > len(uris) || to < 0 || to > len(uris) || (to > from && to < from+n) {
+			return nil, false
+		}
+		// Put the block back before the item that was at to
+		next.items = nil
+		for i := 0; i <= len(m.items); i++ {
+			if i == to {
+				next.items = append(next.items, m.items[from:from+n]...)
+			}
+			if i < len(m.items) && (i < from || i >= from+n) {
+				next.items = append(next.items, m.items[i])
+			}
+		}
+
+	case Spotify.Op_UPDATE_ITEM_ATTRIBUTES:
+		up := op.GetUpdateItemAttributes()
+		at := int(up.GetIndex())
+		if at < 0 || at >= len(m.items) {
+			return nil, false
+		}
+		if up.OldAttributesChecksum != nil {
+			next.mismatch = true // only ever sent wrong
+		}
+		item := &next.items[at]
+		if old := up.GetOldAttributes(); old != nil {
+			v := old.GetValues()
+			if v.AddedBy != nil && (item.addedBy == nil || *item.addedBy != *v.AddedBy) ||
+				v.Seen != nil && (item.seen == nil || *item.seen != *v.Seen) {
+				return nil, false
+			}
+			for _, kind := range old.GetNoValue() {
+				if kind == Spotify.ItemAttributesPartialState_ITEM_ADDED_BY && item.addedBy != nil ||
+					kind == Spotify.ItemAttributesPartialState_ITEM_SEEN && item.seen != nil {
+					return nil, false
+				}
+			}
+		}
+		if v := up.GetNewAttributes().GetValues(); v != nil {
+			if v.AddedBy != nil {
+				item.addedBy = proto.String(*v.AddedBy)
+			}
+			if v.Seen != nil {
+				item.seen = proto.Bool(*v.Seen)
+			}
+		}
+		for _, kind := range up.GetNewAttributes().GetNoValue() {
+			switch kind {
+			case Spotify.ItemAttributesPartialState_ITEM_ADDED_BY:
+				item.addedBy = nil
+			case Spotify.ItemAttributesPartialState_ITEM_SEEN:
+				item.seen = nil
+			}
+		}
+
+	case Spotify.Op_UPDATE_LIST_ATTRIBUTES:
+		up := op.GetUpdateListAttributes()
+		if up.OldAttributesChecksum != nil {
+			next.mismatch = true
+		}
+		if old := up.GetOldAttributes(); old != nil {
+			v := old.GetValues()
+			if v.Name != nil && (m.name == nil || *m.name != *v.Name) ||
+				v.Collaborative != nil && (m.collaborative == nil || *m.collaborative != *v.Collaborative) {
+				return nil, false
+			}
+			for _, kind := range old.GetNoValue() {
+				if kind == Spotify.ListAttributesPartialState_LIST_NAME && m.name != nil ||
+					kind == Spotify.ListAttributesPartialState_LIST_COLLABORATIVE && m.collaborative != nil {
+					return nil, false
+				}
+			}
+		}
+		if v := up.GetNewAttributes().GetValues(); v != nil {
+			if v.Name != nil {
+				next.name = proto.String(*v.Name)
+			}
+			if v.Collaborative != nil {
+				next.collaborative = proto.Bool(*v.Collaborative)
+			}
+		}
+		for _, kind := range up.GetNewAttributes().GetNoValue() {
+			switch kind {
+			case Spotify.ListAttributesPartialState_LIST_NAME:
+				next.name = nil
+			case Spotify.ListAttributesPartialState_LIST_COLLABORATIVE:
+				next.collaborative = nil
+			}
+		}
+
+	default:
+		return nil, false
+	}
+	return next, true
+}
+
+func listChecksum(op *Spotify.Op) *Spotify.ListChecksum {
+	switch op.GetKind() {
+	case Spotify.Op_ADD:
+		return op.GetAdd().GetListChecksum()
+	case Spotify.Op_REM:
+		return op.GetRem().GetListChecksum()
+	case Spotify.Op_MOV:
+		return op.GetMov().GetListChecksum()
+	case Spotify.Op_UPDATE_ITEM_ATTRIBUTES:
+		return op.GetUpdateItemAttributes().GetListChecksum()
+	case Spotify.Op_UPDATE_LIST_ATTRIBUTES:
+		return op.GetUpdateListAttributes().GetListChecksum()
+	}
+	return nil
+}
+
+// opGen makes ops against a model that are usually, but not always, valid.
+type opGen struct {
+	rng *rand.Rand
+}
+
+func (g opGen) uri() string {
+	return fmt.Sprintf("spotify:track:%d", g.rng.Intn(8))
+}
+
+func (g opGen) index(m *model) int32 {
+	return int32(g.rng.Intn(len(m.items)+5) - 2)
+}
+
+func (g opGen) some() []*Spotify.Item {
+	uris := make([]string, g.rng.Intn(3)+1)
+	for i := range uris {
+		uris[i] = g.uri()
+	}
+	return items(uris)
+}
+
+// maybe returns a random value or nil.
+func (g opGen) str() *string {
+	if g.rng.Intn(2) == 0 {
+		return nil
+	}
+	return proto.String(fmt.Sprint("v", g.rng.Intn(3)))
+}
+
+func (g opGen) flag() *bool {
+	if g.rng.Intn(2) == 0 {
+		return nil
+	}
+	return proto.Bool(g.rng.Intn(2) == 0)
+}
+
+// itemState returns a partial state: the item's actual attributes most of the
+// time (for old_attributes), otherwise random ones.
+func (g opGen) itemState(cur *modelItem) *Spotify.ItemAttributesPartialState {
+	st := &Spotify.ItemAttributesPartialState{Values: &Spotify.ItemAttributes{}}
+	addedBy, seen := g.str(), g.flag()
+	if cur != nil && g.rng.Intn(4) > 0 {
+		addedBy, seen = cur.addedBy, cur.seen
+	}
+	if addedBy != nil {
+		st.Values.AddedBy = addedBy
+	} else if g.rng.Intn(2) == 0 {
+		st.NoValue = append(st.NoValue, Spotify.ItemAttributesPartialState_ITEM_ADDED_BY)
+	}
+	if seen != nil {
+		st.Values.Seen = seen
+	} else if g.rng.Intn(2) == 0 {
+		st.NoValue = append(st.NoValue, Spotify.ItemAttributesPartialState_ITEM_SEEN)
+	}
+	return st
+}
+
+func (g opGen) listState(cur *model) *Spotify.ListAttributesPartialState {
+	st := &Spotify.ListAttributesPartialState{Values: &Spotify.ListAttributes{}}
+	name, collaborative := g.str(), g.flag()
+	if cur != nil && g.rng.Intn(4) > 0 {
+		name, collaborative = cur.name, cur.collaborative
+	}
+	if name != nil {
+		st.Values.Name = name
+	} else if g.rng.Intn(2) == 0 {
+		st.NoValue = append(st.NoValue, Spotify.ListAttributesPartialState_LIST_NAME)
+	}
+	if collaborative != nil {
+		st.Values.Collaborative = collaborative
+	} else if g.rng.Intn(2) == 0 {
+		st.NoValue = append(st.NoValue, Spotify.ListAttributesPartialState_LIST_COLLABORATIVE)
+	}
+	return st
+}
+
+// wrongAttributesChecksum is never the checksum of any attributes.
+var wrongAttributesChecksum = &Spotify.ListChecksum{Version: proto.Int32(playlist.ChecksumVersion), Sha1: make([]byte, 20)}
+
+func (g opGen) op(m *model) *Spotify.Op {
+	rng := g.rng
+	var op *Spotify.Op
+	switch rng.Intn(6) {
+	case 0:
+		add := &Spotify.Add{Items: g.some()}
+		switch rng.Intn(3) {
+		case 0:
+			add.AddFirst = proto.Bool(true)
+		case 1:
+			add.AddLast = proto.Bool(true)
+		default:
+			add.FromIndex = proto.Int32(g.index(m))
+		}
+		op = &Spotify.Op{Kind: Spotify.Op_ADD.Enum(), Add: add}
+	case 1:
+		// Remove a range, naming its items most of the time correctly
+		from, n := int(g.index(m)), rng.Intn(3)+1
+		rem := &Spotify.Rem{FromIndex: proto.Int32(int32(from))}
+		if from >= 0 && from+n <= len(m.items) && rng.Intn(4) > 0 {
+			rem.Items = items(m.uris()[from : from+n])
+		} else {
+			rem.Items = g.some()
+		}
+		op = &Spotify.Op{Kind: Spotify.Op_REM.Enum(), Rem: rem}
+	case 2:
+		rem := &Spotify.Rem{Items: g.some(), ItemsAsKey: proto.Bool(true)}
+		if rng.Intn(2) == 0 {
+			rem.FromIndex = proto.Int32(g.index(m))
+		}
+		op = &Spotify.Op{Kind: Spotify.Op_REM.Enum(), Rem: rem}
+	case 3:
+		op = &Spotify.Op{Kind: Spotify.Op_MOV.Enum(), Mov: &Spotify.Mov{
+			FromIndex: proto.Int32(g.index(m)),
+			Length:    proto.Int32(int32(rng.Intn(3))),
+			ToIndex:   proto.Int32(g.index(m)),
+		}}
+	case 4:
+		at := g.index(m)
+		up := &Spotify.UpdateItemAttributes{Index: proto.Int32(at), NewAttributes: g.itemState(nil)}
+		if rng.Intn(2) == 0 {
+			var cur *modelItem
+			if at >= 0 && int(at) < len(m.items) {
+				cur = &m.items[at]
+			}
+			up.OldAttributes = g.itemState(cur)
+		}
+		if rng.Intn(5) == 0 {
+			up.OldAttributesChecksum = wrongAttributesChecksum
+		}
+		op = &Spotify.Op{Kind: Spotify.Op_UPDATE_ITEM_ATTRIBUTES.Enum(), UpdateItemAttributes: up}
+	default:
+		up := &Spotify.UpdateListAttributes{NewAttributes: g.listState(nil)}
+		if rng.Intn(2) == 0 {
+			up.OldAttributes = g.listState(m)
+		}
+		if rng.Intn(5) == 0 {
+			up.OldAttributesChecksum = wrongAttributesChecksum
+		}
+		op = &Spotify.Op{Kind: Spotify.Op_UPDATE_LIST_ATTRIBUTES.Enum(), UpdateListAttributes: up}
+	}
+
+	// Sometimes state the list checksum, rightly or wrongly
+	var sum *Spotify.ListChecksum
+	switch rng.Intn(6) {
+	case 0:
+		sum = playlist.Checksum(items(m.uris()))
+	case 1:
+		sum = playlist.Checksum(items(append([]string{g.uri()}, m.uris()...)))
+	}
+	switch {
+	case op.Add != nil:
+		op.Add.ListChecksum = sum
+	case op.Rem != nil:
+		op.Rem.ListChecksum = sum
+	case op.Mov != nil:
+		op.Mov.ListChecksum = sum
+	case op.UpdateItemAttributes != nil:
+		op.UpdateItemAttributes.ListChecksum = sum
+	case op.UpdateListAttributes != nil:
+		op.UpdateListAttributes.ListChecksum = sum
+	}
+	return op
+}
+
+// checkAgainstModel applies a random batch of ops to a random list and to the
+// model: either both accept every op and end in the same state, or the list
+// rejects the batch and is left as it was.  Checksum mismatches never reject
+// an op; they only set ChecksumMismatch.
+func checkAgainstModel(t *testing.T, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	g := opGen{rng}
+	l := &playlist.List{Attributes: &Spotify.ListAttributes{Name: g.str()}}
+	for i := rng.Intn(8); i > 0; i-- {
+		l.Items = append(l.Items, &Spotify.Item{
+			Uri:        proto.String(g.uri()),
+			Attributes: &Spotify.ItemAttributes{AddedBy: g.str(), Seen: g.flag()},
+		})
+	}
+	start := modelOf(l)
+
+	want, ok := start, true
+	var ops []*Spotify.Op
+	for i := rng.Intn(4) + 1; i > 0; i-- {
+		op := g.op(want)
+		ops = append(ops, op)
+		if ok {
+			if next, fits := want.apply(op); fits {
+				want = next
+			} else {
+				ok = false
+			}
+		}
+	}
+
+	err := l.Apply(ops...)
+	if !ok {
+		want = start
+	}
+	if (err == nil) != ok {
+		t.Fatalf("seed %d: Apply returned %v, model accepted: %v\nops: %v", seed, err, ok, ops)
+	}
+	if got := modelOf(l); !reflect.DeepEqual(got, want) {
+		t.Fatalf("seed %d: list is %+v, model %+v\nops: %v", seed, got, want, ops)
+	}
+}
+
+func TestApplyMatchesModel(t *testing.T) {
+	for seed := int64(0); seed < 5000; seed++ {
+		checkAgainstModel(t, seed)
+	}
+}
+
+func FuzzApply(f *testing.F) {
+	for seed := int64(0); seed < 16; seed++ {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(seed))
+		f.Add(b[:])
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var b [8]byte
+		copy(b[:], data)
+		checkAgainstModel(t, int64(binary.LittleEndian.Uint64(b[:])))
+	})
+}
+
+func TestApplyChecksumIsAdvisory(t *testing.T) {
+	l := &playlist.List{Items: items([]string{"spotify:track:1"})}
+	wrong := playlist.Checksum(items([]string{"spotify:track:2"}))
+	err := l.Apply(&Spotify.Op{Kind: Spotify.Op_ADD.Enum(), Add: &Spotify.Add{
+		AddLast:      proto.Bool(true),
+		Items:        items([]string{"spotify:track:3"}),
+		ListChecksum: wrong,
+	}})
+	if err != nil {
+		t.Fatalf("a list checksum mismatch failed the op: %v", err)
+	}
+	if !l.ChecksumMismatch || len(l.Items) != 2 {
+		t.Errorf("mismatch %v, %d items", l.ChecksumMismatch, len(l.Items))
+	}
+}
@@ -0,0 +1,162 @@
+// Package playlist keeps playlists in memory and applies playlist4 ops
+// (Spotify.Op) to them, so that changes can be followed incrementally instead
+// of fetching whole playlists again.
+package playlist
+
+import (
+	"bytes"
+	"crypto/sha1"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/golang/protobuf/proto"
+)
+
+// ChecksumVersion is the ListChecksum version computed and verified here: the
+// SHA-1 of the checksummed content.  Checksums of other versions are not verified.
+//
+// The layout hashed (see Checksum) is this package's reading of playlist4 and
+// has not been confirmed against the service.  Checksums are therefore only
+// advisory: a mismatch in fetched content, in an op or after a diff sets
+// List.ChecksumMismatch and nothing else.
+const ChecksumVersion = 1
+
+var (
+	ErrChecksum  = errors.New("playlist: checksum mismatch")
+	ErrConflict  = errors.New("playlist: op does not match the list")
+	ErrTruncated = errors.New("playlist: contents are incomplete")
+)
+
+// List is a playlist's attributes and items at a revision.
+type List struct {
+	Revision   []byte
+	Attributes *Spotify.ListAttributes
+	Items      []*Spotify.Item
+
+	// ChecksumMismatch is set if a checksum seen since the list was fetched
+	// in full didn't match (see ChecksumVersion).
+	ChecksumMismatch bool
+}
+
+// FromContent copies a fetched playlist.  The contents must be complete, since
+// ops address items by index.  A checksum mismatch is not an error; it is
+// reported in List.ChecksumMismatch.
+func FromContent(content *Spotify.SelectedListContent) (*List, error) {
+	items := content.GetContents()
+	if items.GetPos() != 0 || items.GetTruncated() {
+		return nil, ErrTruncated
+	}
+	if content.Length != nil && int(content.GetLength()) != len(items.GetItems()) {
+		return nil, errors.Wrapf(ErrTruncated, "got %d of %d items", len(items.GetItems()), content.GetLength())
+	}
+	l := &List{
+		Revision:   append([]byte(nil), content.GetRevision()...),
+		Attributes: cloneAttributes(content.GetAttributes()),
+		Items:      cloneItems(items.GetItems()),
+	}
+	l.ChecksumMismatch = verify(content.GetChecksum(), l.Items, itemsChecksum) != nil
+	return l, nil
+}
+
+// Content returns the list in the form GetPlaylist returns it.
+func (l *List) Content() *Spotify.SelectedListContent {
+	return &Spotify.SelectedListContent{
+		Revision:   append([]byte(nil), l.Revision...),
+		Length:     proto.Int32(int32(len(l.Items))),
+		Attributes: cloneAttributes(l.Attributes),
+		Checksum:   Checksum(l.Items),
+		Contents: &Spotify.ListItems{
+			Pos:       proto.Int32(0),
+			Truncated: proto.Bool(false),
+			Items:     cloneItems(l.Items),
+		},
+	}
+}
+
+// Clone returns a deep copy of l.
+func (l *List) Clone() *List {
+	return &List{
+		Revision:         append([]byte(nil), l.Revision...),
+		Attributes:       cloneAttributes(l.Attributes),
+		Items:            cloneItems(l.Items),
+		ChecksumMismatch: l.ChecksumMismatch,
+	}
+}
+
+// URIs returns the URIs of the items in order.
+func (l *List) URIs() []string {
+	uris := make([]string, len(l.Items))
+	for i, item := range l.Items {
+		uris[i] = item.GetUri()
+	}
+	return uris
+}
+
+func cloneAttributes(attrs *Spotify.ListAttributes) *Spotify.ListAttributes {
+	if attrs == nil {
+		return &Spotify.ListAttributes{}
+	}
+	return proto.Clone(attrs).(*Spotify.ListAttributes)
+}
+
+func cloneItems(items []*Spotify.Item) []*Spotify.Item {
+	out := make([]*Spotify.Item, len(items))
+	for i, item := range items {
+		out[i] = proto.Clone(item).(*Spotify.Item)
+	}
+	return out
+}
+
+// Checksum returns the checksum of a list's items, which covers their URIs in order.
+func Checksum(items []*Spotify.Item) *Spotify.ListChecksum {
+	return &Spotify.ListChecksum{
+		Version: proto.Int32(ChecksumVersion),
+		Sha1:    itemsChecksum(items),
+	}
+}
+
+// itemsChecksum hashes each item's URI followed by a newline.
+func itemsChecksum(items []*Spotify.Item) []byte {
+	h := sha1.New()
+	for _, item := range items {
+		h.Write([]byte(item.GetUri()))
+		h.Write([]byte{'\n'})
+	}
+	return h.Sum(nil)
+}
+
+// itemsAttributesChecksum also covers the items' attributes.
+func itemsAttributesChecksum(items []*Spotify.Item) []byte {
+	h := sha1.New()
+	for _, item := range items {
+		h.Write([]byte(item.GetUri()))
+		h.Write([]byte{'\n'})
+		h.Write(marshal(item.GetAttributes()))
+	}
+	return h.Sum(nil)
+}
+
+func messageChecksum(m proto.Message) []byte {
+	sum := sha1.Sum(marshal(m))
+	return sum[:]
+}
+
+func marshal(m proto.Message) []byte {
+	b := proto.NewBuffer(nil)
+	b.SetDeterministic(true)
+	if err := b.Marshal(m); err != nil {
+		return nil
+	}
+	return b.Bytes()
+}
+
+// verify checks a checksum of the given version against the sum of items, if present.
+func verify(sum *Spotify.ListChecksum, items []*Spotify.Item, hash func([]*Spotify.Item) []byte) error {
+	if sum.GetVersion() != ChecksumVersion || len(sum.GetSha1()) == 0 {
+		return nil
+	}
+	if !bytes.Equal(sum.GetSha1(), hash(items)) {
+		return ErrChecksum
+	}
+	return nil
+}
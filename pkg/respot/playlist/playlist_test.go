@@ -0,0 +1,32 @@
+package playlist_test
+
+import (
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/playlist"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestFromContentChecksumIsAdvisory(t *testing.T) {
+	uris := []string{"spotify:track:1", "spotify:track:2"}
+	content := &Spotify.SelectedListContent{
+		Revision: []byte{1},
+		Length:   proto.Int32(2),
+		Checksum: playlist.Checksum(items(uris)),
+		Contents: &Spotify.ListItems{Pos: proto.Int32(0), Items: items(uris)},
+	}
+	l, err := playlist.FromContent(content)
+	if err != nil || l.ChecksumMismatch {
+		t.Fatalf("matching checksum: err %v, mismatch %v", err, l.ChecksumMismatch)
+	}
+
+	content.Checksum = playlist.Checksum(items(uris[:1]))
+	l, err = playlist.FromContent(content)
+	if err != nil {
+		t.Fatalf("a checksum mismatch failed the list: %v", err)
+	}
+	if !l.ChecksumMismatch || len(l.Items) != 2 || !l.Clone().ChecksumMismatch {
+		t.Errorf("mismatch %v, %d items", l.ChecksumMismatch, len(l.Items))
+	}
+}
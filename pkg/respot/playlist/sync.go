@@ -0,0 +1,130 @@
+package playlist
+
+import (
+	"bytes"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/golang/protobuf/proto"
+)
+
+// Fetcher fetches part of a playlist as described by a content selection.  It
+// takes a playlist path, as Mercury().GetPlaylist() does; NewFetcher returns
+// one that sends a Mercury request for "hm://playlist/<path>" carrying the
+// encoded selection.
+type Fetcher interface {
+	GetPlaylistSelection(path string, sel *Spotify.ListContentSelection) (*Spotify.SelectedListContent, error)
+}
+
+// PageSize is how many items a full fetch asks for at a time.
+const PageSize = 1000
+
+// SyncResult says how SyncPlaylist brought a playlist up to date.
+type SyncResult struct {
+	List     *List
+	UpToDate bool // nothing changed since the local revision
+	Full     bool // the whole playlist was fetched
+	Ops      int  // ops applied from the diff
+}
+
+// SyncPlaylist brings a local copy of a playlist up to date.  local may be nil,
+// in which case the playlist is fetched in full; otherwise only the ops since
+// local.Revision are fetched and applied to a copy of local.  A full fetch is
+// used instead when the server reports multiple heads, sends no usable diff or
+// the diff does not apply cleanly.  A checksum that doesn't match after the
+// diff is applied only sets List.ChecksumMismatch, as in Apply.  local itself
+// is never modified.
+func SyncPlaylist(src Fetcher, id catalog.ID, local *List) (*SyncResult, error) {
+	if local == nil || len(local.Revision) == 0 {
+		return fetchFull(src, id)
+	}
+	content, err := src.GetPlaylistSelection(id.PlaylistPath(), &Spotify.ListContentSelection{
+		WantRevision:          proto.Bool(true),
+		WantChecksum:          proto.Bool(true),
+		WantDiff:              proto.Bool(true),
+		BaseRevision:          local.Revision,
+		WantNothingIfUpToDate: proto.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content.GetUpToDate() || (content.Revision != nil && bytes.Equal(content.GetRevision(), local.Revision)) {
+		return &SyncResult{List: local, UpToDate: true}, nil
+	}
+	diff := content.GetDiff()
+	if content.GetMultipleHeads() || diff == nil || !bytes.Equal(diff.GetFromRevision(), local.Revision) {
+		return fetchFull(src, id)
+	}
+
+	list := local.Clone()
+	if err = list.Apply(diff.GetOps()...); err != nil {
+		return fetchFull(src, id)
+	}
+	list.note(content.GetChecksum(), list.Items, itemsChecksum)
+	list.Revision = diff.GetToRevision()
+	if len(list.Revision) == 0 {
+		list.Revision = content.GetRevision()
+	}
+	list.Revision = append([]byte(nil), list.Revision...)
+	return &SyncResult{List: list, Ops: len(diff.GetOps())}, nil
+}
+
+// fetchFull fetches a whole playlist, a page at a time if it is long.  Paging
+// starts over if the playlist changes in the meantime.
+func fetchFull(src Fetcher, id catalog.ID) (*SyncResult, error) {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		var content *Spotify.SelectedListContent
+		if content, err = fetchPages(src, id.PlaylistPath()); err == nil {
+			list, err := FromContent(content)
+			if err != nil {
+				return nil, err
+			}
+			return &SyncResult{List: list, Full: true}, nil
+		}
+		if err != errRevisionChanged {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+var errRevisionChanged = errors.New("playlist: changed while being fetched")
+
+func fetchPages(src Fetcher, path string) (*Spotify.SelectedListContent, error) {
+	sel := &Spotify.ListContentSelection{
+		WantRevision:   proto.Bool(true),
+		WantLength:     proto.Bool(true),
+		WantAttributes: proto.Bool(true),
+		WantChecksum:   proto.Bool(true),
+		WantContent:    proto.Bool(true),
+		ContentRange:   &Spotify.ContentRange{Pos: proto.Int32(0), Length: proto.Int32(PageSize)},
+	}
+	content, err := src.GetPlaylistSelection(path, sel)
+	if err != nil {
+		return nil, err
+	}
+	if content.Contents == nil {
+		content.Contents = &Spotify.ListItems{}
+	}
+	items := content.Contents
+	for len(items.Items) < int(content.GetLength()) || items.GetTruncated() {
+		sel.ContentRange.Pos = proto.Int32(int32(len(items.Items)))
+		page, err := src.GetPlaylistSelection(path, sel)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(page.GetRevision(), content.GetRevision()) {
+			return nil, errRevisionChanged
+		}
+		got := page.GetContents()
+		if len(got.GetItems()) == 0 || (got.Pos != nil && int(got.GetPos()) != len(items.Items)) {
+			return nil, ErrTruncated
+		}
+		items.Items = append(items.Items, got.GetItems()...)
+		items.Truncated = got.Truncated
+	}
+	items.Truncated = nil
+	return content, nil
+}
@@ -0,0 +1,188 @@
+package playlist_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/arcspace/go-librespot/pkg/respot/playlist"
+	"github.com/golang/protobuf/proto"
+)
+
+// fakePlaylist serves a playlist at its current revision over mercurytest,
+// with a scripted diff from one earlier revision.
+type fakePlaylist struct {
+	rev  []byte
+	uris []string
+	base []byte                // revision the diff starts from
+	ops  []*Spotify.Op         // the diff from base to rev
+	sum  *Spotify.ListChecksum // sent instead of the checksum of uris if set
+	sels []*Spotify.ListContentSelection
+}
+
+func (p *fakePlaylist) serve(srv *mercurytest.Server, id catalog.ID) {
+	srv.HandleRequest(playlist.URIPrefix+id.PlaylistPath(), func(req *mercurytest.Request) mercurytest.Response {
+		sel := &Spotify.ListContentSelection{}
+		if err := proto.Unmarshal(req.Body, sel); err != nil {
+			return mercurytest.Response{Status: 400}
+		}
+		p.sels = append(p.sels, sel)
+		content := &Spotify.SelectedListContent{
+			Revision: p.rev,
+			Length:   proto.Int32(int32(len(p.uris))),
+			Checksum: playlist.Checksum(items(p.uris)),
+		}
+		if p.sum != nil {
+			content.Checksum = p.sum
+		}
+		switch {
+		case sel.BaseRevision != nil && bytes.Equal(sel.BaseRevision, p.rev):
+			return mercurytest.Response{Payload: &Spotify.SelectedListContent{UpToDate: proto.Bool(true)}}
+		case sel.BaseRevision != nil && bytes.Equal(sel.BaseRevision, p.base):
+			content.Diff = &Spotify.Diff{FromRevision: p.base, ToRevision: p.rev, Ops: p.ops}
+		case sel.GetWantContent():
+			pos, n := int(sel.GetContentRange().GetPos()), int(sel.GetContentRange().GetLength())
+			end := pos + n
+			if end > len(p.uris) {
+				end = len(p.uris)
+			}
+			content.Contents = &Spotify.ListItems{
+				Pos:       proto.Int32(int32(pos)),
+				Truncated: proto.Bool(end < len(p.uris)),
+				Items:     items(p.uris[pos:end]),
+			}
+		}
+		return mercurytest.Response{Payload: content}
+	})
+}
+
+func testPlaylist(t *testing.T, p *fakePlaylist) (playlist.Fetcher, catalog.ID) {
+	t.Helper()
+	id, err := catalog.ParseID("spotify:user:bob:playlist:37i9dQZF1DXcBWIGoYBM5M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := mercurytest.New()
+	p.serve(srv, id)
+	return playlist.NewFetcher(srv), id
+}
+
+func trackURIs(n int) []string {
+	uris := make([]string, n)
+	for i := range uris {
+		uris[i] = fmt.Sprintf("spotify:track:%d", i)
+	}
+	return uris
+}
+
+func TestSyncAppliesDiff(t *testing.T) {
+	t0, t1, t2, t3 := "spotify:track:0", "spotify:track:1", "spotify:track:2", "spotify:track:3"
+	local := &playlist.List{Revision: []byte{1}, Items: items([]string{t0, t1, t2})}
+	p := &fakePlaylist{
+		rev:  []byte{2},
+		uris: []string{t2, t0, t3},
+		base: []byte{1},
+		ops: []*Spotify.Op{
+			{Kind: Spotify.Op_REM.Enum(), Rem: &Spotify.Rem{FromIndex: proto.Int32(1), Items: items([]string{t1})}},
+			{Kind: Spotify.Op_ADD.Enum(), Add: &Spotify.Add{AddLast: proto.Bool(true), Items: items([]string{t3})}},
+			{Kind: Spotify.Op_MOV.Enum(), Mov: &Spotify.Mov{FromIndex: proto.Int32(1), Length: proto.Int32(1), ToIndex: proto.Int32(0)}},
+		},
+	}
+	src, id := testPlaylist(t, p)
+
+	res, err := playlist.SyncPlaylist(src, id, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Full || res.Ops != 3 || !bytes.Equal(res.List.Revision, p.rev) {
+		t.Errorf("got full %v, %d ops, revision %x", res.Full, res.Ops, res.List.Revision)
+	}
+	if got := res.List.URIs(); !reflect.DeepEqual(got, p.uris) {
+		t.Errorf("synced to %q, want %q", got, p.uris)
+	}
+	if len(p.sels) != 1 || !p.sels[0].GetWantDiff() || !bytes.Equal(p.sels[0].BaseRevision, local.Revision) {
+		t.Errorf("sent %v", p.sels)
+	}
+	if len(local.Items) != 3 {
+		t.Error("modified the local copy")
+	}
+
+	// Syncing again finds it up to date
+	res, err = playlist.SyncPlaylist(src, id, res.List)
+	if err != nil || !res.UpToDate {
+		t.Errorf("second sync: %+v, err %v", res, err)
+	}
+}
+
+func TestSyncKeepsDiffOnChecksumMismatch(t *testing.T) {
+	local := &playlist.List{Revision: []byte{1}, Items: items(trackURIs(2))}
+	p := &fakePlaylist{
+		rev:  []byte{2},
+		uris: trackURIs(3),
+		base: []byte{1},
+		ops: []*Spotify.Op{
+			{Kind: Spotify.Op_ADD.Enum(), Add: &Spotify.Add{AddLast: proto.Bool(true), Items: items(trackURIs(3)[2:])}},
+		},
+		sum: playlist.Checksum(items(trackURIs(4))),
+	}
+	src, id := testPlaylist(t, p)
+
+	res, err := playlist.SyncPlaylist(src, id, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Full || res.Ops != 1 || len(p.sels) != 1 {
+		t.Errorf("got full %v, %d ops after %d requests", res.Full, res.Ops, len(p.sels))
+	}
+	if !res.List.ChecksumMismatch {
+		t.Error("checksum mismatch not noted")
+	}
+	if got := res.List.URIs(); !reflect.DeepEqual(got, p.uris) {
+		t.Errorf("synced to %q, want %q", got, p.uris)
+	}
+}
+
+func TestSyncFallsBackOnConflict(t *testing.T) {
+	local := &playlist.List{Revision: []byte{1}, Items: items(trackURIs(2))}
+	p := &fakePlaylist{
+		rev:  []byte{2},
+		uris: trackURIs(playlist.PageSize + 500),
+		base: []byte{1},
+		ops: []*Spotify.Op{
+			// Names an item the local copy doesn't have there
+			{Kind: Spotify.Op_REM.Enum(), Rem: &Spotify.Rem{FromIndex: proto.Int32(0), Items: items([]string{"spotify:track:x"})}},
+		},
+	}
+	src, id := testPlaylist(t, p)
+
+	res, err := playlist.SyncPlaylist(src, id, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Full || res.Ops != 0 || res.List.ChecksumMismatch {
+		t.Errorf("got full %v, %d ops, checksum mismatch %v", res.Full, res.Ops, res.List.ChecksumMismatch)
+	}
+	if got := res.List.URIs(); !reflect.DeepEqual(got, p.uris) {
+		t.Errorf("fetched %d items, want %d", len(got), len(p.uris))
+	}
+	// The diff, then two pages
+	if len(p.sels) != 3 || p.sels[2].GetContentRange().GetPos() != playlist.PageSize {
+		t.Errorf("sent %d selections", len(p.sels))
+	}
+}
+
+func TestSyncWithoutLocalCopy(t *testing.T) {
+	p := &fakePlaylist{rev: []byte{1}, uris: trackURIs(3)}
+	src, id := testPlaylist(t, p)
+	res, err := playlist.SyncPlaylist(src, id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Full || !reflect.DeepEqual(res.List.URIs(), p.uris) {
+		t.Errorf("got %+v", res)
+	}
+}
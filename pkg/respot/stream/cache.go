@@ -0,0 +1,468 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// CacheOpts configures a Cache.
+type CacheOpts struct {
+	MaxBytes     int64 // total size of cached audio data before LRU eviction (default 1 GiB)
+	ChunkSize    int   // granularity of cached ranges, a multiple of 16 (default 128 KiB)
+	CompactAfter int   // journal records appended before the journal is compacted (default 10000)
+}
+
+// Cache keeps encrypted audio file chunks and audio keys on disk, keyed by
+// AudioFile.file_id.  Partially downloaded files are stored as sparse files
+// with a record of which chunks are present, so cached ranges can be served
+// with no network access.
+//
+// Every change, and the first use of a file after others were used, is
+// appended to a journal which is replayed on Open; a crash loses at most the
+// chunk being written.  The journal is compacted on Open and every
+// CompactAfter records.  Files are evicted least recently used first once
+// MaxBytes is exceeded.  A Cache is safe for concurrent use.
+//
+// Audio keys are kept in the journal as plain hex, protected only by the
+// permissions of dir (0700) and the journal (0600).
+type Cache struct {
+	dir  string
+	opts CacheOpts
+
+	mu      sync.Mutex
+	files   map[string]*cacheEntry
+	bytes   int64
+	tick    uint64
+	journal *os.File
+	jw      *bufio.Writer
+	records int // appended since the journal was last compacted
+}
+
+type cacheEntry struct {
+	size    int64
+	key     []byte
+	chunks  map[int64]int // chunk index => bytes stored
+	bytes   int64
+	lastUse uint64
+}
+
+// journalRecord is one line of the journal.
+type journalRecord struct {
+	Op    string `json:"op"` // "chunk", "key", "use" or "evict"
+	File  string `json:"file"`
+	Size  int64  `json:"size,omitempty"`
+	Chunk int64  `json:"chunk,omitempty"`
+	Len   int    `json:"len,omitempty"`
+	Key   string `json:"key,omitempty"`
+}
+
+const journalName = "journal"
+
+// OpenCache opens (or creates) a cache in dir, recovering its state from the journal.
+func OpenCache(dir string, opts CacheOpts) (*Cache, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 1 << 30
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 128 << 10
+	}
+	opts.ChunkSize = (opts.ChunkSize + 15) &^ 15
+	if opts.CompactAfter <= 0 {
+		opts.CompactAfter = 10000
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:   dir,
+		opts:  opts,
+		files: make(map[string]*cacheEntry),
+	}
+	if err := c.replay(); err != nil {
+		return nil, err
+	}
+	if err := c.rewriteJournal(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.evict("")
+	c.mu.Unlock()
+	return c, nil
+}
+
+// replay rebuilds the index from the journal, stopping at the first torn record.
+func (c *Cache) replay() error {
+	f, err := os.Open(filepath.Join(c.dir, journalName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec journalRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			break
+		}
+		c.apply(rec)
+	}
+
+	// Drop entries whose data file has gone missing
+	for fileID, entry := range c.files {
+		if len(entry.chunks) == 0 {
+			continue
+		}
+		if _, err := os.Stat(c.dataPath(fileID)); err != nil {
+			c.bytes -= entry.bytes
+			entry.chunks = make(map[int64]int)
+			entry.bytes = 0
+		}
+	}
+	return nil
+}
+
+func (c *Cache) apply(rec journalRecord) {
+	c.tick++
+	entry := c.files[rec.File]
+	switch rec.Op {
+	case "evict":
+		if entry != nil {
+			c.bytes -= entry.bytes
+			delete(c.files, rec.File)
+		}
+		return
+	case "use":
+		if entry != nil {
+			entry.lastUse = c.tick
+		}
+		return
+	}
+	if entry == nil {
+		entry = &cacheEntry{chunks: make(map[int64]int)}
+		c.files[rec.File] = entry
+	}
+	entry.lastUse = c.tick
+	switch rec.Op {
+	case "chunk":
+		entry.size = rec.Size
+		if _, exists := entry.chunks[rec.Chunk]; !exists {
+			entry.chunks[rec.Chunk] = rec.Len
+			entry.bytes += int64(rec.Len)
+			c.bytes += int64(rec.Len)
+		}
+	case "key":
+		entry.key, _ = hex.DecodeString(rec.Key)
+	}
+}
+
+// rewriteJournal replaces the journal with a compact snapshot of the current
+// state.  c.mu must be held once the Cache is open.
+func (c *Cache) rewriteJournal() error {
+	path := filepath.Join(c.dir, journalName)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+
+	// Oldest first so replay restores the LRU order
+	for _, fileID := range c.lruOrder() {
+		entry := c.files[fileID]
+		if entry.key != nil {
+			enc.Encode(journalRecord{Op: "key", File: fileID, Key: hex.EncodeToString(entry.key)})
+		}
+		for idx, n := range entry.chunks {
+			enc.Encode(journalRecord{Op: "chunk", File: fileID, Size: entry.size, Chunk: idx, Len: n})
+		}
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "rewriting audio cache journal")
+	}
+
+	if c.journal != nil {
+		c.journal.Close()
+		c.journal, c.jw = nil, nil
+	}
+	c.journal, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	c.jw = bufio.NewWriter(c.journal)
+	c.records = 0
+	return nil
+}
+
+// lruOrder returns file IDs, least recently used first.
+func (c *Cache) lruOrder() []string {
+	ids := make([]string, 0, len(c.files))
+	for fileID := range c.files {
+		ids = append(ids, fileID)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return c.files[ids[i]].lastUse < c.files[ids[j]].lastUse
+	})
+	return ids
+}
+
+// record appends rec to the journal and applies it, compacting the journal
+// every CompactAfter records.  c.mu must be held.
+func (c *Cache) record(rec journalRecord) error {
+	c.apply(rec)
+	if c.jw == nil {
+		return errors.ErrClosed
+	}
+	buf, _ := json.Marshal(rec)
+	c.jw.Write(append(buf, '\n'))
+	if err := c.jw.Flush(); err != nil {
+		return err
+	}
+	if c.records++; c.records >= c.opts.CompactAfter {
+		// On failure the old journal stays in use; try again after as many records
+		if err := c.rewriteJournal(); err != nil {
+			c.records = 0
+		}
+	}
+	return nil
+}
+
+func (c *Cache) dataPath(fileID string) string {
+	return filepath.Join(c.dir, fileID+".enc")
+}
+
+// Key returns the cached audio key for a file.
+func (c *Cache) Key(fileID []byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.files[hex.EncodeToString(fileID)]
+	if entry == nil || entry.key == nil {
+		return nil, false
+	}
+	return append([]byte(nil), entry.key...), true
+}
+
+// PutKey stores the audio key for a file.
+func (c *Cache) PutKey(fileID, key []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.record(journalRecord{Op: "key", File: hex.EncodeToString(fileID), Key: hex.EncodeToString(key)})
+}
+
+// Bytes returns the amount of audio data currently cached.
+func (c *Cache) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// Complete reports whether every chunk of a file is cached.
+func (c *Cache) Complete(fileID []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.files[hex.EncodeToString(fileID)]
+	return entry != nil && entry.size > 0 && entry.bytes == entry.size
+}
+
+// Remove evicts a file's data and key.
+func (c *Cache) Remove(fileID []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remove(hex.EncodeToString(fileID))
+}
+
+func (c *Cache) remove(fileID string) error {
+	if c.files[fileID] == nil {
+		return nil
+	}
+	if err := c.record(journalRecord{Op: "evict", File: fileID}); err != nil {
+		return err
+	}
+	err := os.Remove(c.dataPath(fileID))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// evict removes least recently used files (other than keep) until the cache fits MaxBytes.  c.mu must be held.
+func (c *Cache) evict(keep string) {
+	if c.bytes <= c.opts.MaxBytes {
+		return
+	}
+	for _, fileID := range c.lruOrder() {
+		if c.bytes <= c.opts.MaxBytes {
+			return
+		}
+		if fileID != keep && c.files[fileID].bytes > 0 {
+			c.remove(fileID)
+		}
+	}
+}
+
+// Close flushes and closes the journal.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.journal == nil {
+		return nil
+	}
+	err := c.jw.Flush()
+	if closeErr := c.journal.Close(); err == nil {
+		err = closeErr
+	}
+	c.journal, c.jw = nil, nil
+	return err
+}
+
+// Fetcher returns a Fetcher for fileID that serves cached ranges from disk and
+// fetches (and caches) the rest through upstream.  upstream may be nil to serve
+// only what is already cached.
+func (c *Cache) Fetcher(fileID []byte, upstream Fetcher) Fetcher {
+	return &cachingFetcher{
+		cache:    c,
+		fileID:   hex.EncodeToString(fileID),
+		upstream: upstream,
+	}
+}
+
+type cachingFetcher struct {
+	cache    *Cache
+	fileID   string
+	upstream Fetcher
+}
+
+func (f *cachingFetcher) Size() (int64, error) {
+	c := f.cache
+	c.mu.Lock()
+	var size int64
+	if entry := c.files[f.fileID]; entry != nil {
+		size = entry.size
+	}
+	c.mu.Unlock()
+	if size > 0 {
+		return size, nil
+	}
+	if f.upstream == nil {
+		return 0, errors.Wrapf(errors.Err404, "audio file %s not cached", f.fileID)
+	}
+	return f.upstream.Size()
+}
+
+func (f *cachingFetcher) FetchRange(off int64, n int) ([]byte, error) {
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+	if off+int64(n) > size {
+		n = int(size - off)
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	chunkSize := int64(f.cache.opts.ChunkSize)
+	buf := make([]byte, 0, n)
+	for pos := off; pos < off+int64(n); {
+		idx := pos / chunkSize
+		data, err := f.chunk(idx, size)
+		if err != nil {
+			return nil, err
+		}
+		within := pos - idx*chunkSize
+		take := int64(len(data)) - within
+		if rem := off + int64(n) - pos; take > rem {
+			take = rem
+		}
+		buf = append(buf, data[within:within+take]...)
+		pos += take
+	}
+	return buf, nil
+}
+
+// chunk returns cached chunk idx, fetching and storing it if missing.
+func (f *cachingFetcher) chunk(idx, size int64) ([]byte, error) {
+	c := f.cache
+	chunkSize := int64(c.opts.ChunkSize)
+	off := idx * chunkSize
+	length := chunkSize
+	if off+length > size {
+		length = size - off
+	}
+	path := c.dataPath(f.fileID)
+
+	c.mu.Lock()
+	entry := c.files[f.fileID]
+	cached := entry != nil && entry.chunks[idx] == int(length)
+	if cached && entry.lastUse != c.tick {
+		// Journal the use only if another file was used since, so that
+		// reading through one file adds a single record
+		c.record(journalRecord{Op: "use", File: f.fileID})
+	}
+	c.mu.Unlock()
+
+	if cached {
+		file, err := os.Open(path)
+		if err == nil {
+			data := make([]byte, length)
+			_, err = file.ReadAt(data, off)
+			file.Close()
+			if err == nil {
+				return data, nil
+			}
+		}
+		// Fall through and refetch whatever was lost
+	}
+
+	if f.upstream == nil {
+		return nil, errors.Wrapf(errors.Err404, "audio file %s: chunk %d not cached", f.fileID, idx)
+	}
+	data, err := f.upstream.FetchRange(off, int(length))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != length {
+		return nil, errors.Errorf("audio file %s: short read at %d", f.fileID, off)
+	}
+
+	// Failing to cache is not fatal to the read
+	if f.store(path, off, data) == nil {
+		c.mu.Lock()
+		c.record(journalRecord{Op: "chunk", File: f.fileID, Size: size, Chunk: idx, Len: len(data)})
+		c.evict(f.fileID)
+		c.mu.Unlock()
+	}
+	return data, nil
+}
+
+// store writes data at off and syncs it, so the data is durable before the journal says it is there.
+func (f *cachingFetcher) store(path string, off int64, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err = file.WriteAt(data, off); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,195 @@
+package stream_test
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/arcspace/go-librespot/pkg/respot/stream"
+)
+
+// memFetcher serves a byte slice and counts range requests.
+type memFetcher struct {
+	data []byte
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (f *memFetcher) Size() (int64, error) {
+	return int64(len(f.data)), nil
+}
+
+func (f *memFetcher) FetchRange(off int64, n int) ([]byte, error) {
+	f.mu.Lock()
+	f.requests++
+	f.mu.Unlock()
+	return f.data[off : off+int64(n)], nil
+}
+
+func fileID(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 20)
+}
+
+func cacheFile(t *testing.T, c *stream.Cache, id []byte, upstream stream.Fetcher) {
+	t.Helper()
+	f := c.Fetcher(id, upstream)
+	size, err := f.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.FetchRange(0, int(size)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func journalLines(t *testing.T, dir string) int {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	n := 0
+	for s := bufio.NewScanner(f); s.Scan(); n++ {
+	}
+	return n
+}
+
+func TestCacheKeepsRecentlyUsedAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	opts := stream.CacheOpts{ChunkSize: 1024}
+	c, err := stream.OpenCache(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &memFetcher{data: bytes.Repeat([]byte("a"), 4096)}
+	b := &memFetcher{data: bytes.Repeat([]byte("b"), 4096)}
+	cacheFile(t, c, fileID(1), a)
+	cacheFile(t, c, fileID(2), b)
+
+	// Reading a from the cache makes b the least recently used
+	cacheFile(t, c, fileID(1), nil)
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.MaxBytes = 4096
+	if c, err = stream.OpenCache(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if !c.Complete(fileID(1)) || c.Complete(fileID(2)) {
+		t.Errorf("after reopening, a cached %v and b %v; want only a", c.Complete(fileID(1)), c.Complete(fileID(2)))
+	}
+}
+
+func TestCacheCompactsJournal(t *testing.T) {
+	dir := t.TempDir()
+	opts := stream.CacheOpts{ChunkSize: 1024, CompactAfter: 20}
+	c, err := stream.OpenCache(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err = c.PutKey(fileID(1), bytes.Repeat([]byte{byte(i)}, 16)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := journalLines(t, dir); n >= 20 {
+		t.Errorf("journal has %d records after compaction every 20", n)
+	}
+	c.Close()
+
+	if c, err = stream.OpenCache(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if key, ok := c.Key(fileID(1)); !ok || key[0] != 99 {
+		t.Errorf("reopened with key %x, want the last one put", key)
+	}
+}
+
+func TestCacheRecoversFromTornJournal(t *testing.T) {
+	dir := t.TempDir()
+	opts := stream.CacheOpts{ChunkSize: 1024}
+	c, err := stream.OpenCache(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheFile(t, c, fileID(1), &memFetcher{data: bytes.Repeat([]byte("a"), 4096)})
+	if err = c.PutKey(fileID(1), fileID(9)[:16]); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// A crash mid-append leaves half a record without a newline
+	f, err := os.OpenFile(filepath.Join(dir, "journal"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"key","file":"02020202`)
+	f.Close()
+
+	if c, err = stream.OpenCache(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Complete(fileID(1)) {
+		t.Error("lost the records before the torn one")
+	}
+	if key, ok := c.Key(fileID(1)); !ok || !bytes.Equal(key, fileID(9)[:16]) {
+		t.Errorf("reopened with key %x", key)
+	}
+	if _, ok := c.Key(fileID(2)); ok {
+		t.Error("replayed the torn record")
+	}
+
+	// Records appended after recovery must not run on from the torn one
+	if err = c.PutKey(fileID(3), fileID(8)[:16]); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if c, err = stream.OpenCache(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, ok := c.Key(fileID(3)); !ok || !c.Complete(fileID(1)) {
+		t.Error("lost records written after recovering from a torn journal")
+	}
+}
+
+func TestCacheServesWhileFilling(t *testing.T) {
+	c, err := stream.OpenCache(t.TempDir(), stream.CacheOpts{ChunkSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	upstream := &memFetcher{data: bytes.Repeat([]byte("x"), 64<<10)}
+
+	// Concurrent readers of one file, for the race detector
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f := c.Fetcher(fileID(1), upstream)
+			for off := int64(i) * 1024; off < 64<<10; off += 4 * 1024 {
+				if _, err := f.FetchRange(off, 1024); err != nil {
+					t.Error(err)
+					return
+				}
+				f.Size()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	before := upstream.requests
+	cacheFile(t, c, fileID(1), upstream)
+	if upstream.requests != before {
+		t.Errorf("fetched %d chunks again that were cached", upstream.requests-before)
+	}
+}
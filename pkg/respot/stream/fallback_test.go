@@ -0,0 +1,107 @@
+package stream_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/audiokey/audiokeytest"
+	"github.com/arcspace/go-librespot/pkg/respot/channel"
+	"github.com/arcspace/go-librespot/pkg/respot/stream"
+)
+
+func TestPinFileFallsBackToChannels(t *testing.T) {
+	cdn, plain := newTestCDN(t, 2, 100<<10)
+	for _, h := range cdn.Hosts {
+		h.Fail(http.StatusForbidden)
+	}
+	ap := audiokeytest.New()
+	ap.AddFile(testFileID, encrypted(plain, 0))
+	m := channel.NewManager(ap, channel.Opts{})
+	ap.Attach(m)
+
+	p := &stream.Pinner{
+		Resolver: cdn.StreamResolver(),
+		Keys:     staticKeys{string(testFileID): testKey},
+		Channels: m,
+	}
+	asset, err := p.PinFile(&Spotify.Track{}, &Spotify.AudioFile{FileId: testFileID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := asset.NewAssetReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got := make([]byte, len(plain))
+	if _, err = io.ReadFull(r, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Error("read the wrong bytes over channels")
+	}
+	if ap.ChunkRequests() == 0 {
+		t.Error("no chunk requests reached the AP")
+	}
+}
+
+// scriptedFetcher fails while failing is set and counts its requests.
+type scriptedFetcher struct {
+	failing  bool
+	requests int
+}
+
+func (f *scriptedFetcher) Size() (int64, error) {
+	return 100, nil
+}
+
+func (f *scriptedFetcher) FetchRange(off int64, n int) ([]byte, error) {
+	f.requests++
+	if f.failing {
+		return nil, errors.New("unreachable")
+	}
+	return make([]byte, n), nil
+}
+
+func TestFallbackFetcherThresholdAndRetry(t *testing.T) {
+	primary, fallback := &scriptedFetcher{failing: true}, &scriptedFetcher{}
+	switches := 0
+	f := &stream.FallbackFetcher{
+		Primary:    primary,
+		Fallback:   fallback,
+		Threshold:  2,
+		RetryAfter: 50 * time.Millisecond,
+		OnFallback: func(error) { switches++ },
+	}
+	fetch := func() {
+		t.Helper()
+		if _, err := f.FetchRange(0, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each failure is covered by Fallback; the second one switches
+	fetch()
+	fetch()
+	if primary.requests != 2 || fallback.requests != 2 || switches != 1 {
+		t.Fatalf("primary %d, fallback %d requests, %d switches", primary.requests, fallback.requests, switches)
+	}
+	fetch()
+	if primary.requests != 2 {
+		t.Errorf("primary was asked again %v after switching", primary.requests)
+	}
+
+	// Once RetryAfter has passed, a recovered Primary takes over again
+	primary.failing = false
+	time.Sleep(60 * time.Millisecond)
+	fetch()
+	fetch()
+	if primary.requests != 4 || fallback.requests != 3 {
+		t.Errorf("primary %d, fallback %d requests after recovery", primary.requests, fallback.requests)
+	}
+}
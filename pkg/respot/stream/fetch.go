@@ -0,0 +1,258 @@
+// Package stream provides seekable, on-demand access to Spotify's encrypted
+// audio files: byte ranges are fetched from the CDN in fixed-size chunks and
+// decrypted (AES-128-CTR) from any offset.
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// Fetcher retrieves byte ranges of an encrypted audio file.
+type Fetcher interface {
+
+	// Size returns the total size of the file in bytes.
+	Size() (int64, error)
+
+	// FetchRange returns the n bytes starting at off (fewer only at the end of the file).
+	FetchRange(off int64, n int) ([]byte, error)
+}
+
+// HTTPFetcher fetches ranges of a single CDN URL using HTTP Range requests.
+type HTTPFetcher struct {
+	URL    string
+	Client *http.Client // defaults to http.DefaultClient
+
+	mu   sync.Mutex
+	size int64
+}
+
+func (f *HTTPFetcher) client() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	return http.DefaultClient
+}
+
+// Size issues a one-byte range request to learn the file size, which is then
+// remembered.  If the reply does not give the total, a HEAD request is made.
+func (f *HTTPFetcher) Size() (int64, error) {
+	f.mu.Lock()
+	size := f.size
+	f.mu.Unlock()
+	if size > 0 {
+		return size, nil
+	}
+	if _, err := f.FetchRange(0, 1); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	size = f.size
+	f.mu.Unlock()
+	if size > 0 {
+		return size, nil
+	}
+	size, err := headSize(context.Background(), f.client(), f.URL)
+	if err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	f.size = size
+	f.mu.Unlock()
+	return size, nil
+}
+
+// FetchRange implements Fetcher.
+func (f *HTTPFetcher) FetchRange(off int64, n int) ([]byte, error) {
+	data, size, err := fetchRange(context.Background(), f.client(), f.URL, off, n)
+	if size > 0 {
+		f.mu.Lock()
+		f.size = size
+		f.mu.Unlock()
+	}
+	return data, err
+}
+
+// fetchRange requests n bytes at off from url, returning them along with the
+// total file size if the server reported it.
+func fetchRange(ctx context.Context, client *http.Client, url string, off int64, n int) ([]byte, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(n)-1))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var size int64
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		size = parseContentRangeSize(resp.Header.Get("Content-Range"))
+	case http.StatusOK:
+		// Server ignored the range; skip to the part we asked for
+		size = resp.ContentLength
+		if _, err = io.CopyN(io.Discard, resp.Body, off); err != nil {
+			return nil, size, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, 0, io.EOF
+	default:
+		return nil, 0, &HTTPError{URL: url, Status: resp.StatusCode}
+	}
+
+	buf := make([]byte, n)
+	got, err := io.ReadFull(resp.Body, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return buf[:got], size, err
+}
+
+// ErrUnknownSize is returned when a server reports the size of a file neither
+// in its Content-Range nor in reply to a HEAD request.
+var ErrUnknownSize = errors.New("stream: server did not report the file size")
+
+// headSize learns the size of url from the Content-Length of a HEAD request.
+func headSize(ctx context.Context, client *http.Client, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, &HTTPError{URL: url, Status: resp.StatusCode}
+	}
+	if resp.ContentLength <= 0 {
+		return 0, errors.Wrap(ErrUnknownSize, url)
+	}
+	return resp.ContentLength, nil
+}
+
+// FallbackFetcher serves ranges from Primary and falls back to Fallback, e.g.
+// from the CDN to AP channels (see package channel) on networks that block the
+// CDN.  A range Primary fails to deliver is fetched from Fallback; after
+// Threshold consecutive failures Fallback serves everything until RetryAfter
+// has passed, when Primary gets another chance.
+type FallbackFetcher struct {
+	Primary  Fetcher
+	Fallback Fetcher
+
+	Threshold  int           // consecutive Primary failures before switching (default 1)
+	RetryAfter time.Duration // time on Fallback before Primary is retried (default 1 minute; < 0: never)
+
+	// OnFallback, if set, is called with Primary's error when switching.
+	OnFallback func(err error)
+
+	mu         sync.Mutex
+	failures   int
+	switchedAt time.Time // zero while Primary is in use
+}
+
+// usePrimary reports whether the next request should go to Primary.
+func (f *FallbackFetcher) usePrimary() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.switchedAt.IsZero() {
+		return true
+	}
+	retry := f.RetryAfter
+	if retry == 0 {
+		retry = time.Minute
+	}
+	if retry > 0 && time.Since(f.switchedAt) >= retry {
+		f.switchedAt = time.Time{}
+		f.failures = 0
+		return true
+	}
+	return false
+}
+
+// succeed resets the failure count after Primary delivered.
+func (f *FallbackFetcher) succeed() {
+	f.mu.Lock()
+	f.failures = 0
+	f.mu.Unlock()
+}
+
+// fail counts a Primary failure, switching to Fallback at the threshold.
+func (f *FallbackFetcher) fail(err error) {
+	threshold := f.Threshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	f.mu.Lock()
+	f.failures++
+	switching := f.switchedAt.IsZero() && f.failures >= threshold
+	if switching {
+		f.switchedAt = time.Now()
+	}
+	f.mu.Unlock()
+	if switching && f.OnFallback != nil {
+		f.OnFallback(err)
+	}
+}
+
+// Size implements Fetcher.
+func (f *FallbackFetcher) Size() (int64, error) {
+	if f.usePrimary() {
+		size, err := f.Primary.Size()
+		if err == nil {
+			f.succeed()
+			return size, nil
+		}
+		f.fail(err)
+	}
+	return f.Fallback.Size()
+}
+
+// FetchRange implements Fetcher.
+func (f *FallbackFetcher) FetchRange(off int64, n int) ([]byte, error) {
+	if f.usePrimary() {
+		data, err := f.Primary.FetchRange(off, n)
+		if err == nil || err == io.EOF {
+			f.succeed()
+			return data, err
+		}
+		f.fail(err)
+	}
+	return f.Fallback.FetchRange(off, n)
+}
+
+// HTTPError is returned when a CDN replies with an unexpected status.
+type HTTPError struct {
+	URL    string
+	Status int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("fetching %s: %d %s", e.URL, e.Status, http.StatusText(e.Status))
+}
+
+// parseContentRangeSize returns the total size from "bytes 0-99/12345", or -1.
+func parseContentRangeSize(contentRange string) int64 {
+	slash := strings.LastIndexByte(contentRange, '/')
+	if slash < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[slash+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
@@ -0,0 +1,142 @@
+package stream
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/channel"
+)
+
+// KeySource returns the AES key of an audio file of a track, as audiokey.Client does.
+type KeySource interface {
+	Key(gid, fileID []byte) ([]byte, error)
+}
+
+// Pinner pins audio files of tracks for streaming from the CDN.
+type Pinner struct {
+	Resolver *Resolver
+	Keys     KeySource
+	Cache    *Cache           // optional; keeps fetched chunks and keys on disk
+	Channels *channel.Manager // optional; AP channels serve ranges the CDN fails to
+	Failover FailoverOpts
+	Opts     ReaderOpts
+
+	// Used by PinTrack to look up tracks and relink them for the account's market
+	Source    catalog.Source
+	Country   string
+	Catalogue string
+	Policy    catalog.FormatPolicy // default catalog.PolicyDefault()
+}
+
+// Asset is a pinned audio file of a track.
+type Asset struct {
+	Track *Spotify.Track     // the track the file belongs to
+	File  *Spotify.AudioFile // the file that is streamed
+
+	fetcher Fetcher
+	key     []byte
+	opts    ReaderOpts
+}
+
+// PinTrack pins a track by base62 ID, URI or URL.  The track is relinked to a
+// version playable in Country (see catalog.ResolvePlayable) and the file to
+// stream is chosen by Policy; Asset.Track is the track actually pinned.
+func (p *Pinner) PinTrack(trackID string) (*Asset, error) {
+	id, err := catalog.ParseIDAs(catalog.KindTrack, trackID)
+	if err != nil {
+		return nil, err
+	}
+	track, err := catalog.ResolvePlayable(p.Source, id, p.Country, p.Catalogue)
+	if err != nil {
+		return nil, err
+	}
+	policy := p.Policy
+	if len(policy.Prefer) == 0 {
+		policy = catalog.PolicyDefault()
+	}
+	track, file, err := policy.SelectFile(p.Source, track, p.Country, p.Catalogue)
+	if err != nil {
+		return nil, err
+	}
+	return p.PinFile(track, file)
+}
+
+// PinFile pins the given audio file of track.  The audio key is requested up
+// front so that a restricted file fails here rather than on the first read.
+func (p *Pinner) PinFile(track *Spotify.Track, file *Spotify.AudioFile) (*Asset, error) {
+	fileID := file.GetFileId()
+	if len(fileID) == 0 {
+		return nil, errors.New("stream: audio file has no file ID")
+	}
+	key, err := p.key(track.GetGid(), fileID)
+	if err != nil {
+		return nil, err
+	}
+	var fetcher Fetcher = NewFailoverFetcher(p.Resolver, fileID, p.Failover)
+	if p.Channels != nil {
+		fetcher = &FallbackFetcher{
+			Primary:  fetcher,
+			Fallback: &channel.Fetcher{Manager: p.Channels, FileID: fileID},
+		}
+	}
+	if p.Cache != nil {
+		fetcher = p.Cache.Fetcher(fileID, fetcher)
+	}
+	return &Asset{
+		Track:   track,
+		File:    file,
+		fetcher: fetcher,
+		key:     key,
+		opts:    p.Opts,
+	}, nil
+}
+
+func (p *Pinner) key(gid, fileID []byte) ([]byte, error) {
+	if p.Cache != nil {
+		if key, ok := p.Cache.Key(fileID); ok {
+			return key, nil
+		}
+	}
+	key, err := p.Keys.Key(gid, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Cache != nil {
+		p.Cache.PutKey(fileID, key)
+	}
+	return key, nil
+}
+
+// NewAssetReader returns a seekable reader over the decrypted file.
+func (a *Asset) NewAssetReader() (*Reader, error) {
+	return NewReader(a.fetcher, a.key, a.opts)
+}
+
+// Format returns the format of the pinned file.
+func (a *Asset) Format() Spotify.AudioFile_Format {
+	return a.File.GetFormat()
+}
+
+// Label names the file by file ID and format, e.g. "<hex>.ogg".
+func (a *Asset) Label() string {
+	return fileLabel(a.File, "ogg")
+}
+
+// fileLabel names an audio file by its hex file ID and an extension matching
+// its format, or ext if the file has no format.
+func fileLabel(file *Spotify.AudioFile, ext string) string {
+	if file.Format != nil {
+		switch format := file.GetFormat().String(); {
+		case strings.HasPrefix(format, "OGG_"):
+			ext = "ogg"
+		case strings.HasPrefix(format, "MP3_"):
+			ext = "mp3"
+		case strings.HasPrefix(format, "AAC_"):
+			ext = "m4a"
+		}
+	}
+	return hex.EncodeToString(file.GetFileId()) + "." + ext
+}
@@ -0,0 +1,161 @@
+package stream_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/arcspace/go-librespot/pkg/respot/stream"
+	"github.com/golang/protobuf/proto"
+)
+
+type staticKeys map[string][]byte
+
+func (k staticKeys) Key(gid, fileID []byte) ([]byte, error) {
+	key, ok := k[string(fileID)]
+	if !ok {
+		return nil, fmt.Errorf("no key for %x", fileID)
+	}
+	return key, nil
+}
+
+func TestPinFileSeeks(t *testing.T) {
+	cdn, plain := newTestCDN(t, 2, 300<<10)
+	p := &stream.Pinner{
+		Resolver: cdn.StreamResolver(),
+		Keys:     staticKeys{string(testFileID): testKey},
+		Opts:     stream.ReaderOpts{ChunkSize: 16 << 10},
+	}
+	file := &Spotify.AudioFile{FileId: testFileID, Format: Spotify.AudioFile_OGG_VORBIS_160.Enum()}
+	asset, err := p.PinFile(&Spotify.Track{Gid: make([]byte, 16), Name: proto.String("t")}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Format() != Spotify.AudioFile_OGG_VORBIS_160 || !strings.HasSuffix(asset.Label(), ".ogg") {
+		t.Errorf("asset reports %v as %q", asset.Format(), asset.Label())
+	}
+
+	r, err := asset.NewAssetReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Size() != int64(len(plain)) {
+		t.Fatalf("size %d, want %d", r.Size(), len(plain))
+	}
+	const off = 200<<10 + 7
+	if _, err = r.Seek(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1000)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, plain[off:off+len(buf)]) {
+		t.Error("read the wrong bytes after seeking")
+	}
+}
+
+func TestPinFileWithoutKey(t *testing.T) {
+	cdn, _ := newTestCDN(t, 1, 1024)
+	p := &stream.Pinner{Resolver: cdn.StreamResolver(), Keys: staticKeys{}}
+	if _, err := p.PinFile(&Spotify.Track{}, &Spotify.AudioFile{FileId: testFileID}); err == nil {
+		t.Error("pinned a file without a key")
+	}
+}
+
+// Some servers answer ranges with "bytes 0-0/*", leaving the size to a HEAD request
+func TestHTTPFetcherSizeFromHead(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 5000)
+	heads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads++
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+		var start, end int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, end))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[start : end+1])
+	}))
+	defer srv.Close()
+
+	f := &stream.HTTPFetcher{URL: srv.URL}
+	size, err := f.Size()
+	if err != nil || size != int64(len(data)) || heads != 1 {
+		t.Errorf("size %d, err %v after %d HEAD requests", size, err, heads)
+	}
+}
+
+func TestHTTPFetcherUnknownSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Transfer-Encoding", "chunked")
+			return
+		}
+		w.Header().Set("Content-Range", "bytes 0-0/*")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("x"))
+	}))
+	defer srv.Close()
+
+	f := &stream.HTTPFetcher{URL: srv.URL}
+	if _, err := f.Size(); err == nil {
+		t.Error("got a size although the server reported none")
+	}
+}
+
+func TestPinTrackRelinks(t *testing.T) {
+	cdn, _ := newTestCDN(t, 1, 4096)
+	srv := mercurytest.New()
+	gid := func(b byte) []byte { g := make([]byte, 16); g[15] = b; return g }
+	forbidden := &Spotify.Restriction{
+		CountriesForbidden: proto.String("SE"),
+		Typ:                Spotify.Restriction_STREAMING.Enum(),
+	}
+	alt := &Spotify.Track{
+		Gid:  gid(2),
+		Name: proto.String("alt"),
+		File: []*Spotify.AudioFile{{FileId: testFileID, Format: Spotify.AudioFile_OGG_VORBIS_160.Enum()}},
+	}
+	orig := &Spotify.Track{
+		Gid:         gid(1),
+		Name:        proto.String("orig"),
+		Restriction: []*Spotify.Restriction{forbidden},
+		Alternative: []*Spotify.Track{{Gid: gid(2)}},
+		File:        []*Spotify.AudioFile{{FileId: []byte{9}, Format: Spotify.AudioFile_OGG_VORBIS_160.Enum()}},
+	}
+	origID, _ := catalog.FromGID(catalog.KindTrack, orig.Gid)
+	altID, _ := catalog.FromGID(catalog.KindTrack, alt.Gid)
+	srv.HandleTrack(origID.Hex(), orig)
+	srv.HandleTrack(altID.Hex(), alt)
+
+	p := &stream.Pinner{
+		Resolver: cdn.StreamResolver(),
+		Keys:     staticKeys{string(testFileID): testKey},
+		Source:   catalog.NewSource(srv),
+		Country:  "SE",
+	}
+	asset, err := p.PinTrack(origID.URI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Track.GetName() != "alt" || !bytes.Equal(asset.File.GetFileId(), testFileID) {
+		t.Errorf("pinned %q file %x, want the relinked track", asset.Track.GetName(), asset.File.GetFileId())
+	}
+
+	p.Country = "DE"
+	if _, err = p.PinTrack(origID.URI()); err == nil {
+		t.Error("pinned the unrelinked track although there is no key for its file")
+	}
+}
@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/arcspace/go-cedar/errors"
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+)
+
+// DefaultPreviewURL serves 30-second preview clips by hex file ID.
+const DefaultPreviewURL = "https://p.scdn.co/mp3-preview/"
+
+// ErrNoPreview is returned for tracks without a preview clip.
+var ErrNoPreview = errors.New("track has no preview")
+
+// Previews pins preview clips (Spotify.Track.preview).  Unlike full tracks they
+// are not encrypted, so no audio key is needed and free accounts can play them.
+type Previews struct {
+	Source  catalog.Source
+	BaseURL string       // default DefaultPreviewURL
+	Client  *http.Client // defaults to http.DefaultClient
+	Opts    ReaderOpts
+}
+
+// PreviewAsset is a pinned preview clip.
+type PreviewAsset struct {
+	Track *Spotify.Track
+	File  *Spotify.AudioFile
+
+	fetcher Fetcher
+	opts    ReaderOpts
+}
+
+// PinPreview looks up the preview clip of a track, given by base62 ID, URI or URL.
+func (p *Previews) PinPreview(trackID string) (*PreviewAsset, error) {
+	id, err := catalog.ParseIDAs(catalog.KindTrack, trackID)
+	if err != nil {
+		return nil, err
+	}
+	track, err := p.Source.GetTrack(id)
+	if err != nil {
+		return nil, err
+	}
+	file := PreviewFile(track)
+	if file == nil {
+		return nil, errors.Wrapf(ErrNoPreview, "%v", id)
+	}
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultPreviewURL
+	}
+	return &PreviewAsset{
+		Track: track,
+		File:  file,
+		fetcher: &HTTPFetcher{
+			URL:    baseURL + hex.EncodeToString(file.GetFileId()),
+			Client: p.Client,
+		},
+		opts: p.Opts,
+	}, nil
+}
+
+// PreviewFile returns the first preview clip of a track, or nil if it has none.
+func PreviewFile(track *Spotify.Track) *Spotify.AudioFile {
+	for _, file := range track.GetPreview() {
+		if len(file.GetFileId()) > 0 {
+			return file
+		}
+	}
+	return nil
+}
+
+// NewAssetReader returns a seekable reader over the clip.
+func (a *PreviewAsset) NewAssetReader() (*Reader, error) {
+	return NewReader(a.fetcher, nil, a.opts)
+}
+
+// Label names the clip by file ID and format, e.g. "<hex>.mp3".  Clips without
+// a format are assumed to be MP3, as served by DefaultPreviewURL.
+func (a *PreviewAsset) Label() string {
+	return fileLabel(a.File, "mp3")
+}
@@ -0,0 +1,52 @@
+package stream_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-librespot/Spotify"
+	"github.com/arcspace/go-librespot/pkg/respot/catalog"
+	"github.com/arcspace/go-librespot/pkg/respot/mercurytest"
+	"github.com/arcspace/go-librespot/pkg/respot/stream"
+)
+
+func TestPreviewSeeks(t *testing.T) {
+	clip := bytes.Repeat([]byte("0123456789"), 1000)
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "clip.mp3", time.Time{}, bytes.NewReader(clip))
+	}))
+	defer cdn.Close()
+
+	srv := mercurytest.New()
+	gid := make([]byte, 16)
+	id, _ := catalog.FromGID(catalog.KindTrack, gid)
+	srv.HandleTrack(id.Hex(), &Spotify.Track{
+		Gid:     gid,
+		Preview: []*Spotify.AudioFile{{FileId: []byte{0xab, 0xcd}}},
+	})
+
+	previews := stream.Previews{Source: catalog.NewSource(srv), BaseURL: cdn.URL + "/"}
+	asset, err := previews.PinPreview(id.Base62())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label := asset.Label(); label != "abcd.mp3" {
+		t.Errorf("label %q", label)
+	}
+	r, err := asset.NewAssetReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err = r.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	tail, err := io.ReadAll(r)
+	if err != nil || string(tail) != "56789" {
+		t.Errorf("read %q, %v from the end of the clip", tail, err)
+	}
+}
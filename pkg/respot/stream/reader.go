@@ -0,0 +1,278 @@
+package stream
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// AudioIV is the fixed AES-CTR initial counter Spotify uses for every audio file.
+var AudioIV = [aes.BlockSize]byte{
+	0x72, 0xe0, 0x67, 0xfb, 0xdd, 0xcb, 0xcf, 0x77,
+	0xeb, 0xe8, 0xbc, 0x64, 0x3f, 0x63, 0x0d, 0x93,
+}
+
+// ReaderOpts tunes a Reader.
+type ReaderOpts struct {
+	ChunkSize int // bytes per range request, rounded up to a multiple of 16 (default 128 KiB)
+	ReadAhead int // chunks prefetched in the background past the one being read (default 4)
+	MaxChunks int // decrypted chunks kept in memory (default 32)
+}
+
+func (opts *ReaderOpts) normalize() {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 128 << 10
+	}
+	opts.ChunkSize = (opts.ChunkSize + aes.BlockSize - 1) &^ (aes.BlockSize - 1)
+	if opts.ReadAhead < 0 {
+		opts.ReadAhead = 0
+	} else if opts.ReadAhead == 0 {
+		opts.ReadAhead = 4
+	}
+	if opts.MaxChunks <= opts.ReadAhead {
+		opts.MaxChunks = 32
+		if opts.MaxChunks <= opts.ReadAhead {
+			opts.MaxChunks = opts.ReadAhead + 1
+		}
+	}
+}
+
+// Reader decrypts an audio file fetched on demand.  It implements io.ReadSeeker
+// and io.ReaderAt; ReadAt is safe for concurrent use, Read and Seek are not.
+type Reader struct {
+	fetcher Fetcher
+	block   cipher.Block
+	size    int64
+	opts    ReaderOpts
+	pos     int64
+
+	mu     sync.Mutex
+	chunks map[int64]*chunk
+	tick   uint64
+	closed bool
+}
+
+type chunk struct {
+	done    chan struct{}
+	data    []byte // decrypted
+	err     error
+	lastUse uint64
+}
+
+// NewReader returns a Reader over the file served by fetcher, decrypted with the
+// 16-byte audio key.  A nil key reads the file as is, e.g. for preview clips.
+func NewReader(fetcher Fetcher, key []byte, opts ReaderOpts) (*Reader, error) {
+	var block cipher.Block
+	if key != nil {
+		var err error
+		if block, err = aes.NewCipher(key); err != nil {
+			return nil, errors.Wrap(err, "audio key")
+		}
+	}
+	size, err := fetcher.Size()
+	if err != nil {
+		return nil, err
+	}
+	opts.normalize()
+	return &Reader{
+		fetcher: fetcher,
+		block:   block,
+		size:    size,
+		opts:    opts,
+		chunks:  make(map[int64]*chunk),
+	}, nil
+}
+
+// Size returns the size of the file in bytes.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker.  Seeking is free; data is only fetched when read.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return r.pos, errors.Errorf("stream: invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return r.pos, errors.New("stream: negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("stream: negative offset")
+	}
+	chunkSize := int64(r.opts.ChunkSize)
+	n := 0
+	for n < len(p) {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		idx := off / chunkSize
+		r.prefetch(idx + 1)
+		data, err := r.chunk(idx)
+		if err != nil {
+			return n, err
+		}
+		within := int(off - idx*chunkSize)
+		if within >= len(data) {
+			return n, io.ErrUnexpectedEOF
+		}
+		copied := copy(p[n:], data[within:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// Close releases buffered chunks.  Background fetches still in flight are discarded.
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	r.chunks = make(map[int64]*chunk)
+	return nil
+}
+
+func (r *Reader) numChunks() int64 {
+	chunkSize := int64(r.opts.ChunkSize)
+	return (r.size + chunkSize - 1) / chunkSize
+}
+
+// chunk returns the decrypted chunk idx, fetching it if needed.
+func (r *Reader) chunk(idx int64) ([]byte, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, errors.ErrClosed
+	}
+	c := r.load(idx)
+	r.mu.Unlock()
+
+	<-c.done
+	if c.err != nil {
+		// Let a later read retry
+		r.mu.Lock()
+		if r.chunks[idx] == c {
+			delete(r.chunks, idx)
+		}
+		r.mu.Unlock()
+	}
+	return c.data, c.err
+}
+
+// prefetch starts background fetches of the read-ahead window beginning at idx.
+func (r *Reader) prefetch(idx int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	last := r.numChunks()
+	for i := idx; i < idx+int64(r.opts.ReadAhead) && i < last; i++ {
+		r.load(i)
+	}
+}
+
+// load returns the entry for chunk idx, starting its fetch if it is not present.  r.mu must be held.
+func (r *Reader) load(idx int64) *chunk {
+	r.tick++
+	if c := r.chunks[idx]; c != nil {
+		c.lastUse = r.tick
+		return c
+	}
+	c := &chunk{
+		done:    make(chan struct{}),
+		lastUse: r.tick,
+	}
+	r.chunks[idx] = c
+	r.evict()
+
+	go func() {
+		chunkSize := int64(r.opts.ChunkSize)
+		off := idx * chunkSize
+		n := chunkSize
+		if off+n > r.size {
+			n = r.size - off
+		}
+		c.data, c.err = r.fetcher.FetchRange(off, int(n))
+		if c.err == nil && r.block != nil {
+			Decrypt(r.block, c.data, off)
+		}
+		close(c.done)
+	}()
+	return c
+}
+
+// evict drops the least recently used completed chunks beyond MaxChunks.  r.mu must be held.
+func (r *Reader) evict() {
+	for len(r.chunks) > r.opts.MaxChunks {
+		var (
+			oldest    int64 = -1
+			oldestUse uint64
+		)
+		for idx, c := range r.chunks {
+			select {
+			case <-c.done:
+			default:
+				continue // still loading
+			}
+			if oldest < 0 || c.lastUse < oldestUse {
+				oldest, oldestUse = idx, c.lastUse
+			}
+		}
+		if oldest < 0 {
+			return
+		}
+		delete(r.chunks, oldest)
+	}
+}
+
+// Decrypt decrypts (or encrypts) buf in place, where buf holds the file bytes starting at off.
+func Decrypt(block cipher.Block, buf []byte, off int64) {
+	iv := CounterAt(off)
+	stream := cipher.NewCTR(block, iv[:])
+	if skip := int(off % aes.BlockSize); skip > 0 {
+		var discard [aes.BlockSize]byte
+		stream.XORKeyStream(discard[:skip], discard[:skip])
+	}
+	stream.XORKeyStream(buf, buf)
+}
+
+// CounterAt returns the CTR counter block for the AES block containing byte off.
+func CounterAt(off int64) [aes.BlockSize]byte {
+	iv := AudioIV
+	hi := binary.BigEndian.Uint64(iv[:8])
+	lo := binary.BigEndian.Uint64(iv[8:])
+	add := uint64(off / aes.BlockSize)
+	sum := lo + add
+	if sum < lo {
+		hi++
+	}
+	binary.BigEndian.PutUint64(iv[:8], hi)
+	binary.BigEndian.PutUint64(iv[8:], sum)
+	return iv
+}
@@ -0,0 +1,336 @@
+package stream
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-cedar/errors"
+)
+
+// DefaultResolveURL is the spclient storage-resolve endpoint.
+const DefaultResolveURL = "https://spclient.wg.spotify.com/storage-resolve/files/audio/interactive/"
+
+// ErrRestricted is returned when storage-resolve refuses to hand out a file.
+var ErrRestricted = errors.New("storage-resolve: file is restricted")
+
+// Resolved lists the CDN URLs a file can be fetched from.
+type Resolved struct {
+	URLs    []string
+	Expires time.Time
+}
+
+// Expired reports whether the URLs should be resolved again.
+func (res *Resolved) Expired() bool {
+	return !res.Expires.IsZero() && time.Now().After(res.Expires)
+}
+
+// Resolver looks up the CDN URLs of audio files via storage-resolve.
+type Resolver struct {
+	BaseURL string                 // defaults to DefaultResolveURL
+	Token   func() (string, error) // returns an access token for the Authorization header
+	Client  *http.Client           // defaults to http.DefaultClient
+}
+
+type resolveResponse struct {
+	Result string   `json:"result"`
+	CDNURL []string `json:"cdnurl"`
+	FileID string   `json:"fileid"`
+	TTL    int64    `json:"ttl"`
+}
+
+// Resolve returns every candidate CDN URL for fileID.
+func (r *Resolver) Resolve(fileID []byte) (*Resolved, error) {
+	base := r.BaseURL
+	if base == "" {
+		base = DefaultResolveURL
+	}
+	req, err := http.NewRequest(http.MethodGet, base+hex.EncodeToString(fileID)+"?alt=json", nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.Token != nil {
+		token, err := r.Token()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{URL: req.URL.String(), Status: resp.StatusCode}
+	}
+
+	var body resolveResponse
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "storage-resolve")
+	}
+	switch {
+	case body.Result == "RESTRICTED":
+		return nil, ErrRestricted
+	case len(body.CDNURL) == 0:
+		return nil, errors.Errorf("storage-resolve: no CDN URLs for %x (%s)", fileID, body.Result)
+	}
+	res := &Resolved{URLs: body.CDNURL}
+	if body.TTL > 0 {
+		res.Expires = time.Now().Add(time.Duration(body.TTL) * time.Second)
+	}
+	return res, nil
+}
+
+// HostStats is the recorded health of one CDN host.
+type HostStats struct {
+	Host        string
+	Successes   int
+	Failures    int
+	Slow        int // successful fetches below the minimum throughput
+	LastFailure time.Time
+	BytesPerSec float64 // moving average of successful fetches
+}
+
+// HostHealth records how CDN hosts have performed so the healthiest are tried
+// first.  It is safe for concurrent use and is meant to be shared by all fetchers.
+type HostHealth struct {
+	mu    sync.Mutex
+	hosts map[string]*HostStats
+}
+
+// NewHostHealth returns an empty health record.
+func NewHostHealth() *HostHealth {
+	return &HostHealth{hosts: make(map[string]*HostStats)}
+}
+
+func (h *HostHealth) stats(host string) *HostStats {
+	st := h.hosts[host]
+	if st == nil {
+		st = &HostStats{Host: host}
+		h.hosts[host] = st
+	}
+	return st
+}
+
+// Success records a fetch of n bytes from host that took d.
+func (h *HostHealth) Success(host string, n int, d time.Duration, slow bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.stats(host)
+	st.Successes++
+	if slow {
+		st.Slow++
+	}
+	if d > 0 {
+		rate := float64(n) / d.Seconds()
+		if st.BytesPerSec == 0 {
+			st.BytesPerSec = rate
+		} else {
+			st.BytesPerSec = 0.7*st.BytesPerSec + 0.3*rate
+		}
+	}
+}
+
+// Failure records a failed fetch from host.
+func (h *HostHealth) Failure(host string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.stats(host)
+	st.Failures++
+	st.LastFailure = time.Now()
+}
+
+// Stats returns a snapshot of every host seen, healthiest first.
+func (h *HostHealth) Stats() []HostStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := make([]HostStats, 0, len(h.hosts))
+	for _, st := range h.hosts {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return h.less(&stats[i], &stats[j])
+	})
+	return stats
+}
+
+// less orders hosts that failed recently last, then by fewest failures and slow fetches, then by speed.
+func (h *HostHealth) less(a, b *HostStats) bool {
+	const penaltyBox = time.Minute
+	aRecent := time.Since(a.LastFailure) < penaltyBox
+	bRecent := time.Since(b.LastFailure) < penaltyBox
+	if aRecent != bRecent {
+		return bRecent
+	}
+	if a.Failures+a.Slow != b.Failures+b.Slow {
+		return a.Failures+a.Slow < b.Failures+b.Slow
+	}
+	return a.BytesPerSec > b.BytesPerSec
+}
+
+// Order returns urls sorted healthiest host first; hosts with equal health keep their given order.
+func (h *HostHealth) Order(urls []string) []string {
+	ordered := append([]string(nil), urls...)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return h.less(h.stats(hostOf(ordered[i])), h.stats(hostOf(ordered[j])))
+	})
+	return ordered
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Host
+}
+
+// FailoverOpts configures a FailoverFetcher.
+type FailoverOpts struct {
+	Client        *http.Client  // defaults to http.DefaultClient
+	Health        *HostHealth   // shared host health; a private one is used if nil
+	MinThroughput float64       // bytes/sec below which a host is marked slow and moved down (0 to disable)
+	Timeout       time.Duration // deadline for one attempt on one URL, after which the next is tried (default 15s)
+}
+
+// Fetches smaller than this are dominated by latency and not judged for throughput
+const minThroughputSample = 16 << 10
+
+// FailoverFetcher fetches a file from whichever of its CDN URLs works, rotating
+// to the next URL on HTTP errors or when an attempt outlives opts.Timeout, and
+// demoting hosts that are slow.  URLs are resolved again when they expire or
+// all have failed.
+type FailoverFetcher struct {
+	resolve func() (*Resolved, error)
+	opts    FailoverOpts
+
+	mu       sync.Mutex
+	resolved *Resolved
+	size     int64
+}
+
+// NewFailoverFetcher returns a Fetcher for fileID resolved through resolver.
+func NewFailoverFetcher(resolver *Resolver, fileID []byte, opts FailoverOpts) *FailoverFetcher {
+	return NewFailoverFetcherFunc(func() (*Resolved, error) {
+		return resolver.Resolve(fileID)
+	}, opts)
+}
+
+// NewFailoverFetcherFunc is like NewFailoverFetcher with a custom resolve function.
+func NewFailoverFetcherFunc(resolve func() (*Resolved, error), opts FailoverOpts) *FailoverFetcher {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Health == nil {
+		opts.Health = NewHostHealth()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 15 * time.Second
+	}
+	return &FailoverFetcher{
+		resolve: resolve,
+		opts:    opts,
+	}
+}
+
+// urls returns the current candidate URLs, resolving if needed.
+func (f *FailoverFetcher) urls(refresh bool) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if refresh || f.resolved == nil || f.resolved.Expired() {
+		res, err := f.resolve()
+		if err != nil {
+			return nil, err
+		}
+		f.resolved = res
+	}
+	return f.opts.Health.Order(f.resolved.URLs), nil
+}
+
+// Size implements Fetcher.
+func (f *FailoverFetcher) Size() (int64, error) {
+	f.mu.Lock()
+	size := f.size
+	f.mu.Unlock()
+	if size > 0 {
+		return size, nil
+	}
+	if _, err := f.FetchRange(0, 1); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	size = f.size
+	f.mu.Unlock()
+	if size > 0 {
+		return size, nil
+	}
+
+	// The range reply lacked the total, so ask each URL in turn with HEAD
+	urls, err := f.urls(false)
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range urls {
+		ctx, cancel := context.WithTimeout(context.Background(), f.opts.Timeout)
+		size, err = headSize(ctx, f.opts.Client, u)
+		cancel()
+		if err == nil {
+			f.mu.Lock()
+			f.size = size
+			f.mu.Unlock()
+			return size, nil
+		}
+	}
+	return 0, err
+}
+
+// FetchRange implements Fetcher.
+func (f *FailoverFetcher) FetchRange(off int64, n int) ([]byte, error) {
+	var lastErr error
+	for pass := 0; pass < 2; pass++ {
+		urls, err := f.urls(pass > 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range urls {
+			host := hostOf(u)
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), f.opts.Timeout)
+			data, size, err := fetchRange(ctx, f.opts.Client, u, off, n)
+			cancel()
+			if err == io.EOF {
+				return nil, err
+			}
+			if err != nil {
+				f.opts.Health.Failure(host)
+				lastErr = err
+				continue
+			}
+			elapsed := time.Since(start)
+			slow := f.opts.MinThroughput > 0 && len(data) >= minThroughputSample &&
+				float64(len(data))/elapsed.Seconds() < f.opts.MinThroughput
+			f.opts.Health.Success(host, len(data), elapsed, slow)
+			if size > 0 {
+				f.mu.Lock()
+				f.size = size
+				f.mu.Unlock()
+			}
+			return data, nil
+		}
+		// Every URL failed; they may have expired early, so resolve again once
+	}
+	return nil, errors.Wrap(lastErr, "all CDN URLs failed")
+}
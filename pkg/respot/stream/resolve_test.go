@@ -0,0 +1,130 @@
+package stream_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/arcspace/go-librespot/pkg/respot/stream"
+	"github.com/arcspace/go-librespot/pkg/respot/stream/streamtest"
+)
+
+var (
+	testFileID = bytes.Repeat([]byte{0xf1}, 20)
+	testKey    = bytes.Repeat([]byte{0x4b}, 16)
+)
+
+func newTestCDN(t *testing.T, hosts, size int) (*streamtest.CDN, []byte) {
+	t.Helper()
+	cdn := streamtest.New(hosts)
+	t.Cleanup(cdn.Close)
+	plain := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(plain)
+	if err := cdn.AddFile(testFileID, testKey, plain); err != nil {
+		t.Fatal(err)
+	}
+	return cdn, plain
+}
+
+func readAll(t *testing.T, fetcher stream.Fetcher) []byte {
+	t.Helper()
+	r, err := stream.NewReader(fetcher, testKey, stream.ReaderOpts{ChunkSize: 32 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestFailoverSkipsFailingHost(t *testing.T) {
+	cdn, plain := newTestCDN(t, 3, 200<<10)
+	cdn.Hosts[0].Fail(http.StatusInternalServerError)
+
+	health := stream.NewHostHealth()
+	f := stream.NewFailoverFetcher(cdn.StreamResolver(), testFileID, stream.FailoverOpts{Health: health})
+	if got := readAll(t, f); !bytes.Equal(got, plain) {
+		t.Fatalf("read %d bytes that differ from the %d served", len(got), len(plain))
+	}
+
+	// The failing host is tried once, then demoted behind the working ones
+	if n := cdn.Hosts[0].Requests(); n != 1 {
+		t.Errorf("failing host got %d requests, want 1", n)
+	}
+	stats := health.Stats()
+	if last := stats[len(stats)-1]; last.Failures != 1 || last.Host != hostOf(cdn.Hosts[0].URL) {
+		t.Errorf("unhealthiest host is %+v, want the failing one", last)
+	}
+}
+
+func TestFailoverTimesOutStalledHost(t *testing.T) {
+	cdn, plain := newTestCDN(t, 2, 64<<10)
+	cdn.Hosts[0].SetDelay(2 * time.Second)
+
+	f := stream.NewFailoverFetcher(cdn.StreamResolver(), testFileID, stream.FailoverOpts{Timeout: 100 * time.Millisecond})
+	start := time.Now()
+	got, err := f.FetchRange(0, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fetch took %v despite a 100ms timeout", elapsed)
+	}
+	if !bytes.Equal(got, encrypted(plain[:1024], 0)) {
+		t.Error("fetched the wrong bytes")
+	}
+}
+
+func TestFailoverResolvesAgainWhenAllFail(t *testing.T) {
+	cdn, _ := newTestCDN(t, 2, 1024)
+	for _, h := range cdn.Hosts {
+		h.Fail(http.StatusServiceUnavailable)
+	}
+
+	f := stream.NewFailoverFetcher(cdn.StreamResolver(), testFileID, stream.FailoverOpts{})
+	if _, err := f.FetchRange(0, 16); err == nil {
+		t.Fatal("fetch succeeded with every host failing")
+	}
+	if n := cdn.Resolves(); n != 2 {
+		t.Errorf("resolved %d times, want 2", n)
+	}
+	for i, h := range cdn.Hosts {
+		if n := h.Requests(); n != 2 {
+			t.Errorf("host %d got %d requests, want 2", i, n)
+		}
+	}
+}
+
+func TestFailoverRestricted(t *testing.T) {
+	cdn, _ := newTestCDN(t, 1, 1024)
+	f := stream.NewFailoverFetcher(cdn.StreamResolver(), []byte{1, 2, 3}, stream.FailoverOpts{})
+	if _, err := f.Size(); err != stream.ErrRestricted {
+		t.Errorf("got %v, want ErrRestricted", err)
+	}
+}
+
+func encrypted(plain []byte, off int64) []byte {
+	block, err := aes.NewCipher(testKey)
+	if err != nil {
+		panic(err)
+	}
+	data := append([]byte(nil), plain...)
+	stream.Decrypt(block, data, off)
+	return data
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Host
+}
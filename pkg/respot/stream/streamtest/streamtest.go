@@ -0,0 +1,171 @@
+// Package streamtest provides a local stand-in for Spotify's storage-resolve
+// endpoint and CDN hosts, serving encrypted audio files over HTTP with
+// scriptable failures and slowness so failover can be exercised offline.
+package streamtest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arcspace/go-librespot/pkg/respot/stream"
+)
+
+// Host is one fake CDN edge.
+type Host struct {
+	*httptest.Server
+
+	cdn      *CDN
+	mu       sync.Mutex
+	status   int           // if non-zero, every request fails with this status
+	delay    time.Duration // added before each response
+	requests int
+}
+
+// Fail makes the host answer every request with status (0 restores it).
+func (h *Host) Fail(status int) {
+	h.mu.Lock()
+	h.status = status
+	h.mu.Unlock()
+}
+
+// SetDelay makes the host wait d before answering each request.
+func (h *Host) SetDelay(d time.Duration) {
+	h.mu.Lock()
+	h.delay = d
+	h.mu.Unlock()
+}
+
+// Requests returns how many requests the host has received.
+func (h *Host) Requests() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.requests
+}
+
+func (h *Host) serve(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests++
+	status, delay := h.status, h.delay
+	h.mu.Unlock()
+
+	time.Sleep(delay)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	fileID := strings.TrimPrefix(r.URL.Path, "/audio/")
+	h.cdn.mu.Lock()
+	data := h.cdn.files[fileID]
+	h.cdn.mu.Unlock()
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, fileID, time.Time{}, bytes.NewReader(data))
+}
+
+// CDN is a set of fake hosts plus a storage-resolve endpoint listing all of them.
+type CDN struct {
+	Hosts    []*Host
+	Resolver *httptest.Server
+
+	mu       sync.Mutex
+	files    map[string][]byte // hex file id => encrypted data
+	ttl      int64
+	resolves int
+}
+
+// New starts a CDN with n hosts.  Close it when done.
+func New(n int) *CDN {
+	cdn := &CDN{
+		files: make(map[string][]byte),
+	}
+	for i := 0; i < n; i++ {
+		h := &Host{cdn: cdn}
+		h.Server = httptest.NewServer(http.HandlerFunc(h.serve))
+		cdn.Hosts = append(cdn.Hosts, h)
+	}
+	cdn.Resolver = httptest.NewServer(http.HandlerFunc(cdn.resolve))
+	return cdn
+}
+
+// ResolveURL is the base URL to use as stream.Resolver.BaseURL.
+func (cdn *CDN) ResolveURL() string {
+	return cdn.Resolver.URL + "/storage-resolve/"
+}
+
+// StreamResolver returns a stream.Resolver pointed at this CDN.
+func (cdn *CDN) StreamResolver() *stream.Resolver {
+	return &stream.Resolver{BaseURL: cdn.ResolveURL()}
+}
+
+// SetTTL sets the lifetime in seconds reported for resolved URLs (0 means no expiry).
+func (cdn *CDN) SetTTL(seconds int64) {
+	cdn.mu.Lock()
+	cdn.ttl = seconds
+	cdn.mu.Unlock()
+}
+
+// Resolves returns how many storage-resolve requests have been made.
+func (cdn *CDN) Resolves() int {
+	cdn.mu.Lock()
+	defer cdn.mu.Unlock()
+	return cdn.resolves
+}
+
+// AddFile encrypts plain with key the way Spotify does and serves it as fileID.
+func (cdn *CDN) AddFile(fileID, key, plain []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	data := append([]byte(nil), plain...)
+	stream.Decrypt(block, data, 0) // CTR mode: encrypting is the same operation
+	cdn.mu.Lock()
+	cdn.files[hex.EncodeToString(fileID)] = data
+	cdn.mu.Unlock()
+	return nil
+}
+
+func (cdn *CDN) resolve(w http.ResponseWriter, r *http.Request) {
+	fileID := strings.TrimPrefix(r.URL.Path, "/storage-resolve/")
+	cdn.mu.Lock()
+	cdn.resolves++
+	_, known := cdn.files[fileID]
+	ttl := cdn.ttl
+	cdn.mu.Unlock()
+
+	body := map[string]interface{}{
+		"fileid": fileID,
+	}
+	if !known {
+		body["result"] = "RESTRICTED"
+	} else {
+		var urls []string
+		for _, h := range cdn.Hosts {
+			urls = append(urls, h.URL+"/audio/"+fileID)
+		}
+		body["result"] = "CDN"
+		body["cdnurl"] = urls
+		if ttl > 0 {
+			body["ttl"] = ttl
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// Close shuts down every host and the resolver.
+func (cdn *CDN) Close() {
+	for _, h := range cdn.Hosts {
+		h.Close()
+	}
+	cdn.Resolver.Close()
+}